@@ -0,0 +1,64 @@
+package ghttp
+
+import (
+	"encoding"
+	"mime"
+	"strings"
+)
+
+// getAccept 返回请求的Accept,为空时使用ContentType,form只用于请求body,不作为Accept
+func (o *Options) getAccept(contentType string) string {
+	if o.Accept != "" {
+		return o.Accept
+	}
+
+	ct := o.ContentType
+	if ct == "" && contentType == TypeProtobuf {
+		ct = TypeProtobuf
+	}
+	if ct == "" || ct == TypeForm || ct == TypeMultipart {
+		return ""
+	}
+
+	return ct
+}
+
+// checkMediaType 检查应答的Content-Type是否可以解码到result,StrictAccept时还需要符合Accept
+func (o *Options) checkMediaType(rspType string, accept string, result interface{}) error {
+	switch result.(type) {
+	case *string, *[]byte:
+		return nil
+	}
+
+	_, custom := result.(Unmarshaler)
+	_, binary := result.(encoding.BinaryUnmarshaler)
+	if _, ok := o.getCodec(rspType); !ok && !custom && !binary {
+		return &MediaTypeError{ContentType: rspType, Accept: accept}
+	}
+
+	if o.StrictAccept && accept != "" && !acceptMatch(accept, rspType) {
+		return &MediaTypeError{ContentType: rspType, Accept: accept}
+	}
+
+	return nil
+}
+
+// acceptMatch 判断contentType是否符合Accept,支持*/*和type/*,忽略q参数
+func acceptMatch(accept string, contentType string) bool {
+	for _, part := range strings.Split(accept, ",") {
+		mt, params, err := mime.ParseMediaType(strings.TrimSpace(part))
+		if err != nil || params["q"] == "0" {
+			continue
+		}
+
+		if mt == "*/*" || mt == contentType {
+			return true
+		}
+
+		if strings.HasSuffix(mt, "/*") && strings.HasPrefix(contentType, mt[:len(mt)-1]) {
+			return true
+		}
+	}
+
+	return false
+}
@@ -1,6 +1,12 @@
 package ghttp
 
-import "time"
+import (
+	"math"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+)
 
 type Backoff interface {
 	Reset()
@@ -21,3 +27,74 @@ func (b *ConstantBackoff) Next() time.Duration {
 func NewConstantBackoff(d time.Duration) *ConstantBackoff {
 	return &ConstantBackoff{Interval: d}
 }
+
+// HintExtractor 从应答头中提取服务器建议的等待时间,优先于Backoff
+type HintExtractor func(rsp *Response) (time.Duration, bool)
+
+// RetryAfterHint 解析Retry-After,支持秒数和HTTP-date
+func RetryAfterHint(rsp *Response) (time.Duration, bool) {
+	return parseHint(rsp, "Retry-After")
+}
+
+// RateLimitResetHint 解析X-RateLimit-Reset,大于1e9时视为unix时间戳,否则为秒数
+func RateLimitResetHint(rsp *Response) (time.Duration, bool) {
+	if rsp == nil {
+		return 0, false
+	}
+
+	val := strings.TrimSpace(rsp.Header.Get("X-RateLimit-Reset"))
+	n, err := strconv.ParseInt(val, 10, 64)
+	if err != nil || n < 0 {
+		return 0, false
+	}
+
+	if n > 1e9 {
+		d := time.Until(time.Unix(n, 0))
+		if d < 0 {
+			d = 0
+		}
+		return d, true
+	}
+
+	return time.Duration(n) * time.Second, true
+}
+
+// HeaderHint 解析自定义header,支持秒数,时长(如1.5s)和HTTP-date
+func HeaderHint(name string) HintExtractor {
+	return func(rsp *Response) (time.Duration, bool) {
+		return parseHint(rsp, name)
+	}
+}
+
+func parseHint(rsp *Response, name string) (time.Duration, bool) {
+	if rsp == nil {
+		return 0, false
+	}
+
+	val := strings.TrimSpace(rsp.Header.Get(name))
+	if val == "" {
+		return 0, false
+	}
+
+	if n, err := strconv.ParseFloat(val, 64); err == nil {
+		// 排除负数,NaN,Inf和溢出Duration的值
+		if !(n >= 0 && n*float64(time.Second) < math.MaxInt64) {
+			return 0, false
+		}
+		return time.Duration(n * float64(time.Second)), true
+	}
+
+	if d, err := time.ParseDuration(val); err == nil && d >= 0 {
+		return d, true
+	}
+
+	if t, err := http.ParseTime(val); err == nil {
+		d := time.Until(t)
+		if d < 0 {
+			d = 0
+		}
+		return d, true
+	}
+
+	return 0, false
+}
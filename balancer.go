@@ -0,0 +1,229 @@
+package ghttp
+
+import (
+	"context"
+	"errors"
+	"sort"
+	"sync"
+	"time"
+)
+
+const (
+	hostBackoffMin = time.Second
+	hostBackoffMax = time.Second * 30
+)
+
+// Balancer 在多个BaseURL间选择,每次执行(包括重试)前调用Next,执行后调用Done
+type Balancer interface {
+	Next() (string, error)
+	Done(baseURL string, err error)
+}
+
+// hostList 记录BaseURL及其健康状态
+// 连接失败或超时的BaseURL在退避时间内不会被选择,重试时会切换到其他BaseURL
+type hostList struct {
+	mutex sync.Mutex
+	hosts []string
+	down  map[string]bool
+	fails map[string]int       // 连续失败次数
+	until map[string]time.Time // 退避结束时间
+}
+
+func newHostList(hosts []string) hostList {
+	return hostList{
+		hosts: hosts,
+		down:  make(map[string]bool),
+		fails: make(map[string]int),
+		until: make(map[string]time.Time),
+	}
+}
+
+// SetHealthy 设置健康状态,如由外部健康检查调用,不健康的BaseURL不会被选择
+func (l *hostList) SetHealthy(baseURL string, healthy bool) {
+	l.mutex.Lock()
+	l.down[baseURL] = !healthy
+	l.mutex.Unlock()
+}
+
+// available 是否可以选择,需要持有锁
+func (l *hostList) available(host string, now time.Time) bool {
+	return !l.down[host] && !now.Before(l.until[host])
+}
+
+// fallback 全部在退避中时,选择最早结束退避的BaseURL,需要持有锁
+func (l *hostList) fallback() (int, bool) {
+	best := -1
+	for i, h := range l.hosts {
+		if l.down[h] {
+			continue
+		}
+		if best == -1 || l.until[h].Before(l.until[l.hosts[best]]) {
+			best = i
+		}
+	}
+
+	return best, best != -1
+}
+
+// report 记录执行结果,失败时按连续失败次数指数退避,成功时重置
+func (l *hostList) report(host string, err error) {
+	l.mutex.Lock()
+	defer l.mutex.Unlock()
+	if err == nil {
+		delete(l.fails, host)
+		delete(l.until, host)
+		return
+	}
+
+	// 调用者取消不认为是BaseURL的问题
+	if errors.Is(err, context.Canceled) {
+		return
+	}
+
+	l.fails[host]++
+	wait := hostBackoffMin << (l.fails[host] - 1)
+	if wait > hostBackoffMax || wait <= 0 {
+		wait = hostBackoffMax
+	}
+	l.until[host] = time.Now().Add(wait)
+}
+
+// RoundRobinBalancer 依次选择健康的BaseURL
+type RoundRobinBalancer struct {
+	hostList
+	next int
+}
+
+// NewRoundRobinBalancer 创建轮询的Balancer
+func NewRoundRobinBalancer(baseURLs ...string) *RoundRobinBalancer {
+	return &RoundRobinBalancer{hostList: newHostList(baseURLs)}
+}
+
+func (b *RoundRobinBalancer) Next() (string, error) {
+	b.mutex.Lock()
+	defer b.mutex.Unlock()
+	now := time.Now()
+	for i := 0; i < len(b.hosts); i++ {
+		idx := (b.next + i) % len(b.hosts)
+		if b.available(b.hosts[idx], now) {
+			b.next = idx + 1
+			return b.hosts[idx], nil
+		}
+	}
+
+	if idx, ok := b.fallback(); ok {
+		b.next = idx + 1
+		return b.hosts[idx], nil
+	}
+
+	return "", ErrNoAvailableHost
+}
+
+func (b *RoundRobinBalancer) Done(baseURL string, err error) {
+	b.report(baseURL, err)
+}
+
+// WeightedBalancer 按权重选择,使用平滑加权轮询,避免连续选择同一个BaseURL
+type WeightedBalancer struct {
+	hostList
+	weights []int
+	current []int
+}
+
+// NewWeightedBalancer 创建加权的Balancer,权重需要大于0
+func NewWeightedBalancer(weights map[string]int) *WeightedBalancer {
+	hosts := make([]string, 0, len(weights))
+	for h := range weights {
+		hosts = append(hosts, h)
+	}
+	sort.Strings(hosts)
+
+	b := &WeightedBalancer{hostList: newHostList(hosts)}
+	b.weights = make([]int, len(hosts))
+	b.current = make([]int, len(hosts))
+	for i, h := range hosts {
+		b.weights[i] = weights[h]
+	}
+
+	return b
+}
+
+func (b *WeightedBalancer) Next() (string, error) {
+	b.mutex.Lock()
+	defer b.mutex.Unlock()
+	now := time.Now()
+	best := -1
+	total := 0
+	for i, h := range b.hosts {
+		if !b.available(h, now) || b.weights[i] <= 0 {
+			continue
+		}
+
+		b.current[i] += b.weights[i]
+		total += b.weights[i]
+		if best == -1 || b.current[i] > b.current[best] {
+			best = i
+		}
+	}
+
+	if best == -1 {
+		if idx, ok := b.fallback(); ok {
+			return b.hosts[idx], nil
+		}
+		return "", ErrNoAvailableHost
+	}
+
+	b.current[best] -= total
+	return b.hosts[best], nil
+}
+
+func (b *WeightedBalancer) Done(baseURL string, err error) {
+	b.report(baseURL, err)
+}
+
+// LeastPendingBalancer 选择正在执行的请求最少的BaseURL
+type LeastPendingBalancer struct {
+	hostList
+	pending map[string]int
+}
+
+// NewLeastPendingBalancer 创建最少请求的Balancer
+func NewLeastPendingBalancer(baseURLs ...string) *LeastPendingBalancer {
+	return &LeastPendingBalancer{hostList: newHostList(baseURLs), pending: make(map[string]int)}
+}
+
+func (b *LeastPendingBalancer) Next() (string, error) {
+	b.mutex.Lock()
+	defer b.mutex.Unlock()
+	now := time.Now()
+	best := ""
+	for _, h := range b.hosts {
+		if !b.available(h, now) {
+			continue
+		}
+
+		if best == "" || b.pending[h] < b.pending[best] {
+			best = h
+		}
+	}
+
+	if best == "" {
+		idx, ok := b.fallback()
+		if !ok {
+			return "", ErrNoAvailableHost
+		}
+		best = b.hosts[idx]
+	}
+
+	b.pending[best]++
+	return best, nil
+}
+
+func (b *LeastPendingBalancer) Done(baseURL string, err error) {
+	b.mutex.Lock()
+	if b.pending[baseURL] > 0 {
+		b.pending[baseURL]--
+	}
+	b.mutex.Unlock()
+	b.report(baseURL, err)
+}
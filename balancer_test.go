@@ -0,0 +1,133 @@
+package ghttp
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"testing"
+)
+
+func TestBalancer(t *testing.T) {
+	rr := NewRoundRobinBalancer("a", "b", "c")
+	rr.SetHealthy("b", false)
+	var picks []string
+	for i := 0; i < 4; i++ {
+		h, _ := rr.Next()
+		picks = append(picks, h)
+	}
+	if !reflect.DeepEqual(picks, []string{"a", "c", "a", "c"}) {
+		t.Errorf("unexpected round robin: %v", picks)
+	}
+
+	w := NewWeightedBalancer(map[string]int{"a": 2, "b": 1})
+	picks = nil
+	for i := 0; i < 3; i++ {
+		h, _ := w.Next()
+		picks = append(picks, h)
+	}
+	if !reflect.DeepEqual(picks, []string{"a", "b", "a"}) {
+		t.Errorf("unexpected weighted: %v", picks)
+	}
+
+	lp := NewLeastPendingBalancer("a", "b")
+	h1, _ := lp.Next()
+	h2, _ := lp.Next()
+	lp.Done(h1, nil)
+	h3, _ := lp.Next()
+	if h1 != "a" || h2 != "b" || h3 != "a" {
+		t.Errorf("unexpected least pending: %v, %v, %v", h1, h2, h3)
+	}
+
+	lp.SetHealthy("a", false)
+	lp.SetHealthy("b", false)
+	if _, err := lp.Next(); err != ErrNoAvailableHost {
+		t.Errorf("expect ErrNoAvailableHost, got %v", err)
+	}
+}
+
+func TestBaseURLs(t *testing.T) {
+	newServer := func(name string) *httptest.Server {
+		return httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			w.Header().Set("Content-Type", TypeText)
+			w.Write([]byte(name + r.URL.Path + "?" + r.URL.RawQuery))
+		}))
+	}
+	s1, s2 := newServer("s1"), newServer("s2")
+	defer s1.Close()
+	defer s2.Close()
+
+	c := NewClient(WithBaseURLs([]string{s1.URL, s2.URL}))
+	var results []string
+	for i := 0; i < 2; i++ {
+		var text string
+		if _, err := c.Get("/users/{id}", &text, WithPathParam("id", 1), WithQuery("v", 1)); err != nil {
+			t.Fatal(err)
+		}
+		results = append(results, text)
+	}
+
+	if !reflect.DeepEqual(results, []string{"s1/users/1?v=1", "s2/users/1?v=1"}) {
+		t.Errorf("unexpected results: %v", results)
+	}
+}
+
+func TestFailover(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", TypeText)
+		w.Write([]byte("ok"))
+	}))
+	defer srv.Close()
+
+	// 已关闭的端口,连接会失败
+	dead := httptest.NewServer(http.NotFoundHandler())
+	dead.Close()
+
+	b := NewRoundRobinBalancer(dead.URL, srv.URL)
+	c := NewClient(WithBalancer(b), WithBackoff(nil))
+	for i := 0; i < 3; i++ {
+		var text string
+		if _, err := c.Get("/", &text, WithRetry(1)); err != nil || text != "ok" {
+			t.Fatalf("failover fail: %v, %v", text, err)
+		}
+	}
+
+	// 失败的BaseURL在退避中,不会再被选择
+	if b.fails[dead.URL] != 1 {
+		t.Errorf("unexpected fails: %v", b.fails[dead.URL])
+	}
+}
+
+func TestBalancerPending(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte(r.Host))
+	}))
+	defer srv.Close()
+
+	b := NewLeastPendingBalancer(srv.URL)
+	c := NewClient(WithBalancer(b))
+	fail := errors.New("fail")
+
+	// 编码,AuthProvider和hook失败时都不能遗漏Done
+	if _, err := c.Post("/", make(chan int), nil); err == nil {
+		t.Error("expect encode error")
+	}
+	if _, err := c.Get("/", nil, WithAuthProvider(func(ctx context.Context) (string, error) { return "", fail })); err != fail {
+		t.Errorf("expect auth error, got %v", err)
+	}
+	if _, err := c.Get("/", nil, WithHook(func(ev *Event) error { return fail })); err != fail {
+		t.Errorf("expect hook error, got %v", err)
+	}
+	var text string
+	if _, err := c.Get("/", &text, WithHostHeader("api.example.com")); err != nil || text != "api.example.com" {
+		t.Errorf("unexpected result: %q, %v", text, err)
+	}
+
+	b.mutex.Lock()
+	pending := b.pending[srv.URL]
+	b.mutex.Unlock()
+	if pending != 0 {
+		t.Errorf("expect no pending requests, got %d", pending)
+	}
+}
@@ -0,0 +1,57 @@
+package ghttp
+
+import (
+	"bytes"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+var benchBody = bytes.Repeat([]byte("0123456789abcdef"), 4096)
+
+func newBenchResponse() *http.Response {
+	return &http.Response{
+		Body:          ioutil.NopCloser(bytes.NewReader(benchBody)),
+		ContentLength: int64(len(benchBody)),
+	}
+}
+
+// 对比旧的读取和解码方式与fastDecode
+func BenchmarkStringResult(b *testing.B) {
+	b.Run("decode", func(b *testing.B) {
+		o := &Options{}
+		b.ReportAllocs()
+		for i := 0; i < b.N; i++ {
+			rsp := newBenchResponse()
+			data, _ := ioutil.ReadAll(rsp.Body)
+			var text string
+			o.decode(TypeText, data, &text)
+		}
+	})
+
+	b.Run("fast", func(b *testing.B) {
+		b.ReportAllocs()
+		for i := 0; i < b.N; i++ {
+			var text string
+			fastDecode(newBenchResponse(), &text, false)
+		}
+	})
+}
+
+func BenchmarkGetBytes(b *testing.B) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write(benchBody)
+	}))
+	defer srv.Close()
+
+	c := NewClient()
+	b.ReportAllocs()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		var data []byte
+		if _, err := c.Get(srv.URL, &data); err != nil {
+			b.Fatal(err)
+		}
+	}
+}
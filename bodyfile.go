@@ -0,0 +1,49 @@
+package ghttp
+
+import (
+	"io"
+	"mime"
+	"net/http"
+	"os"
+	"path/filepath"
+)
+
+// BodyFromFile 作为请求参数时以流的方式发送文件,同WithBodyFile
+type BodyFromFile string
+
+// fileBody WithBodyFile时发送的文件,每次执行重新打开,重试时可以重新发送
+type fileBody struct {
+	path string
+	size int64
+}
+
+// openFileBody 获取文件大小用于Content-Length,只支持普通文件
+func openFileBody(path string) (*fileBody, error) {
+	fi, err := os.Stat(path)
+	if err != nil {
+		return nil, err
+	}
+
+	if !fi.Mode().IsRegular() {
+		return nil, &os.PathError{Op: "open", Path: path, Err: ErrNotSupport}
+	}
+
+	return &fileBody{path: path, size: fi.Size()}, nil
+}
+
+func (f *fileBody) open() (io.ReadCloser, error) {
+	if f.size == 0 {
+		return http.NoBody, nil
+	}
+
+	return os.Open(f.path)
+}
+
+// fileContentType 按扩展名推断Content-Type,未知时为application/octet-stream
+func fileContentType(path string) string {
+	if ct := parseContentType(mime.TypeByExtension(filepath.Ext(path))); ct != "" {
+		return ct
+	}
+
+	return TypeOctetStream
+}
@@ -0,0 +1,188 @@
+package ghttp
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"time"
+)
+
+type BreakerState int
+
+const (
+	BreakerClosed   = BreakerState(0) // 正常
+	BreakerOpen     = BreakerState(1) // 直接返回ErrCircuitOpen
+	BreakerHalfOpen = BreakerState(2) // 允许少量试探请求
+)
+
+func (s BreakerState) String() string {
+	switch s {
+	case BreakerClosed:
+		return "closed"
+	case BreakerOpen:
+		return "open"
+	case BreakerHalfOpen:
+		return "half-open"
+	default:
+		return "unknown"
+	}
+}
+
+const (
+	defaultBreakerThreshold = 5
+	defaultBreakerCoolDown  = time.Second * 30
+)
+
+// CircuitBreaker 熔断器,依赖持续失败时快速失败,避免耗尽超时时间
+// 连续失败FailureThreshold次后打开,CoolDown后进入半开,试探成功后关闭,失败则重新打开
+// 默认按host区分,可以通过KeyFunc或WithBreakerKey指定
+type CircuitBreaker struct {
+	FailureThreshold int                                     // 连续失败次数达到后打开
+	CoolDown         time.Duration                           // 打开后等待多久进入半开
+	HalfOpenMax      int                                     // 半开时同时允许的试探请求数
+	KeyFunc          func(req *Request) string               // 计算key,默认使用host
+	IsFailure        func(rsp *Response, err error) bool     // 判断是否失败,默认为网络错误或5xx
+	OnStateChange    func(key string, from, to BreakerState) // 状态变化时调用,可用于日志和监控
+	mutex            sync.Mutex
+	circuits         map[string]*circuit
+}
+
+type circuit struct {
+	state    BreakerState
+	failures int
+	openAt   time.Time
+	probes   int
+}
+
+// NewCircuitBreaker 创建熔断器,threshold和coolDown为0时使用默认值
+func NewCircuitBreaker(threshold int, coolDown time.Duration) *CircuitBreaker {
+	if threshold <= 0 {
+		threshold = defaultBreakerThreshold
+	}
+	if coolDown <= 0 {
+		coolDown = defaultBreakerCoolDown
+	}
+
+	return &CircuitBreaker{FailureThreshold: threshold, CoolDown: coolDown, HalfOpenMax: 1}
+}
+
+// State 返回key当前的状态
+func (b *CircuitBreaker) State(key string) BreakerState {
+	b.mutex.Lock()
+	defer b.mutex.Unlock()
+	if c, ok := b.circuits[key]; ok {
+		if c.state == BreakerOpen && time.Since(c.openAt) >= b.CoolDown {
+			return BreakerHalfOpen
+		}
+		return c.state
+	}
+
+	return BreakerClosed
+}
+
+func (b *CircuitBreaker) key(req *Request, key string) string {
+	if key != "" {
+		return key
+	}
+
+	if b.KeyFunc != nil {
+		return b.KeyFunc(req)
+	}
+
+	return req.URL.Host
+}
+
+func (b *CircuitBreaker) isFailure(rsp *Response, err error) bool {
+	if b.IsFailure != nil {
+		return b.IsFailure(rsp, err)
+	}
+
+	if err != nil {
+		// 调用者取消不认为是依赖的问题
+		return !errors.Is(err, context.Canceled)
+	}
+
+	return rsp.StatusCode >= 500
+}
+
+// allow 判断是否允许发送请求
+func (b *CircuitBreaker) allow(key string) error {
+	b.mutex.Lock()
+	c := b.get(key)
+	var from BreakerState
+	changed := false
+	switch c.state {
+	case BreakerOpen:
+		if time.Since(c.openAt) < b.CoolDown {
+			b.mutex.Unlock()
+			return ErrCircuitOpen
+		}
+		from, changed = c.state, true
+		c.state = BreakerHalfOpen
+		c.probes = 0
+		fallthrough
+	case BreakerHalfOpen:
+		if c.probes >= b.HalfOpenMax && b.HalfOpenMax > 0 {
+			b.mutex.Unlock()
+			b.notify(key, from, BreakerHalfOpen, changed)
+			return ErrCircuitOpen
+		}
+		c.probes++
+	}
+	b.mutex.Unlock()
+
+	b.notify(key, from, BreakerHalfOpen, changed)
+	return nil
+}
+
+// report 记录执行结果
+func (b *CircuitBreaker) report(key string, failed bool) {
+	b.mutex.Lock()
+	c := b.get(key)
+	from := c.state
+	if c.state == BreakerOpen {
+		// 打开之前发出的请求,结果不再影响状态
+		b.mutex.Unlock()
+		return
+	}
+
+	if c.state == BreakerHalfOpen && c.probes > 0 {
+		c.probes--
+	}
+
+	if failed {
+		c.failures++
+		if c.state == BreakerHalfOpen || (c.state == BreakerClosed && c.failures >= b.FailureThreshold) {
+			c.state = BreakerOpen
+			c.openAt = time.Now()
+		}
+	} else {
+		c.failures = 0
+		c.state = BreakerClosed
+	}
+	to := c.state
+	b.mutex.Unlock()
+
+	b.notify(key, from, to, from != to)
+}
+
+func (b *CircuitBreaker) notify(key string, from, to BreakerState, changed bool) {
+	if changed && b.OnStateChange != nil {
+		b.OnStateChange(key, from, to)
+	}
+}
+
+// get 需要持有锁
+func (b *CircuitBreaker) get(key string) *circuit {
+	if b.circuits == nil {
+		b.circuits = make(map[string]*circuit)
+	}
+
+	c, ok := b.circuits[key]
+	if !ok {
+		c = &circuit{}
+		b.circuits[key] = c
+	}
+
+	return c
+}
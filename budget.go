@@ -0,0 +1,41 @@
+package ghttp
+
+import (
+	"sync"
+	"time"
+)
+
+// RetryBudget 以令牌桶限制重试的速率,避免故障期间重试放大流量,通常在NewClient时设置,所有请求共享
+// 每次重试消耗一个令牌,令牌以Rate每秒的速度补充,最多Burst个,没有令牌时不再重试并返回ErrRetryBudget
+type RetryBudget struct {
+	Rate   float64 // 每秒补充的令牌数
+	Burst  int     // 最多积累的令牌数
+	mutex  sync.Mutex
+	tokens float64
+	last   time.Time
+}
+
+// NewRetryBudget 创建RetryBudget,初始有burst个令牌
+func NewRetryBudget(rate float64, burst int) *RetryBudget {
+	return &RetryBudget{Rate: rate, Burst: burst, tokens: float64(burst), last: time.Now()}
+}
+
+// allow 消耗一个令牌,没有令牌时返回false
+func (b *RetryBudget) allow() bool {
+	b.mutex.Lock()
+	defer b.mutex.Unlock()
+
+	now := time.Now()
+	b.tokens += now.Sub(b.last).Seconds() * b.Rate
+	if b.tokens > float64(b.Burst) {
+		b.tokens = float64(b.Burst)
+	}
+	b.last = now
+
+	if b.tokens < 1 {
+		return false
+	}
+
+	b.tokens--
+	return true
+}
@@ -0,0 +1,70 @@
+package ghttp
+
+import "sync"
+
+// EventHandler 事件订阅者,与Hook不同,只能观察,不能中断请求
+type EventHandler func(ev *Event)
+
+// EventBus 按EventType分发事件,多个订阅者相互独立,可以随时订阅和取消
+type EventBus struct {
+	mutex sync.RWMutex
+	seq   int
+	subs  map[EventType][]subscriber
+}
+
+type subscriber struct {
+	id      int
+	handler EventHandler
+}
+
+// NewEventBus 创建EventBus
+func NewEventBus() *EventBus {
+	return &EventBus{subs: make(map[EventType][]subscriber)}
+}
+
+// Subscribe 订阅事件,返回取消订阅的函数
+func (b *EventBus) Subscribe(typ EventType, handler EventHandler) func() {
+	b.mutex.Lock()
+	defer b.mutex.Unlock()
+	b.seq++
+	id := b.seq
+	b.subs[typ] = append(b.subs[typ], subscriber{id: id, handler: handler})
+
+	return func() {
+		b.unsubscribe(typ, id)
+	}
+}
+
+func (b *EventBus) unsubscribe(typ EventType, id int) {
+	b.mutex.Lock()
+	defer b.mutex.Unlock()
+	subs := b.subs[typ]
+	for i, s := range subs {
+		if s.id == id {
+			// 复制一份,不影响正在分发的事件
+			b.subs[typ] = append(subs[:i:i], subs[i+1:]...)
+			return
+		}
+	}
+}
+
+// Publish 按订阅顺序通知所有订阅者
+func (b *EventBus) Publish(ev *Event) {
+	if b == nil {
+		return
+	}
+
+	b.mutex.RLock()
+	subs := b.subs[ev.Type]
+	b.mutex.RUnlock()
+
+	for _, s := range subs {
+		s.handler(ev)
+	}
+}
+
+// Hook 将EventBus作为Hook使用
+func (b *EventBus) Hook(ev *Event) error {
+	b.Publish(ev)
+	return nil
+}
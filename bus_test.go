@@ -0,0 +1,199 @@
+package ghttp
+
+import (
+	"bytes"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestEventBus(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte("ok"))
+	}))
+	defer srv.Close()
+
+	c := NewClient()
+	var prev, post, local int
+	c.Subscribe(EventPrev, func(ev *Event) { prev++ })
+	unsubscribe := c.Subscribe(EventPost, func(ev *Event) {
+		if ev.Rsp == nil || ev.Rsp.StatusCode != http.StatusOK {
+			t.Errorf("unexpected event: %+v", ev)
+		}
+		post++
+	})
+
+	if _, err := c.Get(srv.URL, nil, WithSubscribe(EventPost, func(ev *Event) { local++ })); err != nil {
+		t.Fatal(err)
+	}
+
+	unsubscribe()
+	if _, err := c.Get(srv.URL, nil); err != nil {
+		t.Fatal(err)
+	}
+
+	if prev != 2 || post != 1 || local != 1 {
+		t.Errorf("unexpected count: prev=%v, post=%v, local=%v", prev, post, local)
+	}
+}
+
+func TestGlobalHook(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte(r.Header.Get("X-Trace-Id")))
+	}))
+	defer srv.Close()
+
+	defer func() {
+		global.hooks = nil
+		global.middlewares = nil
+	}()
+
+	var order []string
+	RegisterGlobalHook(func(ev *Event) error {
+		if ev.Type == EventPrev {
+			order = append(order, "global")
+		}
+		return nil
+	})
+	RegisterMiddleware(func(next http.RoundTripper) http.RoundTripper {
+		return RoundTripperFunc(func(req *Request) (*Response, error) {
+			req.Header.Set("X-Trace-Id", "trace")
+			return next.RoundTrip(req)
+		})
+	})
+
+	var text string
+	_, err := Get(srv.URL, &text, WithContentType(TypeText), WithHook(func(ev *Event) error {
+		if ev.Type == EventPrev {
+			order = append(order, "local")
+		}
+		return nil
+	}))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if text != "trace" || len(order) != 2 || order[0] != "global" {
+		t.Errorf("unexpected result: %v, %v", text, order)
+	}
+}
+
+func TestRouteName(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte(r.URL.EscapedPath()))
+	}))
+	defer srv.Close()
+
+	var route, path string
+	hook := WithSubscribe(EventPost, func(ev *Event) { route = ev.Route })
+	if _, err := Get(srv.URL+"/users/{id}?v=1", &path, hook, WithContentType(TypeText), WithPathParam("id", "a/b")); err != nil {
+		t.Fatal(err)
+	}
+	if route != "/users/{id}" || path != "/users/a%2Fb" {
+		t.Errorf("unexpected route: %v, %v", route, path)
+	}
+
+	if _, err := Get(srv.URL+"/users/{id}", nil, hook, WithRouteName("GetUser"), WithPathParam("id", 1)); err != nil {
+		t.Fatal(err)
+	}
+	if route != "GetUser" {
+		t.Errorf("unexpected route: %v", route)
+	}
+}
+
+func TestUsageCollector(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/fail" {
+			w.WriteHeader(http.StatusInternalServerError)
+		}
+	}))
+	defer srv.Close()
+
+	u := NewUsageCollector()
+	c := NewClient(WithHook(u.Hook))
+	for i := 0; i < 3; i++ {
+		c.Get(srv.URL+"/ok", nil)
+	}
+	c.Get(srv.URL+"/fail", nil)
+	c.Get(srv.URL+"/users/1", nil, WithRouteName("users.get"))
+
+	r := u.Report()
+	if len(r.Endpoints) != 3 {
+		t.Fatalf("unexpected endpoints: %+v", r.Endpoints)
+	}
+	if e := r.Endpoints[0]; e.Count != 3 || e.Errors != 0 || e.Status[200] != 3 {
+		t.Errorf("unexpected usage: %+v", e)
+	}
+
+	buf := &bytes.Buffer{}
+	if err := r.WriteMarkdown(buf); err != nil {
+		t.Fatal(err)
+	}
+	if !strings.Contains(buf.String(), "| GET | users.get | 1 |") || !strings.Contains(buf.String(), "/fail | 1 | ") {
+		t.Errorf("unexpected markdown:\n%s", buf.String())
+	}
+
+	buf.Reset()
+	if err := r.WriteJSON(buf); err != nil {
+		t.Fatal(err)
+	}
+	if !strings.Contains(buf.String(), `"error_rate": 1`) {
+		t.Errorf("unexpected json:\n%s", buf.String())
+	}
+}
+
+func TestChain(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", TypeJSON)
+		switch r.URL.Path {
+		case "/login":
+			w.Write([]byte(`{"token":"t1"}`))
+		case "/users/t1":
+			w.Write([]byte(`{"name":"ghttp"}`))
+		default:
+			w.WriteHeader(http.StatusNotFound)
+		}
+	}))
+	defer srv.Close()
+
+	type login struct {
+		Token string `json:"token"`
+	}
+	type user struct {
+		Name string `json:"name"`
+	}
+
+	var steps []string
+	hook := func(ev *Event) error {
+		if ev.Type == EventPrev {
+			steps = append(steps, ev.Datas["chain.step"])
+		}
+		return nil
+	}
+
+	c := NewClient(WithBaseURL(srv.URL))
+	s, err := c.NewChain(WithHook(hook)).
+		Step("login", http.MethodPost, "/login", &login{}, nil).
+		Step("user", http.MethodGet, "/users/{token}", &user{}, func(s *ChainState, req *ChainRequest) error {
+			req.Opts = append(req.Opts, WithPathParam("token", s.Result("login").(*login).Token))
+			return nil
+		}).
+		Do()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if u := s.Result("user").(*user); u.Name != "ghttp" || strings.Join(steps, ",") != "login,user" {
+		t.Errorf("unexpected result: %+v, %v", u, steps)
+	}
+
+	_, err = c.NewChain().
+		Step("", http.MethodGet, "/missing", nil, nil).
+		Step("never", http.MethodGet, "/login", nil, nil).
+		Do()
+	var ce *ChainError
+	if !errors.As(err, &ce) || ce.Index != 0 || !IsStatusErr(ce.Err) {
+		t.Errorf("unexpected error: %v", err)
+	}
+}
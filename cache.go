@@ -0,0 +1,347 @@
+package ghttp
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// 缓存结果,通过Event.Cache和RequestInfo中的Attempt.Cache获取,不会写入应答的header
+const (
+	CacheHit         = "HIT"         // 直接使用缓存
+	CacheMiss        = "MISS"        // 未命中,从服务器获取
+	CacheRevalidated = "REVALIDATED" // 缓存过期,服务器返回304后继续使用
+)
+
+// cacheEntry 缓存的应答,使用json序列化后保存在CacheStorage中
+type cacheEntry struct {
+	Status int               `json:"status"`
+	Header http.Header       `json:"header"`
+	Body   []byte            `json:"body"`
+	Stored time.Time         `json:"stored"` // 收到应答的时间
+	Vary   map[string]string `json:"vary"`   // Vary中的header在请求中的值
+}
+
+// cacheableStatus 默认可以缓存的状态码,RFC 7231 6.1
+var cacheableStatus = map[int]bool{
+	http.StatusOK:                   true,
+	http.StatusNonAuthoritativeInfo: true,
+	http.StatusNoContent:            true,
+	http.StatusMultipleChoices:      true,
+	http.StatusMovedPermanently:     true,
+	http.StatusNotFound:             true,
+	http.StatusMethodNotAllowed:     true,
+	http.StatusGone:                 true,
+	http.StatusRequestURITooLong:    true,
+	http.StatusNotImplemented:       true,
+}
+
+// parseCacheControl 解析Cache-Control,key为小写
+func parseCacheControl(value string) map[string]string {
+	cc := make(map[string]string)
+	for _, part := range strings.Split(value, ",") {
+		part = strings.TrimSpace(part)
+		if part == "" {
+			continue
+		}
+
+		key, val := part, ""
+		if idx := strings.IndexByte(part, '='); idx != -1 {
+			key, val = part[:idx], strings.Trim(strings.TrimSpace(part[idx+1:]), `"`)
+		}
+		cc[strings.ToLower(strings.TrimSpace(key))] = val
+	}
+
+	return cc
+}
+
+// cacheKey method+url,请求带有Authorization或Cookie时加上其摘要,避免不同用户共享应答
+// 使用摘要而不是原始值,避免凭证出现在Redis等外部存储的key中
+func cacheKey(req *Request) string {
+	key := http.MethodGet + " " + req.URL.String()
+	auth, cookie := req.Header.Values("Authorization"), req.Header.Values("Cookie")
+	if len(auth) == 0 && len(cookie) == 0 {
+		return key
+	}
+
+	h := sha256.New()
+	h.Write([]byte(strings.Join(auth, ",")))
+	h.Write([]byte{'\n'})
+	h.Write([]byte(strings.Join(cookie, ",")))
+	return key + " " + hex.EncodeToString(h.Sum(nil))
+}
+
+func loadCache(storage CacheStorage, key string, req *Request) *cacheEntry {
+	data, ok := storage.Get(key)
+	if !ok {
+		return nil
+	}
+
+	e := &cacheEntry{}
+	if err := json.Unmarshal(data, e); err != nil {
+		storage.Delete(key)
+		return nil
+	}
+
+	for k, v := range e.Vary {
+		if req.Header.Get(k) != v {
+			return nil
+		}
+	}
+
+	return e
+}
+
+func (e *cacheEntry) save(storage CacheStorage, key string) {
+	if data, err := json.Marshal(e); err == nil {
+		storage.Set(key, data)
+	}
+}
+
+func (e *cacheEntry) date() time.Time {
+	if t, err := http.ParseTime(e.Header.Get("Date")); err == nil {
+		return t
+	}
+
+	return e.Stored
+}
+
+// lifetime 新鲜时间,依次使用max-age,Expires,Last-Modified的10%,RFC 7234 4.2.1
+func (e *cacheEntry) lifetime() time.Duration {
+	cc := parseCacheControl(e.Header.Get("Cache-Control"))
+	if v, ok := cc["max-age"]; ok {
+		if n, err := strconv.Atoi(v); err == nil {
+			return time.Duration(n) * time.Second
+		}
+		return 0
+	}
+
+	if v := e.Header.Get("Expires"); v != "" {
+		t, err := http.ParseTime(v)
+		if err != nil {
+			return 0
+		}
+		return t.Sub(e.date())
+	}
+
+	if t, err := http.ParseTime(e.Header.Get("Last-Modified")); err == nil {
+		if d := e.date().Sub(t); d > 0 {
+			return d / 10
+		}
+	}
+
+	return 0
+}
+
+// age 当前的年龄,RFC 7234 4.2.3
+func (e *cacheEntry) age(now time.Time) time.Duration {
+	age := e.Stored.Sub(e.date())
+	if age < 0 {
+		age = 0
+	}
+	if n, err := strconv.Atoi(e.Header.Get("Age")); err == nil {
+		if d := time.Duration(n) * time.Second; d > age {
+			age = d
+		}
+	}
+
+	return age + now.Sub(e.Stored)
+}
+
+// fresh 是否可以不经验证直接使用,会考虑请求中的no-cache和max-age
+func (e *cacheEntry) fresh(reqCC map[string]string, now time.Time) bool {
+	if _, ok := reqCC["no-cache"]; ok {
+		return false
+	}
+	if _, ok := parseCacheControl(e.Header.Get("Cache-Control"))["no-cache"]; ok {
+		return false
+	}
+
+	age := e.age(now)
+	if v, ok := reqCC["max-age"]; ok {
+		if n, err := strconv.Atoi(v); err == nil && age > time.Duration(n)*time.Second {
+			return false
+		}
+	}
+
+	return age < e.lifetime()
+}
+
+func (e *cacheEntry) hasValidator() bool {
+	return e.Header.Get("ETag") != "" || e.Header.Get("Last-Modified") != ""
+}
+
+func (e *cacheEntry) response(req *Request) *Response {
+	return &Response{
+		Status:        strconv.Itoa(e.Status) + " " + http.StatusText(e.Status),
+		StatusCode:    e.Status,
+		Proto:         "HTTP/1.1",
+		ProtoMajor:    1,
+		ProtoMinor:    1,
+		Header:        e.Header.Clone(),
+		Body:          ioutil.NopCloser(bytes.NewReader(e.Body)),
+		ContentLength: int64(len(e.Body)),
+		Request:       req,
+	}
+}
+
+// update 使用304应答中的header更新缓存,RFC 7234 4.3.4
+func (e *cacheEntry) update(rsp *Response) {
+	for k, v := range rsp.Header {
+		if k == "Content-Length" || k == "Content-Encoding" || k == "Transfer-Encoding" {
+			continue
+		}
+		e.Header[k] = v
+	}
+	e.Stored = time.Now()
+}
+
+// doCache 遵循Cache-Control和Expires缓存GET请求,过期后通过If-None-Match和If-Modified-Since验证
+// 不安全的方法成功后删除对应url的缓存,raw为true时由调用者读取body,只使用已有的缓存,不保存新的应答
+// 返回的status为缓存结果,未使用缓存时为空
+func doCache(storage CacheStorage, req *Request, raw bool, send func(req *Request) (*Response, error)) (*Response, string, error) {
+	key := cacheKey(req)
+	if req.Method != http.MethodGet {
+		rsp, err := send(req)
+		if err == nil && req.Method != http.MethodHead && req.Method != http.MethodOptions && rsp.StatusCode < 400 {
+			storage.Delete(key)
+		}
+		return rsp, "", err
+	}
+
+	// 调用者自己处理条件请求和Range
+	if req.Header.Get("If-None-Match") != "" || req.Header.Get("If-Modified-Since") != "" || req.Header.Get("Range") != "" {
+		rsp, err := send(req)
+		return rsp, "", err
+	}
+
+	reqCC := parseCacheControl(req.Header.Get("Cache-Control"))
+	if _, ok := reqCC["no-store"]; ok {
+		rsp, err := send(req)
+		return rsp, "", err
+	}
+
+	e := loadCache(storage, key, req)
+	if e == nil {
+		rsp, err := send(req)
+		if err != nil {
+			return nil, "", err
+		}
+		return storeCache(storage, key, req, rsp, raw)
+	}
+
+	if e.fresh(reqCC, time.Now()) {
+		return e.response(req), CacheHit, nil
+	}
+
+	if !e.hasValidator() {
+		rsp, err := send(req)
+		if err != nil {
+			return nil, "", err
+		}
+		return storeCache(storage, key, req, rsp, raw)
+	}
+
+	rsp, err := e.revalidate(req, send)
+	if err != nil {
+		return nil, "", err
+	}
+
+	if rsp.StatusCode != http.StatusNotModified {
+		return storeCache(storage, key, req, rsp, raw)
+	}
+
+	e.update(rsp)
+	e.save(storage, key)
+	return e.response(req), CacheRevalidated, nil
+}
+
+// revalidate 发送带If-None-Match和If-Modified-Since的请求,返回304时已关闭body
+func (e *cacheEntry) revalidate(req *Request, send func(req *Request) (*Response, error)) (*Response, error) {
+	creq := req.Clone(req.Context())
+	if etag := e.Header.Get("ETag"); etag != "" {
+		creq.Header.Set("If-None-Match", etag)
+	}
+	if lm := e.Header.Get("Last-Modified"); lm != "" {
+		creq.Header.Set("If-Modified-Since", lm)
+	}
+
+	rsp, err := send(creq)
+	if err != nil {
+		return nil, err
+	}
+
+	rsp.Request = req
+	if rsp.StatusCode == http.StatusNotModified {
+		io.Copy(ioutil.Discard, rsp.Body)
+		rsp.Body.Close()
+	}
+
+	return rsp, nil
+}
+
+// storeCache 可以缓存时读取body并保存,raw时不读取body
+func storeCache(storage CacheStorage, key string, req *Request, rsp *Response, raw bool) (*Response, string, error) {
+	if raw || !cacheableStatus[rsp.StatusCode] {
+		return rsp, CacheMiss, nil
+	}
+
+	cc := parseCacheControl(rsp.Header.Get("Cache-Control"))
+	if _, ok := cc["no-store"]; ok {
+		return rsp, CacheMiss, nil
+	}
+
+	e := newCacheEntry(req, rsp)
+	if e == nil || (e.lifetime() <= 0 && !e.hasValidator()) {
+		return rsp, CacheMiss, nil
+	}
+
+	if err := e.saveBody(storage, key, rsp); err != nil {
+		return nil, "", err
+	}
+
+	return rsp, CacheMiss, nil
+}
+
+// newCacheEntry 创建不含body的缓存,Vary为*时返回nil
+func newCacheEntry(req *Request, rsp *Response) *cacheEntry {
+	e := &cacheEntry{Status: rsp.StatusCode, Header: rsp.Header.Clone(), Stored: time.Now()}
+	for _, v := range rsp.Header.Values("Vary") {
+		for _, h := range strings.Split(v, ",") {
+			h = http.CanonicalHeaderKey(strings.TrimSpace(h))
+			if h == "*" {
+				return nil
+			}
+			if h == "" {
+				continue
+			}
+			if e.Vary == nil {
+				e.Vary = make(map[string]string)
+			}
+			e.Vary[h] = req.Header.Get(h)
+		}
+	}
+
+	return e
+}
+
+// saveBody 读取body并保存,同时替换rsp.Body以便继续使用
+func (e *cacheEntry) saveBody(storage CacheStorage, key string, rsp *Response) error {
+	data, err := readAll(rsp.Body, rsp.ContentLength)
+	rsp.Body.Close()
+	if err != nil {
+		return err
+	}
+
+	rsp.Body = ioutil.NopCloser(bytes.NewReader(data))
+	e.Body = data
+	e.save(storage, key)
+	return nil
+}
@@ -0,0 +1,126 @@
+package ghttp
+
+import (
+	"container/list"
+	"crypto/sha256"
+	"encoding/hex"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sync"
+)
+
+// CacheStorage 缓存的存储,可以是内存,磁盘,也可以通过此接口使用Redis等在多个进程间共享
+type CacheStorage interface {
+	Get(key string) ([]byte, bool)
+	Set(key string, value []byte)
+	Delete(key string)
+}
+
+// MemoryStorage 基于LRU的内存存储
+type MemoryStorage struct {
+	mutex sync.Mutex
+	max   int
+	list  *list.List
+	items map[string]*list.Element
+}
+
+type memoryItem struct {
+	key   string
+	value []byte
+}
+
+// NewMemoryStorage 创建内存存储,最多保存max条,超过时淘汰最久未使用的,max<=0时不限制
+func NewMemoryStorage(max int) *MemoryStorage {
+	return &MemoryStorage{max: max, list: list.New(), items: make(map[string]*list.Element)}
+}
+
+func (s *MemoryStorage) Get(key string) ([]byte, bool) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	if e, ok := s.items[key]; ok {
+		s.list.MoveToFront(e)
+		return e.Value.(*memoryItem).value, true
+	}
+
+	return nil, false
+}
+
+func (s *MemoryStorage) Set(key string, value []byte) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	if e, ok := s.items[key]; ok {
+		e.Value.(*memoryItem).value = value
+		s.list.MoveToFront(e)
+		return
+	}
+
+	s.items[key] = s.list.PushFront(&memoryItem{key: key, value: value})
+	if s.max > 0 && s.list.Len() > s.max {
+		e := s.list.Back()
+		s.list.Remove(e)
+		delete(s.items, e.Value.(*memoryItem).key)
+	}
+}
+
+func (s *MemoryStorage) Delete(key string) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	if e, ok := s.items[key]; ok {
+		s.list.Remove(e)
+		delete(s.items, key)
+	}
+}
+
+// DiskStorage 磁盘存储,每个key一个文件,文件名为key的sha256
+type DiskStorage struct {
+	Dir string
+}
+
+// NewDiskStorage 创建磁盘存储,目录不存在时自动创建
+func NewDiskStorage(dir string) *DiskStorage {
+	return &DiskStorage{Dir: dir}
+}
+
+func (s *DiskStorage) path(key string) string {
+	sum := sha256.Sum256([]byte(key))
+	return filepath.Join(s.Dir, hex.EncodeToString(sum[:]))
+}
+
+func (s *DiskStorage) Get(key string) ([]byte, bool) {
+	data, err := ioutil.ReadFile(s.path(key))
+	if err != nil {
+		return nil, false
+	}
+
+	return data, true
+}
+
+// Set 先写入临时文件再重命名,避免其他进程读到不完整的数据
+func (s *DiskStorage) Set(key string, value []byte) {
+	if err := os.MkdirAll(s.Dir, 0755); err != nil {
+		return
+	}
+
+	f, err := ioutil.TempFile(s.Dir, "tmp-")
+	if err != nil {
+		return
+	}
+
+	_, err = f.Write(value)
+	if cerr := f.Close(); err == nil {
+		err = cerr
+	}
+	if err != nil {
+		os.Remove(f.Name())
+		return
+	}
+
+	if err := os.Rename(f.Name(), s.path(key)); err != nil {
+		os.Remove(f.Name())
+	}
+}
+
+func (s *DiskStorage) Delete(key string) {
+	os.Remove(s.path(key))
+}
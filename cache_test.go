@@ -0,0 +1,164 @@
+package ghttp
+
+import (
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+)
+
+func TestCache(t *testing.T) {
+	var hits int32
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		atomic.AddInt32(&hits, 1)
+		w.Header().Set("Content-Type", TypeJSON)
+		switch r.URL.Path {
+		case "/fresh":
+			w.Header().Set("Cache-Control", "max-age=60")
+		case "/etag":
+			w.Header().Set("Cache-Control", "no-cache")
+			w.Header().Set("ETag", `"v1"`)
+			if r.Header.Get("If-None-Match") == `"v1"` {
+				w.WriteHeader(http.StatusNotModified)
+				return
+			}
+		case "/nostore":
+			w.Header().Set("Cache-Control", "no-store")
+		}
+		w.Write([]byte(`{"id":1}`))
+	}))
+	defer srv.Close()
+
+	var statuses []string
+	hook := func(ev *Event) error {
+		if ev.Type == EventPost {
+			statuses = append(statuses, ev.Cache)
+		}
+		return nil
+	}
+
+	for _, storage := range []CacheStorage{NewMemoryStorage(10), NewDiskStorage(t.TempDir())} {
+		c := NewClient(WithCache(storage), WithHook(hook))
+		cases := []struct {
+			path   string
+			status []string
+			hits   int32
+		}{
+			{"/fresh", []string{CacheMiss, CacheHit}, 1},
+			{"/etag", []string{CacheMiss, CacheRevalidated}, 2},
+			{"/nostore", []string{CacheMiss, CacheMiss}, 2},
+		}
+		for _, cs := range cases {
+			atomic.StoreInt32(&hits, 0)
+			statuses = nil
+			for i := 0; i < 2; i++ {
+				var result struct {
+					ID int `json:"id"`
+				}
+				rsp, err := c.Get(srv.URL+cs.path, &result)
+				if err != nil {
+					t.Fatal(err)
+				}
+				if result.ID != 1 || len(rsp.Header.Values("X-Ghttp-Cache")) != 0 {
+					t.Errorf("%s: unexpected result %+v, %v", cs.path, result, rsp.Header)
+				}
+			}
+			if atomic.LoadInt32(&hits) != cs.hits || len(statuses) != 2 || statuses[0] != cs.status[0] || statuses[1] != cs.status[1] {
+				t.Errorf("%s: hits=%d, statuses=%v", cs.path, hits, statuses)
+			}
+		}
+
+		// 不安全的方法使缓存失效
+		atomic.StoreInt32(&hits, 0)
+		if _, err := c.Post(srv.URL+"/fresh", nil, nil); err != nil {
+			t.Fatal(err)
+		}
+		if _, err := c.Get(srv.URL+"/fresh", nil); err != nil {
+			t.Fatal(err)
+		}
+		if atomic.LoadInt32(&hits) != 2 {
+			t.Errorf("cache not invalidated, hits=%d", hits)
+		}
+
+		// RawResponse由调用者读取body,不保存到缓存
+		atomic.StoreInt32(&hits, 0)
+		rsp, err := c.Get(srv.URL+"/fresh?raw", nil, WithRawResponse())
+		if err != nil {
+			t.Fatal(err)
+		}
+		if data, err := ioutil.ReadAll(rsp.Body); err != nil || string(data) != `{"id":1}` {
+			t.Errorf("unexpected raw body: %s, %v", data, err)
+		}
+		rsp.Body.Close()
+		if _, err := c.Get(srv.URL+"/fresh?raw", nil); err != nil {
+			t.Fatal(err)
+		}
+		if atomic.LoadInt32(&hits) != 2 {
+			t.Errorf("raw response cached, hits=%d", hits)
+		}
+	}
+
+	s := NewMemoryStorage(1)
+	s.Set("a", []byte("1"))
+	s.Set("b", []byte("2"))
+	if _, ok := s.Get("a"); ok {
+		t.Errorf("lru not evicted")
+	}
+}
+
+func TestConditional(t *testing.T) {
+	var full int32
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", TypeJSON)
+		w.Header().Set("Cache-Control", "max-age=60")
+		w.Header().Set("Last-Modified", "Mon, 02 Jan 2006 15:04:05 GMT")
+		if r.Header.Get("If-Modified-Since") != "" {
+			w.WriteHeader(http.StatusNotModified)
+			return
+		}
+		atomic.AddInt32(&full, 1)
+		w.Write([]byte(`{"id":1}`))
+	}))
+	defer srv.Close()
+
+	c := NewClient(WithConditional(nil))
+	for i := 0; i < 3; i++ {
+		var result struct {
+			ID int `json:"id"`
+		}
+		var info RequestInfo
+		if _, err := c.Get(srv.URL, &result, WithRequestInfo(&info)); err != nil {
+			t.Fatal(err)
+		}
+		if result.ID != 1 || (i > 0 && info.Attempts[0].Cache != CacheRevalidated) {
+			t.Errorf("unexpected result %+v, %+v", result, info.Attempts)
+		}
+	}
+	if full != 1 {
+		t.Errorf("unexpected full downloads: %d", full)
+	}
+}
+
+func TestCacheCredentials(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Cache-Control", "max-age=60")
+		w.Header().Set("ETag", `"`+r.Header.Get("Authorization")+`"`)
+		if r.Header.Get("If-None-Match") != "" && r.Header.Get("If-None-Match") == `"`+r.Header.Get("Authorization")+`"` {
+			w.WriteHeader(http.StatusNotModified)
+			return
+		}
+		w.Write([]byte(r.Header.Get("Authorization")))
+	}))
+	defer srv.Close()
+
+	for _, opt := range []Option{WithCache(NewMemoryStorage(0)), WithConditional(nil)} {
+		c := NewClient(opt)
+		for _, user := range []string{"alice", "bob", "alice"} {
+			var text string
+			if _, err := c.With(WithBearAuth(user)).Get(srv.URL, &text); err != nil || text != "Bearer "+user {
+				t.Errorf("%s: unexpected result %q, %v", user, text, err)
+			}
+		}
+	}
+}
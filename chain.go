@@ -0,0 +1,121 @@
+package ghttp
+
+import (
+	"context"
+	"fmt"
+	"strconv"
+)
+
+// Chain 多步骤的请求,上一步解码后的结果可以用于下一步的path,query和body
+// 所有步骤共享Context和Options,任一步骤失败时终止并返回ChainError
+type Chain struct {
+	client *Client
+	opts   []Option
+	steps  []*chainStep
+}
+
+// ChainRequest 单个步骤的请求,可以在ChainFunc中根据之前的结果修改
+type ChainRequest struct {
+	Method string      //
+	URL    string      // 可以包含{name}占位符,通过WithPathParam替换
+	Body   interface{} //
+	Result interface{} // 解码结果,之后的步骤通过ChainState.Result获取
+	Opts   []Option    // 本步骤额外的Options
+}
+
+// ChainFunc 从之前步骤的结果中提取参数并修改本次请求,返回错误时终止
+type ChainFunc func(s *ChainState, req *ChainRequest) error
+
+// ChainState 已执行步骤的结果
+type ChainState struct {
+	Context   context.Context //
+	results   map[string]interface{}
+	responses map[string]*Response
+}
+
+// Result 返回name步骤的解码结果,需要类型断言为设置的Result类型
+func (s *ChainState) Result(name string) interface{} {
+	return s.results[name]
+}
+
+// Response 返回name步骤的应答,body已经被读取或关闭
+func (s *ChainState) Response(name string) *Response {
+	return s.responses[name]
+}
+
+// ChainError 步骤失败时的错误
+type ChainError struct {
+	Step  string // 步骤名
+	Index int    // 步骤序号,从0开始
+	Err   error  //
+}
+
+func (e *ChainError) Error() string {
+	return fmt.Sprintf("chain step %d(%s): %v", e.Index, e.Step, e.Err)
+}
+
+func (e *ChainError) Unwrap() error {
+	return e.Err
+}
+
+type chainStep struct {
+	name string
+	req  ChainRequest
+	fn   ChainFunc
+}
+
+// NewChain 创建Chain,opts用于所有步骤,可以通过WithContext设置共享的Context
+func (c *Client) NewChain(opts ...Option) *Chain {
+	return &Chain{client: c, opts: opts}
+}
+
+// Step 添加步骤,name用于获取结果和hook中的追踪,为空时使用序号,fn可以为nil
+func (ch *Chain) Step(name string, method string, url string, result interface{}, fn ChainFunc) *Chain {
+	if name == "" {
+		name = strconv.Itoa(len(ch.steps))
+	}
+
+	ch.steps = append(ch.steps, &chainStep{name: name, req: ChainRequest{Method: method, URL: url, Result: result}, fn: fn})
+	return ch
+}
+
+// Do 按顺序执行所有步骤,每个步骤在Event.Datas中带有chain.step,便于追踪
+func (ch *Chain) Do() (*ChainState, error) {
+	o := ch.client.buildOptions(ch.opts...)
+	s := &ChainState{
+		Context:   o.Context,
+		results:   make(map[string]interface{}, len(ch.steps)),
+		responses: make(map[string]*Response, len(ch.steps)),
+	}
+
+	for i, step := range ch.steps {
+		if err := s.Context.Err(); err != nil {
+			return s, &ChainError{Step: step.name, Index: i, Err: err}
+		}
+
+		req := step.req
+		if step.fn != nil {
+			if err := step.fn(s, &req); err != nil {
+				return s, &ChainError{Step: step.name, Index: i, Err: err}
+			}
+		}
+
+		opts := make([]Option, 0, len(ch.opts)+len(req.Opts)+2)
+		opts = append(opts, ch.opts...)
+		opts = append(opts, WithContext(s.Context), WithData("chain.step", step.name))
+		opts = append(opts, req.Opts...)
+		rsp, err := ch.client.DoRequest(req.Method, req.URL, req.Body, req.Result, opts...)
+		if err != nil {
+			return s, &ChainError{Step: step.name, Index: i, Err: err}
+		}
+
+		if req.Result == nil {
+			rsp.Body.Close()
+		}
+
+		s.results[step.name] = req.Result
+		s.responses[step.name] = rsp
+	}
+
+	return s, nil
+}
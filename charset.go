@@ -0,0 +1,80 @@
+package ghttp
+
+import (
+	"mime"
+	"strings"
+
+	"golang.org/x/text/encoding/htmlindex"
+)
+
+// isUTF8 判断是否无需转码
+func isUTF8(charset string) bool {
+	switch strings.ToLower(charset) {
+	case "", UTF8, "utf8", "us-ascii":
+		return true
+	default:
+		return false
+	}
+}
+
+// isTextType 判断是否是文本格式,只有文本格式才需要转码
+func isTextType(contentType string) bool {
+	switch contentType {
+	case TypeJSON, TypeXML, TypeForm, TypeHTML, TypeText, TypeYAML, typeYAMLText:
+		return true
+	default:
+		return strings.HasPrefix(contentType, "text/")
+	}
+}
+
+// encodeCharset 将utf-8转为charset编码,支持GBK,GB18030,Big5,Shift-JIS等
+func encodeCharset(data []byte, charset string) ([]byte, error) {
+	if isUTF8(charset) {
+		return data, nil
+	}
+
+	enc, err := htmlindex.Get(charset)
+	if err != nil {
+		return nil, err
+	}
+
+	return enc.NewEncoder().Bytes(data)
+}
+
+// decodeCharset 将charset编码转为utf-8
+func decodeCharset(data []byte, charset string) ([]byte, error) {
+	if isUTF8(charset) {
+		return data, nil
+	}
+
+	enc, err := htmlindex.Get(charset)
+	if err != nil {
+		return nil, err
+	}
+
+	return enc.NewDecoder().Bytes(data)
+}
+
+// withCharset 文本格式且设置了Charset时,在Content-Type中添加charset参数
+func withCharset(contentType string, charset string) string {
+	if charset == "" || !isTextType(contentType) {
+		return contentType
+	}
+
+	return contentType + "; charset=" + strings.ToLower(charset)
+}
+
+// parseCharset 解析Content-Type中的charset参数
+func parseCharset(content string) string {
+	return parseContentParam(content, "charset")
+}
+
+// parseContentParam 解析Content-Type中的参数,key为小写
+func parseContentParam(content string, key string) string {
+	_, params, err := mime.ParseMediaType(content)
+	if err != nil {
+		return ""
+	}
+
+	return params[key]
+}
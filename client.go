@@ -3,13 +3,15 @@ package ghttp
 import (
 	"bytes"
 	"context"
+	"crypto/tls"
 	"errors"
 	"fmt"
+	"io"
 	"io/ioutil"
 	"net"
 	"net/http"
-	"path"
 	"strings"
+	"sync"
 	"time"
 )
 
@@ -17,6 +19,30 @@ var (
 	ErrNoData      = errors.New("no data")
 	ErrNotSupport  = errors.New("not support")
 	ErrInvalidType = errors.New("invalid type")
+
+	ErrArchiveTooLarge    = errors.New("archive too large")
+	ErrInvalidArchivePath = errors.New("invalid archive path")
+
+	ErrETagMismatch     = errors.New("etag mismatch")
+	ErrResponseTooLarge = errors.New("response too large")
+	ErrNoAvailableHost  = errors.New("no available host")
+	ErrCircuitOpen      = errors.New("circuit open")
+	ErrTooManyRequests  = errors.New("too many requests")
+	ErrNoDNSAnswer      = errors.New("no dns answer")
+	ErrQuietHours       = errors.New("quiet hours")
+	ErrPageLimit        = errors.New("page limit exceeded")
+	ErrRetryBudget      = errors.New("retry budget exhausted")
+	ErrInvalidConfig    = errors.New("invalid config")
+
+	// 应答的Content-Type无法解码或不符合Accept,具体信息通过errors.As获取MediaTypeError
+	ErrUnsupportedMediaType = errors.New("unsupported media type")
+
+	// 网络错误的分类,通过errors.Is判断
+	ErrTimeout           = errors.New("timeout")
+	ErrDNS               = errors.New("dns error")
+	ErrConnectionRefused = errors.New("connection refused")
+	ErrTLS               = errors.New("tls error")
+	ErrCanceled          = errors.New("canceled")
 )
 
 // NewClient 通过参数创建Client
@@ -25,25 +51,172 @@ func NewClient(opts ...Option) *Client {
 	o.setNewDefault()
 	o.build(opts...)
 
-	client := &http.Client{
-		Timeout: o.Timeout,
-		Transport: &http.Transport{
-			DialContext: (&net.Dialer{
-				Timeout:   o.DialTimeout,
-				KeepAlive: o.KeepAlive,
-			}).DialContext,
-			TLSHandshakeTimeout: o.HandshakeTimeout,
-		},
+	transport := o.Transport
+	if transport == nil && o.HTTPClient == nil {
+		transport = newTransport(o)
+	}
+
+	client := o.HTTPClient
+	if client != nil && o.Transport != nil {
+		// 复制一份,避免修改调用者的Client
+		cp := *client
+		cp.Transport = o.Transport
+		client = &cp
+	} else if client == nil {
+		client = &http.Client{
+			Timeout:   o.Timeout,
+			Transport: transport,
+		}
+		if len(o.HostOptions) > 0 {
+			// 由每次执行的context控制超时,否则host的超时不能超过默认值
+			client.Timeout = 0
+		}
 	}
 
-	c := &Client{client: client, baseURL: o.BaseURL, hooks: o.Hooks}
+	// 复制一份,避免调用者修改opts后影响之后的请求
+	opts = append([]Option(nil), opts...)
+	c := &Client{client: client, opts: opts, bus: NewEventBus()}
 	return c
 }
 
+var shared struct {
+	once      sync.Once
+	transport http.RoundTripper
+}
+
+// SharedTransport 返回包级别共享的Transport,使用默认的拨号和连接池参数,第一次调用时创建
+func SharedTransport() http.RoundTripper {
+	shared.once.Do(func() {
+		o := &Options{}
+		o.setNewDefault()
+		o.build()
+		shared.transport = newTransport(o)
+	})
+
+	return shared.transport
+}
+
+// newTransport 按拨号,TLS和连接池参数创建Transport
+func newTransport(o *Options) http.RoundTripper {
+	dialer := &net.Dialer{
+		Timeout:   o.DialTimeout,
+		KeepAlive: o.KeepAlive,
+	}
+	if o.Dialer != nil {
+		// 复制一份,避免修改调用者的Dialer
+		cp := *o.Dialer
+		dialer = &cp
+	}
+	if o.LocalAddr != nil {
+		dialer.LocalAddr = &net.TCPAddr{IP: o.LocalAddr}
+	}
+	if o.Resolver != nil {
+		dialer.Resolver = o.Resolver
+	}
+	if o.SocketMark != 0 || o.TrafficClass != 0 {
+		dialer.Control = socketControl(o.SocketMark, o.TrafficClass, dialer.Control)
+	}
+
+	dial := o.DialContext
+	if dial == nil {
+		dial = dialer.DialContext
+	}
+	if o.DNSCache != nil {
+		dial = o.DNSCache.wrap(dial)
+	}
+
+	ht := &http.Transport{
+		DialContext:           dial,
+		TLSHandshakeTimeout:   o.HandshakeTimeout,
+		MaxIdleConns:          o.MaxIdleConns,
+		MaxIdleConnsPerHost:   o.MaxIdleConnsPerHost,
+		MaxConnsPerHost:       o.MaxConnsPerHost,
+		IdleConnTimeout:       o.IdleConnTimeout,
+		ResponseHeaderTimeout: o.ResponseHeaderTimeout,
+		ExpectContinueTimeout: o.ExpectContinueTimeout,
+		DisableKeepAlives:     o.DisableKeepAlives,
+	}
+	if o.Proxy != nil {
+		ht.Proxy = o.Proxy
+	}
+	serverName := o.ServerName
+	if serverName == "" && o.HostHeader != "" {
+		serverName = hostname(o.HostHeader)
+	}
+	if o.TLSConfig != nil {
+		ht.TLSClientConfig = o.TLSConfig.Clone()
+	}
+	if o.TLSKeyLogWriter != nil || serverName != "" {
+		if ht.TLSClientConfig == nil {
+			ht.TLSClientConfig = &tls.Config{}
+		}
+		if o.TLSKeyLogWriter != nil {
+			ht.TLSClientConfig.KeyLogWriter = o.TLSKeyLogWriter
+		}
+		if serverName != "" {
+			ht.TLSClientConfig.ServerName = serverName
+		}
+	}
+
+	if o.H2C {
+		return newH2CTransport(dial, ht)
+	}
+
+	return ht
+}
+
+// Client 可以被多个goroutine同时使用,创建后默认参数不再修改,每次请求都重新生成Options
+// 需要不同的默认参数时使用With创建新的Client,Option不应修改捕获的变量
 type Client struct {
-	client  *http.Client
-	baseURL string
-	hooks   []Hook
+	client *http.Client
+	opts   []Option  // 默认参数,每次请求时先于请求参数应用
+	bus    *EventBus // Client级别的事件订阅
+
+	noCompression sync.Map    // 拒绝过压缩请求body的host
+	flight        flightGroup // 合并相同的并发请求
+	fresh         freshClient // 不复用连接的http.Client,用于失效连接的重试
+}
+
+// With 返回共享连接池和事件订阅的新Client,opts在原默认参数之后应用,用于如按租户设置不同的认证
+// 仅NewClient时有效的参数不生效,合并并发请求时不与原Client合并
+func (c *Client) With(opts ...Option) *Client {
+	all := make([]Option, 0, len(c.opts)+len(opts))
+	all = append(all, c.opts...)
+	all = append(all, opts...)
+
+	// 由每次执行的context控制超时,使新的Timeout可以超过原来的值
+	client := *c.client
+	client.Timeout = 0
+	return &Client{client: &client, opts: all, bus: c.bus}
+}
+
+// Std 返回底层的http.Client,用于需要*http.Client的库,直接使用时不经过Option和全局中间件
+func (c *Client) Std() *http.Client {
+	return c.client
+}
+
+// CloseIdleConnections 关闭空闲连接,通常在程序退出时调用,使用SharedTransport时会影响其他Client
+func (c *Client) CloseIdleConnections() {
+	c.client.CloseIdleConnections()
+}
+
+// rejectsCompression 判断host是否拒绝过压缩的请求body
+func (c *Client) rejectsCompression(host string) bool {
+	_, ok := c.noCompression.Load(host)
+	return ok
+}
+
+// Subscribe 订阅此Client所有请求的事件,返回取消订阅的函数
+func (c *Client) Subscribe(typ EventType, handler EventHandler) func() {
+	return c.bus.Subscribe(typ, handler)
+}
+
+// publish 先通知Client的订阅者,再通知请求的订阅者
+func (c *Client) publish(o *Options, ev *Event) {
+	c.bus.Publish(ev)
+	for _, b := range o.EventBuses {
+		b.Publish(ev)
+	}
 }
 
 func (c *Client) Get(url string, result interface{}, opts ...Option) (*Response, error) {
@@ -58,102 +231,623 @@ func (c *Client) Put(url string, req interface{}, result interface{}, opts ...Op
 	return c.DoRequest(http.MethodPut, url, req, result, opts...)
 }
 
+// Options 返回合并默认参数和opts后的Options,用于ws等扩展包读取参数
+func (c *Client) Options(opts ...Option) *Options {
+	return c.buildOptions(opts...)
+}
+
+// buildOptions 合并Client默认参数和请求参数
+func (c *Client) buildOptions(opts ...Option) *Options {
+	all := make([]Option, 0, len(c.opts)+len(opts))
+	all = append(all, c.opts...)
+	all = append(all, opts...)
+
+	o := &Options{}
+	o.build(all...)
+	return o
+}
+
 // DoRequest 执行
 func (c *Client) DoRequest(method string, url string, reqBody interface{}, result interface{}, opts ...Option) (*Response, error) {
-	o := &Options{}
-	o.build(opts...)
+	o := c.buildOptions(opts...)
+
+	if o.QuietHours != nil && o.NonUrgent {
+		if err := o.QuietHours.wait(o.Context); err != nil {
+			return nil, err
+		}
+	}
 
 	// build url
-	if !strings.HasPrefix(url, "http") {
-		if o.BaseURL != "" {
-			url = path.Join(o.BaseURL, url)
-		} else if c.baseURL != "" {
-			url = path.Join(o.BaseURL, url)
+	isAbs := strings.HasPrefix(url, "http://") || strings.HasPrefix(url, "https://")
+	if o.BaseURL != "" && !isAbs && o.Balancer == nil {
+		url = joinURL(o.BaseURL, url)
+	}
+
+	route := o.RouteName
+	if len(o.PathParams) > 0 {
+		if route == "" {
+			route = routePath(url)
 		}
+		url = expandPath(url, o.PathParams)
+	}
+
+	// 按host重新生成参数,host的参数在默认参数之后,请求参数之前
+	if hostOpts := o.HostOptions.lookup(url); len(hostOpts) > 0 {
+		all := make([]Option, 0, len(hostOpts)+len(opts))
+		all = append(all, hostOpts...)
+		all = append(all, opts...)
+		o = c.buildOptions(all...)
 	}
 
-	body, err := encode(o.ContentType, reqBody)
+	// 多个BaseURL时,每次执行前请求构建完成后通过Balancer选择
+	balance := o.Balancer != nil && !isAbs
+	relative := url
+
+	// 用户指定的Content-Type中带有charset时,按照此charset编码
+	if cs := parseCharset(o.Header.Get("Content-Type")); cs != "" {
+		o.Charset = cs
+	}
+
+	var contentType string
+	var body []byte
+	var err error
+	var mapped *mappedFile
+	var file *fileBody
+	bodyFile := o.BodyFile
+	if path, ok := reqBody.(BodyFromFile); ok {
+		bodyFile = string(path)
+	}
+	if bodyFile != "" {
+		if file, err = openFileBody(bodyFile); err != nil {
+			return nil, err
+		}
+		contentType = o.ContentType
+		if contentType == "" {
+			contentType = fileContentType(bodyFile)
+		}
+	} else if o.MmapBody != "" {
+		if mapped, err = openMapped(o.MmapBody); err != nil {
+			return nil, err
+		}
+		// 发送中的body持有各自的引用
+		defer mapped.release()
+		body = mapped.data
+		contentType = o.ContentType
+		if contentType == "" {
+			contentType = TypeOctetStream
+		}
+	} else if form, ok := reqBody.(*Form); ok {
+		body, contentType, err = o.encodeMultipart(form)
+	} else {
+		contentType = o.getContentType(reqBody, result)
+		body, err = o.encode(contentType, reqBody)
+		if err == nil && body != nil && o.CSRF != nil && contentType == TypeForm {
+			body, err = o.CSRF.encodeField(method, body)
+		}
+	}
 	if err != nil {
 		return nil, err
 	}
 
+	// 压缩前的body,用于DeadLetter
+	encoded := body
+	if body != nil && o.RequestEncoding != "" {
+		body, err = compress(o.RequestEncoding, body)
+		if err != nil {
+			return nil, err
+		}
+	}
+
 	req, err := http.NewRequestWithContext(o.Context, method, url, nil)
 	if err != nil {
 		return nil, err
 	}
 
 	if len(o.Header) > 0 {
-		req.Header = o.Header
+		// 之后会设置Content-Type等header,不修改Options中的map
+		req.Header = o.Header.Clone()
+	}
+
+	if (body != nil || file != nil) && req.Header.Get("Content-Type") == "" {
+		req.Header.Set("Content-Type", withCharset(contentType, o.Charset))
+	}
+
+	if len(o.Compression) > 0 && req.Header.Get("Accept-Encoding") == "" {
+		req.Header.Set("Accept-Encoding", strings.Join(o.Compression, ", "))
+	}
+
+	if o.CompressionInfo != nil && req.Header.Get("Accept-Encoding") == "" {
+		// 由标准库解压时无法得到压缩前的大小
+		req.Header.Set("Accept-Encoding", EncodingGzip)
+	}
+
+	if result != nil && req.Header.Get("Accept") == "" {
+		if accept := o.getAccept(contentType); accept != "" {
+			req.Header.Set("Accept", accept)
+		}
+	}
+
+	if o.HostHeader != "" {
+		req.Host = o.HostHeader
 	}
 
-	if len(o.Query) > 0 {
-		req.URL.RawQuery = o.toRawQuery(req.URL.Query())
+	o.setUserAgent(req)
+	o.setIdempotencyKey(req)
+	requestID := o.setRequestID(req)
+
+	if o.APIVersion != "" {
+		o.setVersion(req, contentType)
+	}
+
+	if len(o.Query) > 0 || len(o.QueryParams) > 0 {
+		rawQuery, err := o.toRawQuery(req.URL.Query())
+		if err != nil {
+			return nil, err
+		}
+		req.URL.RawQuery = rawQuery
 	}
 
 	addCookies(req, o.Cookies)
 
-	ev := &Event{Req: req, Datas: o.Datas}
-	hooks := append(o.Hooks, c.hooks...)
+	return c.execute(&call{
+		o:           o,
+		req:         req,
+		result:      result,
+		route:       route,
+		contentType: contentType,
+		body:        body,
+		encoded:     encoded,
+		mapped:      mapped,
+		file:        file,
+		balance:     balance,
+		relative:    relative,
+		requestID:   requestID,
+	})
+}
+
+// Do 使用调用者创建的请求执行,同样经过hook,重试和解码,用于Options无法表达的情况,如trailer
+// 使用req的Context,opts中构建请求的参数如BaseURL,Query,Header和请求编码不生效,req不会被修改
+func (c *Client) Do(req *Request, result interface{}, opts ...Option) (*Response, error) {
+	o := c.buildOptions(opts...)
+	o.Context = req.Context()
+
+	if o.QuietHours != nil && o.NonUrgent {
+		if err := o.QuietHours.wait(o.Context); err != nil {
+			return nil, err
+		}
+	}
+
+	req = req.Clone(o.Context)
+	if req.Body != nil && req.Body != http.NoBody && req.GetBody == nil {
+		// 重试时需要重新发送
+		data, err := ioutil.ReadAll(req.Body)
+		req.Body.Close()
+		if err != nil {
+			return nil, err
+		}
+		req.Body = ioutil.NopCloser(bytes.NewReader(data))
+		req.GetBody = func() (io.ReadCloser, error) {
+			return ioutil.NopCloser(bytes.NewReader(data)), nil
+		}
+	}
+
+	o.setUserAgent(req)
+	o.setIdempotencyKey(req)
+	requestID := o.setRequestID(req)
+
+	contentType := parseContentType(req.Header.Get("Content-Type"))
+	if contentType == "" {
+		contentType = defaultContentType
+	}
+
+	return c.execute(&call{o: o, req: req, result: result, route: o.RouteName, contentType: contentType, requestID: requestID})
+}
+
+// call 一次DoRequest或Do的执行状态
+type call struct {
+	o           *Options
+	req         *Request
+	result      interface{}
+	route       string
+	contentType string      // 请求的编码格式,应答中没有Content-Type时用于解码
+	body        []byte      // 发送的body,可能已经压缩,为nil时使用req中的body
+	encoded     []byte      // 压缩前的body
+	mapped      *mappedFile // WithMmapBody时的文件映射
+	file        *fileBody   // WithBodyFile时的文件,每次执行重新打开
+	balance     bool        // 是否通过Balancer选择BaseURL
+	relative    string      // 相对BaseURL的地址
+	requestID   string      // WithRequestID时的请求ID
+}
+
+// execute 设置OverallTimeout时,整个执行过程包括重试和等待都在deadline内
+func (c *Client) execute(cl *call) (*Response, error) {
+	if cl.o.OverallTimeout <= 0 {
+		return c.run(cl)
+	}
+
+	ctx, cancel := context.WithTimeout(cl.o.Context, cl.o.OverallTimeout)
+	cl.o.Context = ctx
+	cl.req = cl.req.WithContext(ctx)
+	rsp, err := c.run(cl)
+	if err != nil || rsp == nil {
+		cancel()
+		return rsp, err
+	}
+
+	// body可能还未读取
+	rsp.Body = &cancelBody{ReadCloser: rsp.Body, cancel: cancel}
+	return rsp, nil
+}
+
+// run 执行hook,重试和解码
+func (c *Client) run(cl *call) (*Response, error) {
+	o, req, result := cl.o, cl.req, cl.result
+	body, encoded, mapped, file, contentType := cl.body, cl.encoded, cl.mapped, cl.file, cl.contentType
+	balance, relative := cl.balance, cl.relative
+	method := req.Method
+	var err error
+
+	// 选择BaseURL后所有的路径都需要调用Done,否则LeastPendingBalancer的计数不会减少
+	baseURL := ""
+	picked := false
+	done := func(err error) {
+		if picked {
+			picked = false
+			o.Balancer.Done(baseURL, err)
+		}
+	}
+	// 没有发送时不认为是BaseURL的问题
+	defer done(context.Canceled)
+
+	ev := &Event{Req: req, Route: cl.route, ReqID: cl.requestID, Datas: o.Datas}
+	hooks := getHooks(o.Hooks)
+	start := time.Now()
+	if o.RequestInfo != nil {
+		*o.RequestInfo = RequestInfo{redact: o.Redact}
+	}
 
 	for i := 0; ; i++ {
-		if body != nil {
-			req.Body = ioutil.NopCloser(bytes.NewReader(body))
+		// 服务器曾经拒绝过压缩的body时,直接发送原始数据
+		data := body
+		compressed := body != nil && o.RequestEncoding != ""
+		if compressed && c.rejectsCompression(req.URL.Host) {
+			data = encoded
+			compressed = false
+		}
+		if compressed {
+			req.Header.Set("Content-Encoding", o.RequestEncoding)
+		} else if o.RequestEncoding != "" {
+			req.Header.Del("Content-Encoding")
+		}
+
+		if file != nil {
+			if req.Body, err = file.open(); err != nil {
+				return nil, err
+			}
+			req.GetBody = file.open
+			req.ContentLength = file.size
+			if o.Progress != nil {
+				req.Body = newProgressReader(req.Body, req.ContentLength, o.Progress)
+			}
+		} else if data != nil {
+			if mapped != nil && !compressed {
+				req.Body = mapped.reader()
+				req.GetBody = func() (io.ReadCloser, error) {
+					return mapped.reader(), nil
+				}
+			} else {
+				req.Body = ioutil.NopCloser(bytes.NewReader(data))
+				req.GetBody = func() (io.ReadCloser, error) {
+					return ioutil.NopCloser(bytes.NewReader(data)), nil
+				}
+			}
+			req.ContentLength = int64(len(data))
+			if o.Progress != nil {
+				req.Body = newProgressReader(req.Body, req.ContentLength, o.Progress)
+			}
+		} else if i > 0 && req.GetBody != nil {
+			// Do传入的请求,重试时重新获取body
+			if req.Body, err = req.GetBody(); err != nil {
+				return nil, err
+			}
 		}
 
+		cancel := context.CancelFunc(func() {})
 		if o.Timeout > 0 {
-			ctx, _ := context.WithTimeout(o.Context, o.Timeout)
+			var ctx context.Context
+			ctx, cancel = context.WithTimeout(o.Context, o.Timeout)
 			req = req.WithContext(ctx)
 		}
+		if o.MaxResponseBytes > 0 && !o.RawResponse {
+			req = req.WithContext(context.WithValue(req.Context(), maxBytesKey{}, o.MaxResponseBytes))
+		}
+
+		// 每次执行都重新获取,token可能在重试期间过期
+		if o.AuthProvider != nil {
+			auth, err := o.AuthProvider(req.Context())
+			if err != nil {
+				cancel()
+				return nil, err
+			}
+			req.Header.Set("Authorization", authorization(auth))
+		}
 
+		if balance {
+			if baseURL, err = o.Balancer.Next(); err != nil {
+				cancel()
+				return nil, err
+			}
+			picked = true
+			u, err := req.URL.Parse(joinURL(baseURL, relative))
+			if err != nil {
+				cancel()
+				return nil, err
+			}
+			u.RawQuery = req.URL.RawQuery
+			req.URL = u
+			if o.HostHeader == "" {
+				req.Host = u.Host
+			}
+		}
+
+		ev.Req = req
 		ev.SetPrev(i)
 		if err := hooks.Run(ev); err != nil {
+			done(err)
+			cancel()
 			return nil, err
 		}
+		c.publish(o, ev)
+
+		if o.Limiter != nil {
+			release, err := o.Limiter.acquire(req.Context(), req.URL.Host)
+			if err != nil {
+				done(err)
+				cancel()
+				return nil, err
+			}
+			// 与context一起在body关闭时释放
+			prev := cancel
+			cancel = func() {
+				prev()
+				release()
+			}
+		}
+
+		breakerKey := ""
+		if o.Breaker != nil {
+			breakerKey = o.Breaker.key(req, o.BreakerKey)
+			if err := o.Breaker.allow(breakerKey); err != nil {
+				done(err)
+				cancel()
+				return nil, err
+			}
+		}
+
+		send := func(req *Request) (*Response, error) {
+			if o.HedgeMax > 1 && isIdempotent(method) {
+				return c.hedge(req, o.HedgeDelay, o.HedgeMax)
+			} else if o.Singleflight != nil {
+				return c.doShared(req, o.Singleflight)
+			} else if !o.DisableStaleRetry {
+				return c.doStale(req)
+			}
+			return c.do(req)
+		}
 
-		rsp, err := c.client.Do(req)
+		var rsp *Response
+		cache := ""
+		if o.Cache != nil {
+			rsp, cache, err = doCache(o.Cache, req, o.RawResponse, send)
+		} else if o.Conditional != nil {
+			rsp, cache, err = doConditional(o.Conditional, req, o.RawResponse, send)
+		} else {
+			rsp, err = send(req)
+		}
+		err = classifyErr(err)
+		if ne, ok := err.(*NetError); ok {
+			ne.ReqID = cl.requestID
+		}
+		done(err)
+		if o.Breaker != nil {
+			o.Breaker.report(breakerKey, o.Breaker.isFailure(rsp, err))
+		}
+		if err == nil && !o.RawResponse {
+			if err = decompressResponse(rsp, o.CompressionInfo); err != nil {
+				rsp.Body.Close()
+				rsp = nil
+			}
+		}
 		ev.SetPost(rsp, err)
+		ev.Cache = cache
+		if o.RequestInfo != nil {
+			o.RequestInfo.add(ev)
+		}
 		if err := hooks.Run(ev); err != nil {
+			if rsp != nil {
+				rsp.Body.Close()
+			}
+			cancel()
 			return nil, err
 		}
+		c.publish(o, ev)
+
+		if err == nil && compressed && isCompressionRejected(rsp.StatusCode) {
+			// 记录后使用原始数据重新发送,不计入重试次数
+			c.noCompression.Store(req.URL.Host, true)
+			rsp.Body.Close()
+			cancel()
+			i--
+			continue
+		}
 
-		if err == nil {
-			if rsp.StatusCode != http.StatusOK {
-				return nil, &StatusErr{Code: rsp.StatusCode, Info: rsp.Status}
+		var rspBody []byte
+		if err == nil && o.RawResponse {
+			// 只在关闭时释放context,不读取也不替换body
+			rsp.Body = &cancelBody{ReadCloser: rsp.Body, cancel: cancel}
+		} else if err == nil {
+			if o.MaxResponseBytes > 0 && rsp.ContentLength > o.MaxResponseBytes {
+				// 未读取完就关闭,连接不会被复用
+				rsp.Body.Close()
+				cancel()
+				return nil, ErrResponseTooLarge
 			}
 
-			if result != nil {
-				// decode result
-				contentType := o.ContentType
-				if val := rsp.Header.Get("Content-Type"); len(val) != 0 {
-					contentType = parseContentType(val)
+			// body读取完毕后才能释放context
+			rsp.Body = &cancelBody{ReadCloser: rsp.Body, cancel: cancel}
+			if o.MaxResponseBytes > 0 {
+				rsp.Body = &limitBody{ReadCloser: rsp.Body, remain: o.MaxResponseBytes}
+			}
+			if o.Progress != nil {
+				rsp.Body = newProgressReader(rsp.Body, rsp.ContentLength, o.Progress)
+			}
+			if len(o.RetryConditions) > 0 {
+				// 重试条件需要检查body
+				rspBody, err = readBody(rsp)
+			}
+		} else {
+			cancel()
+		}
+
+		// 有多个BaseURL时,连接失败也重试,下次会选择其他BaseURL
+		retryable := o.shouldRetry(req, rsp, rspBody, err) || (balance && isConnErr(err))
+		retry := retryable && i < o.Retry
+		var wait time.Duration
+		if retry {
+			wait = o.retryWait(rsp)
+			if o.MaxElapsedTime > 0 && time.Since(start)+wait > o.MaxElapsedTime {
+				retry = false
+			}
+		}
+		exhausted := retry && o.RetryBudget != nil && !o.RetryBudget.allow()
+		if exhausted {
+			retry = false
+			// 只是通知,不经过Hook,避免Hook返回的错误被忽略
+			ev.Type = EventRetryBudget
+			c.publish(o, ev)
+		}
+
+		if retryable && !retry && o.DeadLetter != nil {
+			dlBody := encoded
+			if mapped != nil {
+				// 返回后会解除映射
+				dlBody = append([]byte(nil), encoded...)
+			}
+			o.DeadLetter(newDeadLetter(req, dlBody, i+1, rsp, err, o.Datas))
+		}
+
+		if retry {
+			if rsp != nil {
+				rsp.Body.Close()
+			}
+
+			select {
+			case <-o.Context.Done():
+				return nil, classifyErr(o.Context.Err())
+			case <-time.After(wait):
+			}
+			continue
+		}
+
+		if err != nil {
+			if exhausted {
+				return nil, fmt.Errorf("%w: %w", ErrRetryBudget, err)
+			}
+			return nil, err
+		}
+
+		if rsp.StatusCode < 200 || rsp.StatusCode >= 300 {
+			if r := o.resultFor(rsp.StatusCode); r != nil {
+				o.decodeStatusResult(rsp, contentType, r)
+			}
+			se := newStatusErr(rsp, i+1)
+			se.ReqID = cl.requestID
+			if exhausted {
+				return nil, fmt.Errorf("%w: %w", ErrRetryBudget, se)
+			}
+			return nil, se
+		}
+
+		if o.APIVersion != "" {
+			if err := o.checkVersion(rsp); err != nil {
+				rsp.Body.Close()
+				return nil, err
+			}
+		}
+
+		if o.RawResponse {
+			return rsp, nil
+		}
+
+		if r := o.resultFor(rsp.StatusCode); r != nil {
+			result = r
+		}
+
+		if result != nil {
+			// decode result
+			rspType := contentType
+			charset := o.Charset
+			if val := rsp.Header.Get("Content-Type"); len(val) != 0 {
+				rspType = parseContentType(val)
+				if cs := parseCharset(val); cs != "" {
+					charset = cs
 				}
-				rspBody, err := ioutil.ReadAll(rsp.Body)
+				if d, ok := result.(*DynamicProto); ok && d.Name == "" {
+					d.Name = protoMessageName(val)
+				}
+			}
+
+			if err := o.checkMediaType(rspType, req.Header.Get("Accept"), result); err != nil {
+				rsp.Body.Close()
+				return nil, err
+			}
+
+			// 有hook或重试时走通用流程,保持原有的读取和解码行为
+			if rspBody == nil && o.SchemaWarning == nil && len(hooks) == 0 && o.Retry == 0 {
+				needCharset := isTextType(rspType) && !isUTF8(charset)
+				if ok, err := fastDecode(rsp, result, needCharset); ok {
+					if err != nil {
+						return nil, err
+					}
+					return rsp, nil
+				}
+			}
+
+			if rspBody == nil {
+				rspBody, err = readBody(rsp)
 				if err != nil {
-					rsp.Body.Close()
 					return nil, err
 				}
-				rsp.Body.Close()
-				rsp.Body = ioutil.NopCloser(bytes.NewBuffer(body))
+			}
 
-				if err := decode(contentType, rspBody, result); err != nil {
+			if _, raw := result.(*[]byte); !raw && isTextType(rspType) {
+				rspBody, err = decodeCharset(rspBody, charset)
+				if err != nil {
 					return nil, err
 				}
 			}
 
-			return rsp, nil
-		} else if isTimeoutErr(err) && i < o.Retry {
-			wait := o.Backoff.Next()
-			for {
-				select {
-				case <-req.Context().Done():
-					return nil, req.Context().Err()
-				case <-time.After(wait):
-					break
+			if lazy, ok := result.(lazyResult); ok {
+				data := rspBody
+				lazy.setLazy(data, func(v interface{}) error {
+					return o.decode(rspType, data, v)
+				})
+				return rsp, nil
+			}
+
+			if err := o.decode(rspType, rspBody, result); err != nil {
+				return nil, err
+			}
+
+			if o.SchemaWarning != nil && rspType == TypeJSON {
+				if w := checkSchema(rspBody, result); w != nil {
+					w.URL = req.URL.String()
+					o.SchemaWarning(w)
 				}
 			}
-		} else {
-			return nil, err
 		}
+
+		return rsp, nil
 	}
 }
 
@@ -166,18 +860,60 @@ func isTimeoutErr(err error) bool {
 	return false
 }
 
-// StatusErr 当Response返回状态非200时,返回此错误
+// MaxStatusErrBody StatusErr中最多保存的body字节数
+var MaxStatusErrBody = 4 << 10
+
+// StatusErr 当Response返回状态非2xx时,返回此错误
 type StatusErr struct {
-	Code int    `json:"code"`
-	Info string `json:"info"`
+	Code     int         `json:"code"`
+	Info     string      `json:"info"`
+	Method   string      `json:"method,omitempty"`
+	URL      string      `json:"url,omitempty"`
+	Attempts int         `json:"attempts,omitempty"`   // 执行次数,包括重试
+	Header   http.Header `json:"header,omitempty"`     // 应答头
+	Body     []byte      `json:"body,omitempty"`       // 应答body,最多MaxStatusErrBody字节
+	ReqID    string      `json:"request_id,omitempty"` // WithRequestID时的请求ID
+}
+
+// newStatusErr 读取部分body后关闭
+func newStatusErr(rsp *Response, attempts int) *StatusErr {
+	se := &StatusErr{Code: rsp.StatusCode, Info: rsp.Status, Attempts: attempts, Header: rsp.Header}
+	if rsp.Request != nil {
+		se.Method = rsp.Request.Method
+		se.URL = rsp.Request.URL.String()
+	}
+
+	if MaxStatusErrBody > 0 {
+		se.Body, _ = ioutil.ReadAll(io.LimitReader(rsp.Body, int64(MaxStatusErrBody)))
+	}
+	rsp.Body.Close()
+	return se
 }
 
 func (se *StatusErr) Error() string {
-	return fmt.Sprintf("invalid http status,code=%+v, info=%+v", se.Code, se.Info)
+	msg := fmt.Sprintf("invalid http status,code=%+v, info=%+v", se.Code, se.Info)
+	if se.URL != "" {
+		msg += fmt.Sprintf(", method=%s, url=%s, attempts=%d", se.Method, se.URL, se.Attempts)
+	}
+	if se.ReqID != "" {
+		msg += ", request_id=" + se.ReqID
+	}
+	if len(se.Body) > 0 {
+		msg += fmt.Sprintf(", body=%q", se.Body)
+	}
+
+	return msg
 }
 
 // IsStatusErr 判断是否是StatusErr错误
 func IsStatusErr(e error) bool {
-	_, ok := e.(*StatusErr)
+	_, ok := AsStatusErr(e)
 	return ok
 }
+
+// AsStatusErr 获取错误链中的StatusErr
+func AsStatusErr(e error) (*StatusErr, bool) {
+	var se *StatusErr
+	ok := errors.As(e, &se)
+	return se, ok
+}
@@ -1,7 +1,37 @@
 package ghttp
 
 import (
+	"bytes"
+	"compress/gzip"
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"crypto/tls"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/json"
+	"encoding/pem"
+	"errors"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"sync"
+	"sync/atomic"
 	"testing"
+	"time"
+
+	"golang.org/x/net/dns/dnsmessage"
+	"golang.org/x/net/http2"
+	"golang.org/x/net/http2/h2c"
 )
 
 func TestClient(t *testing.T) {
@@ -13,3 +43,1318 @@ func TestClient(t *testing.T) {
 		t.Log(text)
 	}
 }
+
+func TestH2C(t *testing.T) {
+	handler := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", TypeText)
+		w.Write([]byte(r.Proto))
+	})
+	srv := httptest.NewServer(h2c.NewHandler(handler, &http2.Server{}))
+	defer srv.Close()
+
+	var proto string
+	if _, err := NewClient(WithH2C()).Get(srv.URL, &proto); err != nil {
+		t.Fatal(err)
+	}
+	if proto != "HTTP/2.0" {
+		t.Errorf("unexpected proto: %v", proto)
+	}
+}
+
+func TestDialer(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", TypeText)
+		w.Write([]byte(r.RemoteAddr))
+	}))
+	defer srv.Close()
+
+	var addr string
+	if _, err := NewClient(WithLocalAddr(net.ParseIP("127.0.0.1"))).Get(srv.URL, &addr); err != nil {
+		t.Fatal(err)
+	}
+	if !strings.HasPrefix(addr, "127.0.0.1:") {
+		t.Errorf("unexpected remote addr: %v", addr)
+	}
+
+	dials := 0
+	dialer := &net.Dialer{}
+	c := NewClient(WithDialContext(func(ctx context.Context, network, addr string) (net.Conn, error) {
+		dials++
+		return dialer.DialContext(ctx, network, addr)
+	}))
+	if _, err := c.Get(srv.URL, &addr); err != nil || dials != 1 {
+		t.Errorf("custom dial not used: %v, %v", dials, err)
+	}
+}
+
+type hostsResolver struct {
+	hosts map[string][]string
+	calls int
+}
+
+func (r *hostsResolver) LookupHost(ctx context.Context, host string) ([]string, error) {
+	r.calls++
+	if addrs, ok := r.hosts[host]; ok {
+		return addrs, nil
+	}
+	return nil, &net.DNSError{Err: "no such host", Name: host, IsNotFound: true}
+}
+
+func TestDNSCache(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", TypeText)
+		w.Write([]byte(r.Host))
+	}))
+	defer srv.Close()
+
+	_, port, _ := net.SplitHostPort(srv.Listener.Addr().String())
+	resolver := &hostsResolver{hosts: map[string][]string{"example.test": {"127.0.0.1"}}}
+	c := NewClient(WithDNSCache(NewDNSCache(resolver, time.Minute)), WithDisableKeepAlives(true))
+
+	for i := 0; i < 2; i++ {
+		var host string
+		if _, err := c.Get("http://example.test:"+port, &host); err != nil {
+			t.Fatal(err)
+		}
+		if host != "example.test:"+port {
+			t.Errorf("unexpected host: %v", host)
+		}
+	}
+
+	if resolver.calls != 1 {
+		t.Errorf("expect 1 lookup, got %v", resolver.calls)
+	}
+}
+
+func TestTLSKeyLog(t *testing.T) {
+	srv := httptest.NewTLSServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte("ok"))
+	}))
+	defer srv.Close()
+
+	keylog := &bytes.Buffer{}
+	c := NewClient(WithTLSKeyLogWriter(keylog))
+	// 测试证书是自签名的
+	c.client.Transport.(*http.Transport).TLSClientConfig.InsecureSkipVerify = true
+	if _, err := c.Get(srv.URL, nil); err != nil {
+		t.Fatal(err)
+	}
+	if !strings.Contains(keylog.String(), "CLIENT_") {
+		t.Errorf("unexpected key log: %q", keylog.String())
+	}
+}
+
+func TestDoH(t *testing.T) {
+	doh := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		data, _ := base64.RawURLEncoding.DecodeString(r.URL.Query().Get("dns"))
+		var msg dnsmessage.Message
+		if err := msg.Unpack(data); err != nil {
+			w.WriteHeader(http.StatusBadRequest)
+			return
+		}
+
+		msg.Header.Response = true
+		q := msg.Questions[0]
+		if q.Type == dnsmessage.TypeA && q.Name.String() == "example.test." {
+			msg.Answers = append(msg.Answers, dnsmessage.Resource{
+				Header: dnsmessage.ResourceHeader{Name: q.Name, Type: q.Type, Class: q.Class, TTL: 60},
+				Body:   &dnsmessage.AResource{A: [4]byte{127, 0, 0, 1}},
+			})
+		}
+		reply, _ := msg.Pack()
+		w.Header().Set("Content-Type", typeDNSMessage)
+		w.Write(reply)
+	}))
+	defer doh.Close()
+
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte("ok"))
+	}))
+	defer srv.Close()
+
+	_, port, _ := net.SplitHostPort(srv.Listener.Addr().String())
+	var text []byte
+	if _, err := NewClient(WithDoH(doh.URL)).Get("http://example.test:"+port, &text); err != nil || string(text) != "ok" {
+		t.Fatalf("unexpected result: %s, %v", text, err)
+	}
+
+	if _, err := NewDoHResolver(doh.URL).LookupHost(context.Background(), "missing.test"); err == nil {
+		t.Error("expect lookup error")
+	}
+}
+
+func TestMultipartForm(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		mr, err := r.MultipartReader()
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		var parts []string
+		for {
+			p, err := mr.NextPart()
+			if err != nil {
+				break
+			}
+			data, _ := ioutil.ReadAll(p)
+			parts = append(parts, p.FormName()+"/"+p.FileName()+"="+string(data))
+		}
+		w.Write([]byte(strings.Join(parts, ",")))
+	}))
+	defer srv.Close()
+
+	path := filepath.Join(t.TempDir(), "a.txt")
+	if err := ioutil.WriteFile(path, []byte("hello"), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	fields := url.Values{"name": {"ghttp"}, "age": {"1"}}
+	form := NewForm(fields).AddFile("file", path).AddFileData("raw", "b.bin", []byte{'x'})
+	var text string
+	if _, err := NewClient().Post(srv.URL, form, &text); err != nil {
+		t.Fatal(err)
+	}
+	if expect := "age/=1,name/=ghttp,file/a.txt=hello,raw/b.bin=x"; text != expect {
+		t.Errorf("unexpected parts: %s", text)
+	}
+}
+
+func TestAuthProvider(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte(r.Header.Get("Authorization")))
+	}))
+	defer srv.Close()
+
+	var text string
+	if _, err := NewClient(WithBearAuth("static")).Get(srv.URL, &text); err != nil || text != "Bearer static" {
+		t.Errorf("unexpected bearer auth: %s, %v", text, err)
+	}
+
+	n := 0
+	provider := func(ctx context.Context) (string, error) {
+		n++
+		if n == 3 {
+			return "", context.Canceled
+		}
+		if n == 2 {
+			return "Basic abc", nil
+		}
+		return "token1", nil
+	}
+	c := NewClient(WithBearAuth("static"), WithAuthProvider(provider))
+	for _, expect := range []string{"Bearer token1", "Basic abc"} {
+		if _, err := c.Get(srv.URL, &text); err != nil || text != expect {
+			t.Errorf("unexpected auth: %s, %v", text, err)
+		}
+	}
+	if _, err := c.Get(srv.URL, &text); err != context.Canceled {
+		t.Errorf("expect provider error, got %v", err)
+	}
+}
+
+func TestHMACSigner(t *testing.T) {
+	secret := []byte("secret")
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := ioutil.ReadAll(r.Body)
+		sum := sha256.Sum256(body)
+		canonical := r.Method + "\n" + r.URL.Path + "?" + r.URL.Query().Encode() + "\n" +
+			"x-app:demo\n" + "x-timestamp:" + r.Header.Get("X-Timestamp") + "\n" + hex.EncodeToString(sum[:])
+		mac := hmac.New(sha256.New, secret)
+		mac.Write([]byte(canonical))
+		if r.Header.Get("X-Sign") != "sha256="+hex.EncodeToString(mac.Sum(nil)) {
+			w.WriteHeader(http.StatusUnauthorized)
+		}
+	}))
+	defer srv.Close()
+
+	s := NewHMACSigner(secret, "X-Sign", "X-App")
+	s.Prefix = "sha256="
+	s.Timestamp = "X-Timestamp"
+	opts := []Option{WithHeader("X-App", "demo"), WithQuery("b", "2"), WithQuery("a", "1")}
+	if _, err := NewClient(WithHMACSigner(s)).Post(srv.URL+"/hook", map[string]int{"id": 1}, nil, opts...); err != nil {
+		t.Error(err)
+	}
+
+	RegisterMiddleware(s.Middleware)
+	defer func() { global.middlewares = nil }()
+	if _, err := NewClient().Post(srv.URL+"/hook", map[string]int{"id": 1}, nil, opts...); err != nil {
+		t.Error(err)
+	}
+}
+
+func TestAPIVersion(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("X-Echo-Accept", r.Header.Get("Accept"))
+		if r.URL.Path != "/silent" {
+			w.Header().Set("X-API-Version", "2")
+		}
+	}))
+	defer srv.Close()
+
+	c := NewClient()
+	if _, err := c.Get(srv.URL, nil, WithAPIVersion("2")); err != nil {
+		t.Error(err)
+	}
+	_, err := c.Get(srv.URL, nil, WithAPIVersion("3"))
+	if e, ok := err.(*VersionMismatchError); !ok || e.Expected != "3" || e.Actual != "2" || !IsVersionMismatch(err) {
+		t.Errorf("expect version mismatch, got %v", err)
+	}
+
+	if _, err := c.Get(srv.URL+"/silent", nil, WithAPIVersion("3")); err != nil {
+		t.Error(err)
+	}
+	strict := &VersionPolicy{AcceptParam: "version", Strict: true}
+	if _, err := c.Get(srv.URL+"/silent", nil, WithAPIVersion("3"), WithVersionPolicy(strict)); !IsVersionMismatch(err) {
+		t.Errorf("expect version mismatch in strict mode, got %v", err)
+	}
+
+	rsp, err := c.Get(srv.URL, nil, WithAPIVersion("2"), WithVersionPolicy(strict))
+	if err != nil || rsp.Header.Get("X-Echo-Accept") != TypeJSON+"; version=2" {
+		t.Errorf("unexpected accept: %v, %v", rsp, err)
+	}
+}
+
+func TestUserAgent(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte(r.UserAgent()))
+	}))
+	defer srv.Close()
+
+	cases := []struct {
+		client []Option
+		opts   []Option
+		expect string
+	}{
+		{nil, nil, DefaultUserAgent},
+		{[]Option{WithUserAgent("app/1.0")}, nil, "app/1.0"},
+		{[]Option{WithUserAgent("app/1.0")}, []Option{WithUserAgent("job/2.0")}, "job/2.0"},
+		{nil, []Option{WithHeader("User-Agent", "custom")}, "custom"},
+	}
+	for _, cs := range cases {
+		var ua string
+		if _, err := NewClient(cs.client...).Get(srv.URL, &ua, cs.opts...); err != nil || ua != cs.expect {
+			t.Errorf("unexpected user agent: %s, expect %s, %v", ua, cs.expect, err)
+		}
+	}
+}
+
+func TestHostHeader(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte(r.Host))
+	}))
+	defer srv.Close()
+
+	var host string
+	if _, err := NewClient().Get(srv.URL, &host, WithHostHeader("api.example.com")); err != nil || host != "api.example.com" {
+		t.Errorf("unexpected host: %s, %v", host, err)
+	}
+
+	sni := make(chan string, 1)
+	tsrv := httptest.NewUnstartedServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+	tsrv.TLS = &tls.Config{GetConfigForClient: func(hello *tls.ClientHelloInfo) (*tls.Config, error) {
+		sni <- hello.ServerName
+		return nil, nil
+	}}
+	tsrv.StartTLS()
+	defer tsrv.Close()
+
+	// 证书不受信任,只检查发送的SNI
+	NewClient(WithHostHeader("example.com:443"), WithRetry(0)).Get(tsrv.URL, nil)
+	if name := <-sni; name != "example.com" {
+		t.Errorf("unexpected sni: %s", name)
+	}
+	NewClient(WithHostHeader("example.com"), WithServerName("sni.example.com"), WithRetry(0)).Get(tsrv.URL, nil)
+	if name := <-sni; name != "sni.example.com" {
+		t.Errorf("unexpected sni: %s", name)
+	}
+}
+
+func TestDo(t *testing.T) {
+	var calls int32
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := ioutil.ReadAll(r.Body)
+		if atomic.AddInt32(&calls, 1) == 1 {
+			w.WriteHeader(http.StatusServiceUnavailable)
+			return
+		}
+		w.Header().Set("Content-Type", TypeJSON)
+		fmt.Fprintf(w, `{"body":%q,"trailer":%q}`, body, r.Trailer.Get("X-Checksum"))
+	}))
+	defer srv.Close()
+
+	req, _ := http.NewRequest(http.MethodPost, srv.URL, strings.NewReader("payload"))
+	req.ContentLength = -1
+	req.Trailer = http.Header{"X-Checksum": {"abc"}}
+
+	var result struct {
+		Body    string `json:"body"`
+		Trailer string `json:"trailer"`
+	}
+	if _, err := NewClient().Do(req, &result, WithRetry(1), WithBackoff(nil), WithRetryCondition(func(rsp *Response, body []byte, err error) bool {
+		return rsp != nil && rsp.StatusCode == http.StatusServiceUnavailable
+	})); err != nil {
+		t.Fatal(err)
+	}
+	if result.Body != "payload" || result.Trailer != "abc" || calls != 2 {
+		t.Errorf("unexpected result: %+v, calls=%d", result, calls)
+	}
+}
+
+func TestStatusErr(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("X-Request-Id", "42")
+		w.WriteHeader(http.StatusBadRequest)
+		w.Write([]byte(`{"error":"invalid name"}`))
+		w.Write(bytes.Repeat([]byte(" "), 10000))
+	}))
+	defer srv.Close()
+
+	_, err := NewClient().Post(srv.URL+"/users", nil, nil)
+	se, ok := AsStatusErr(&ChainError{Err: err})
+	if !ok {
+		t.Fatalf("expect StatusErr, got %v", err)
+	}
+	if se.Code != http.StatusBadRequest || se.Method != http.MethodPost || se.URL != srv.URL+"/users" || se.Attempts != 1 ||
+		se.Header.Get("X-Request-Id") != "42" || len(se.Body) != MaxStatusErrBody {
+		t.Errorf("unexpected status error: %+v", se)
+	}
+	if !strings.Contains(se.Error(), "invalid name") {
+		t.Errorf("body not in message: %s", se.Error())
+	}
+}
+
+func TestRequestID(t *testing.T) {
+	var ids []string
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		ids = append(ids, r.Header.Get("X-Request-ID"))
+		w.WriteHeader(http.StatusNotFound)
+	}))
+	defer srv.Close()
+
+	var evID string
+	hook := func(ev *Event) error {
+		evID = ev.ReqID
+		return nil
+	}
+
+	c := NewClient(WithRequestID(""))
+	ctx := ContextWithRequestID(context.Background(), "abc")
+	_, err := c.Get(srv.URL, nil, WithContext(ctx), WithHook(hook))
+	se, ok := AsStatusErr(err)
+	if !ok || se.ReqID != "abc" || evID != "abc" || ids[0] != "abc" {
+		t.Errorf("unexpected request id: %v, %q, %v", err, evID, ids)
+	}
+
+	c.Get(srv.URL, nil)
+	if len(ids[1]) != 36 {
+		t.Errorf("expect generated id, got %q", ids[1])
+	}
+}
+
+func TestAsync(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/slow" {
+			<-r.Context().Done()
+			return
+		}
+		w.Write([]byte(r.URL.Path))
+	}))
+	defer srv.Close()
+
+	c := NewClient()
+	var a, b string
+	fa := c.GetAsync(srv.URL+"/a", &a)
+	fb := c.PostAsync(srv.URL+"/b", nil, &b)
+	if err := WaitAll(fa, fb); err != nil || a != "/a" || b != "/b" {
+		t.Errorf("unexpected result: %q, %q, %v", a, b, err)
+	}
+
+	fs := c.GetAsync(srv.URL+"/slow", nil)
+	fs.Cancel()
+	if _, err := fs.Wait(); !errors.Is(err, ErrCanceled) {
+		t.Errorf("expect canceled, got %v", err)
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	fs = c.GetAsync(srv.URL+"/slow", nil, WithContext(ctx))
+	cancel()
+	if _, err := fs.Wait(); !errors.Is(err, context.Canceled) {
+		t.Errorf("expect canceled, got %v", err)
+	}
+}
+
+func TestParallel(t *testing.T) {
+	var inflight, peak int32
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		n := atomic.AddInt32(&inflight, 1)
+		defer atomic.AddInt32(&inflight, -1)
+		for {
+			p := atomic.LoadInt32(&peak)
+			if n <= p || atomic.CompareAndSwapInt32(&peak, p, n) {
+				break
+			}
+		}
+		time.Sleep(10 * time.Millisecond)
+		if r.URL.Path == "/bad" {
+			w.WriteHeader(http.StatusBadRequest)
+			return
+		}
+		w.Write([]byte(r.URL.Path))
+	}))
+	defer srv.Close()
+
+	paths := []string{"/a", "/bad", "/c", "/d", "/e"}
+	specs := make([]Spec, len(paths))
+	out := make([]string, len(paths))
+	for i, p := range paths {
+		specs[i] = Spec{URL: srv.URL + p, Result: &out[i]}
+	}
+
+	results, err := NewClient().Parallel(context.Background(), specs, 2)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if peak > 2 {
+		t.Errorf("concurrency exceeded: %d", peak)
+	}
+	for i, p := range paths {
+		if p == "/bad" {
+			if !IsStatusErr(results[i].Err) {
+				t.Errorf("expect status error, got %v", results[i].Err)
+			}
+		} else if results[i].Err != nil || out[i] != p {
+			t.Errorf("unexpected result %d: %q, %v", i, out[i], results[i].Err)
+		}
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	results, err = NewClient().Parallel(ctx, specs, 1)
+	if err != context.Canceled || !errors.Is(results[len(results)-1].Err, context.Canceled) {
+		t.Errorf("expect canceled, got %v", err)
+	}
+}
+
+func TestGraphQL(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var req struct {
+			Query     string         `json:"query"`
+			Variables map[string]int `json:"variables"`
+		}
+		json.NewDecoder(r.Body).Decode(&req)
+		w.Header().Set("Content-Type", TypeJSON)
+		if req.Variables["id"] == 1 {
+			fmt.Fprintf(w, `{"data":{"user":{"name":%q}}}`, req.Query)
+			return
+		}
+		w.Write([]byte(`{"data":{"user":null},"errors":[{"message":"not found","path":["user"],"locations":[{"line":1,"column":3}]}]}`))
+	}))
+	defer srv.Close()
+
+	var result struct {
+		User *struct {
+			Name string `json:"name"`
+		} `json:"user"`
+	}
+	c := NewClient()
+	if _, err := c.GraphQL(srv.URL, "{user}", map[string]int{"id": 1}, &result); err != nil || result.User.Name != "{user}" {
+		t.Fatalf("unexpected result: %+v, %v", result.User, err)
+	}
+
+	result.User = nil
+	_, err := c.GraphQL(srv.URL, "{user}", map[string]int{"id": 2}, &result)
+	var ge *GraphQLError
+	if !errors.As(err, &ge) || ge.Message != "not found" || ge.Locations[0].Column != 3 || result.User != nil {
+		t.Errorf("unexpected error: %v", err)
+	}
+}
+
+func TestWebDAV(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.Method {
+		case MethodPropfind:
+			body, _ := ioutil.ReadAll(r.Body)
+			if r.Header.Get("Depth") != DepthOne || !strings.Contains(string(body), "<D:getetag/>") {
+				w.WriteHeader(http.StatusBadRequest)
+				return
+			}
+			w.Header().Set("Content-Type", "text/xml; charset=utf-8")
+			w.WriteHeader(http.StatusMultiStatus)
+			w.Write([]byte(`<?xml version="1.0" encoding="utf-8"?>
+<d:multistatus xmlns:d="DAV:">
+  <d:response>
+    <d:href>/files/</d:href>
+    <d:propstat>
+      <d:prop><d:resourcetype><d:collection/></d:resourcetype><d:getetag>"1"</d:getetag></d:prop>
+      <d:status>HTTP/1.1 200 OK</d:status>
+    </d:propstat>
+  </d:response>
+  <d:response>
+    <d:href>/files/a.txt</d:href>
+    <d:propstat>
+      <d:prop><d:resourcetype/><d:getetag>"2"</d:getetag></d:prop>
+      <d:status>HTTP/1.1 200 OK</d:status>
+    </d:propstat>
+  </d:response>
+</d:multistatus>`))
+		case MethodMove:
+			if r.Header.Get("Destination") != "/files/b.txt" || r.Header.Get("Overwrite") != "F" {
+				w.WriteHeader(http.StatusBadRequest)
+				return
+			}
+			w.WriteHeader(http.StatusCreated)
+		}
+	}))
+	defer srv.Close()
+
+	c := NewClient()
+	ms, err := c.Propfind(srv.URL+"/files/", DepthOne, []string{"resourcetype", "getetag"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(ms.Responses) != 2 || !ms.Responses[0].IsCollection() || ms.Responses[1].IsCollection() {
+		t.Fatalf("unexpected multistatus: %+v", ms)
+	}
+	if p, ok := ms.Responses[1].Prop("getetag"); !ok || p.Value != `"2"` {
+		t.Errorf("unexpected etag: %+v", p)
+	}
+
+	rsp, err := c.Move(srv.URL+"/files/a.txt", "/files/b.txt", false)
+	if err != nil || rsp.StatusCode != http.StatusCreated {
+		t.Errorf("unexpected move result: %v", err)
+	}
+}
+
+func TestResultFor(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", TypeJSON)
+		switch r.URL.Path {
+		case "/created":
+			w.WriteHeader(http.StatusCreated)
+			w.Write([]byte(`{"id":1}`))
+		case "/invalid":
+			w.WriteHeader(http.StatusUnprocessableEntity)
+			w.Write([]byte(`{"field":"name","reason":"required"}`))
+		default:
+			w.WriteHeader(http.StatusInternalServerError)
+			w.Write([]byte(`oops`))
+		}
+	}))
+	defer srv.Close()
+
+	type created struct{ ID int }
+	type invalid struct{ Field, Reason string }
+	c := NewClient()
+
+	var ok created
+	var bad invalid
+	if _, err := c.Post(srv.URL+"/created", nil, nil, WithResultFor(Status2xx, &ok), WithResultFor(Status4xx, &bad)); err != nil || ok.ID != 1 {
+		t.Fatalf("unexpected result: %+v, %v", ok, err)
+	}
+
+	_, err := c.Post(srv.URL+"/invalid", nil, nil, WithResultFor(StatusExact(http.StatusUnprocessableEntity), &bad))
+	se, isStatus := AsStatusErr(err)
+	if !isStatus || bad.Field != "name" || bad.Reason != "required" || !strings.Contains(string(se.Body), "required") {
+		t.Errorf("unexpected error result: %+v, %v", bad, err)
+	}
+
+	bad = invalid{}
+	if _, err := c.Get(srv.URL+"/fail", nil, WithResultFor(Status5xx, &bad)); !IsStatusErr(err) || bad.Field != "" {
+		t.Errorf("unexpected fail result: %+v, %v", bad, err)
+	}
+}
+
+func TestRawResponse(t *testing.T) {
+	release := make(chan struct{})
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", TypeJSON)
+		w.Write([]byte(`{"part":1}`))
+		w.(http.Flusher).Flush()
+		<-release
+		w.Write([]byte(`{"part":2}`))
+	}))
+	defer srv.Close()
+	defer close(release)
+
+	// RetryConditions和MaxResponseBytes都需要读取body,WithRawResponse时忽略
+	var result map[string]int
+	c := NewClient(WithRetryCondition(RetryOnBodyContains("retry")), WithMaxResponseBytes(5))
+	rsp, err := c.Get(srv.URL, &result, WithRawResponse())
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer rsp.Body.Close()
+
+	buf := make([]byte, 10)
+	if _, err := io.ReadFull(rsp.Body, buf); err != nil || string(buf) != `{"part":1}` || result != nil {
+		t.Errorf("unexpected raw body: %q, %v", buf, err)
+	}
+}
+
+func TestSharedTransport(t *testing.T) {
+	var conns int32
+	srv := httptest.NewUnstartedServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+	srv.Config.ConnState = func(conn net.Conn, state http.ConnState) {
+		if state == http.StateNew {
+			atomic.AddInt32(&conns, 1)
+		}
+	}
+	srv.Start()
+	defer srv.Close()
+
+	a := NewClient(WithSharedTransport(), WithHeader("X-Tenant", "a"))
+	b := NewClient(WithSharedTransport(), WithHeader("X-Tenant", "b"))
+	if a.client.Transport != b.client.Transport || a.client.Transport != SharedTransport() {
+		t.Fatal("transport not shared")
+	}
+
+	for _, c := range []*Client{a, b, a} {
+		rsp, err := c.Get(srv.URL, nil)
+		if err != nil {
+			t.Fatal(err)
+		}
+		io.Copy(io.Discard, rsp.Body)
+		rsp.Body.Close()
+	}
+	if conns != 1 {
+		t.Errorf("expect 1 connection, got %d", conns)
+	}
+}
+
+func TestRegistry(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte(r.URL.Path + " " + r.Header.Get("Authorization")))
+	}))
+	defer srv.Close()
+
+	Register("payments", WithBaseURL(srv.URL+"/pay"), WithBearAuth("token"))
+	if _, ok := Lookup("unknown"); ok {
+		t.Error("unknown client should not be found")
+	}
+	func() {
+		defer func() {
+			if r := recover(); r == nil {
+				t.Error("Use should panic for unknown client")
+			}
+		}()
+		Use("unknown")
+	}()
+
+	var text string
+	if _, err := Use("payments").Get("/orders", &text); err != nil || text != "/pay/orders Bearer token" {
+		t.Errorf("unexpected result: %q, %v", text, err)
+	}
+}
+
+func TestConfig(t *testing.T) {
+	srv := httptest.NewTLSServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte(r.URL.Path + " " + r.Header.Get("X-App")))
+	}))
+	defer srv.Close()
+
+	dir := t.TempDir()
+	ca := pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: srv.Certificate().Raw})
+	os.WriteFile(filepath.Join(dir, "ca.pem"), ca, 0644)
+	yml := fmt.Sprintf("base_url: %s/api\ntimeout: 2s\nretry: 2\nbackoff: 0.5\nheaders:\n  X-App: demo\ntls:\n  ca_file: %s\n", srv.URL, filepath.Join(dir, "ca.pem"))
+	os.WriteFile(filepath.Join(dir, "client.yaml"), []byte(yml), 0644)
+
+	cfg, err := LoadConfig(filepath.Join(dir, "client.yaml"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if time.Duration(cfg.Timeout) != 2*time.Second || time.Duration(cfg.Backoff) != 500*time.Millisecond || cfg.Retry != 2 {
+		t.Errorf("unexpected config: %+v", cfg)
+	}
+
+	c, err := NewClientFromConfig(*cfg)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var text string
+	if _, err := c.Get("/users", &text); err != nil || text != "/api/users demo" {
+		t.Errorf("unexpected result: %q, %v", text, err)
+	}
+
+	cfg = &Config{}
+	if err := json.Unmarshal([]byte(`{"timeout":3,"tls":{"ca_file":"missing.pem"}}`), cfg); err != nil || time.Duration(cfg.Timeout) != 3*time.Second {
+		t.Fatalf("unexpected json config: %+v, %v", cfg, err)
+	}
+	if _, err := NewClientFromConfig(*cfg); err == nil {
+		t.Error("expect missing ca error")
+	}
+}
+
+func TestEnvDefaults(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte(r.URL.Path + " " + r.UserAgent()))
+	}))
+	defer srv.Close()
+
+	t.Setenv("GHTTP_BASE_URL", srv.URL+"/v1")
+	t.Setenv("GHTTP_TIMEOUT", "1.5")
+	t.Setenv("GHTTP_RETRY", "3")
+	t.Setenv("GHTTP_BACKOFF", "bad")
+	t.Setenv("GHTTP_USER_AGENT", "env-agent")
+
+	o := NewOptions(WithEnvDefaults(), WithRetry(1))
+	if o.Timeout != 1500*time.Millisecond || o.Retry != 1 {
+		t.Errorf("unexpected options: %v %v", o.Timeout, o.Retry)
+	}
+
+	c := NewClient(WithEnvDefaults())
+	var text string
+	if _, err := c.Get("/ping", &text); err != nil || text != "/v1/ping env-agent" {
+		t.Errorf("unexpected result: %q, %v", text, err)
+	}
+
+	// 只在WithEnvDefaults时读取,之后修改环境变量不影响已经创建的Client
+	os.Setenv("GHTTP_USER_AGENT", "changed")
+	if _, err := c.Get("/ping", &text); err != nil || text != "/v1/ping env-agent" {
+		t.Errorf("unexpected result after env changed: %q, %v", text, err)
+	}
+}
+
+func TestHostOptions(t *testing.T) {
+	var calls int32
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		atomic.AddInt32(&calls, 1)
+		if r.URL.Path == "/slow" {
+			time.Sleep(100 * time.Millisecond)
+		}
+		w.Write([]byte(r.Header.Get("X-Vendor")))
+	}))
+	defer srv.Close()
+
+	u, _ := url.Parse(srv.URL)
+	c := NewClient(
+		WithTimeout(50*time.Millisecond),
+		WithRetry(2),
+		WithBackoff(nil),
+		WithHostOptions(u.Hostname(), WithTimeout(time.Second), WithRetry(0), WithHeader("X-Vendor", "slow")),
+	)
+
+	var text string
+	if _, err := c.Get(srv.URL+"/slow", &text); err != nil || text != "slow" {
+		t.Errorf("unexpected result: %q, %v", text, err)
+	}
+
+	// 请求参数优先于host参数
+	atomic.StoreInt32(&calls, 0)
+	if _, err := c.Get(srv.URL+"/slow", nil, WithTimeout(10*time.Millisecond)); err == nil {
+		t.Error("expect timeout")
+	}
+	if n := atomic.LoadInt32(&calls); n != 1 {
+		t.Errorf("expect no retry, got %d calls", n)
+	}
+
+	// 其他host使用默认参数
+	other := strings.Replace(srv.URL, "127.0.0.1", "localhost", 1)
+	atomic.StoreInt32(&calls, 0)
+	if _, err := c.Get(other+"/slow", nil); err == nil {
+		t.Error("expect timeout")
+	}
+	if n := atomic.LoadInt32(&calls); n != 3 {
+		t.Errorf("expect 3 calls, got %d", n)
+	}
+}
+
+func TestClientWith(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte(r.Header.Get("Authorization") + " " + r.Header.Get("X-App")))
+	}))
+	defer srv.Close()
+
+	var events int32
+	base := NewClient(WithBaseURL(srv.URL), WithHeader("X-App", "demo"))
+	base.Subscribe(EventPost, func(ev *Event) { atomic.AddInt32(&events, 1) })
+
+	tenant := base.With(WithBearAuth("tenant"))
+	var text string
+	if _, err := tenant.Get("/", &text); err != nil || text != "Bearer tenant demo" {
+		t.Errorf("unexpected result: %q, %v", text, err)
+	}
+	if _, err := base.Get("/", &text); err != nil || text != " demo" {
+		t.Errorf("unexpected result: %q, %v", text, err)
+	}
+	if n := atomic.LoadInt32(&events); n != 2 {
+		t.Errorf("expect shared event bus, got %d events", n)
+	}
+}
+
+func TestStd(t *testing.T) {
+	var conns int32
+	srv := httptest.NewUnstartedServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte("ok"))
+	}))
+	srv.Config.ConnState = func(conn net.Conn, state http.ConnState) {
+		if state == http.StateNew {
+			atomic.AddInt32(&conns, 1)
+		}
+	}
+	srv.Start()
+	defer srv.Close()
+
+	c := NewClient()
+	rsp, err := c.Std().Get(srv.URL)
+	if err != nil {
+		t.Fatal(err)
+	}
+	io.Copy(io.Discard, rsp.Body)
+	rsp.Body.Close()
+
+	if _, err := c.Get(srv.URL, nil); err != nil {
+		t.Fatal(err)
+	}
+	c.CloseIdleConnections()
+	if _, err := c.Get(srv.URL, nil); err != nil {
+		t.Fatal(err)
+	}
+	if n := atomic.LoadInt32(&conns); n != 2 {
+		t.Errorf("expect 2 connections, got %d", n)
+	}
+}
+
+// TestConcurrent 使用go test -race检查并发请求时是否修改共享的默认参数
+func TestConcurrent(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		fmt.Fprintf(w, `{"app":%q,"id":%q,"n":%d}`, r.Header.Get("X-App"), r.URL.Query().Get("id"), len(r.Header.Values("X-App")))
+	}))
+	defer srv.Close()
+
+	u, _ := url.Parse(srv.URL)
+	var hooks int32
+	opts := []Option{
+		WithBaseURL(srv.URL),
+		WithHeaders(map[string]string{"x-app": "demo"}),
+		WithQuery("id", "default"),
+		WithPathParams(map[string]string{"name": "users"}),
+		WithHook(func(ev *Event) error {
+			atomic.AddInt32(&hooks, 1)
+			return nil
+		}),
+		WithHostOptions(u.Hostname(), WithRetry(1)),
+	}
+	c := NewClient(opts...)
+	opts[0] = WithBaseURL("http://invalid.invalid")
+
+	var wg sync.WaitGroup
+	for i := 0; i < 20; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			id := strconv.Itoa(i)
+			result := struct {
+				App string `json:"app"`
+				ID  string `json:"id"`
+				N   int    `json:"n"`
+			}{}
+			cl := c
+			if i%2 == 0 {
+				cl = c.With(WithHeader("X-Tenant", id))
+			}
+			if _, err := cl.Get("/{name}", &result, WithQuery("id", id)); err != nil {
+				t.Error(err)
+				return
+			}
+			if result.App != "demo" || result.N != 1 || result.ID != "default" {
+				t.Errorf("unexpected result: %+v", result)
+			}
+		}(i)
+	}
+	wg.Wait()
+
+	if n := atomic.LoadInt32(&hooks); n != 40 {
+		t.Errorf("expect 40 hook calls, got %d", n)
+	}
+
+	// WithOptions不修改共享的Options
+	shared := NewOptions(WithBaseURL(srv.URL), WithHeader("X-App", "demo"), WithQuery("id", "default"), WithData("k", "v"))
+	plain := NewClient()
+	for i := 0; i < 20; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			id := strconv.Itoa(i)
+			result := struct {
+				App string `json:"app"`
+				ID  string `json:"id"`
+			}{}
+			opts := []Option{WithOptions(shared), WithHeader("X-Tenant", id), WithQuery("page", id), WithData("k", id), WithIdempotencyKey(id)}
+			if _, err := plain.Post("/", map[string]string{"id": id}, &result, opts...); err != nil {
+				t.Error(err)
+				return
+			}
+			if result.App != "demo" || result.ID != "default" {
+				t.Errorf("unexpected result: %+v", result)
+			}
+		}(i)
+	}
+	wg.Wait()
+
+	if len(shared.Header) != 1 || len(shared.Query) != 1 || shared.Datas["k"] != "v" {
+		t.Errorf("shared options modified: %v %v %v", shared.Header, shared.Query, shared.Datas)
+	}
+}
+
+func TestHARRecorder(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/binary":
+			w.Header().Set("Content-Type", TypeOctetStream)
+			w.Write([]byte{0xff, 0x00, 0xfe})
+		default:
+			data, _ := io.ReadAll(r.Body)
+			w.Header().Set("Content-Type", TypeJSON)
+			fmt.Fprintf(w, `{"echo":%q}`, data)
+		}
+	}))
+	defer srv.Close()
+
+	path := filepath.Join(t.TempDir(), "out.har")
+	rec := NewHARFileRecorder(path)
+	c := NewClient(WithHARRecorder(rec))
+
+	var result map[string]string
+	if _, err := c.Post(srv.URL+"/echo", "hello", &result); err != nil || result["echo"] != "hello" {
+		t.Fatalf("unexpected result: %v, %v", result, err)
+	}
+	var data []byte
+	if _, err := c.Get(srv.URL+"/binary", &data); err != nil || len(data) != 3 {
+		t.Fatalf("unexpected binary: %v, %v", data, err)
+	}
+
+	// RawResponse的body由调用者读取,不会被提前消耗
+	rsp, err := c.Get(srv.URL+"/raw", nil, WithRawResponse())
+	if err != nil {
+		t.Fatal(err)
+	}
+	raw, _ := io.ReadAll(rsp.Body)
+	rsp.Body.Close()
+	if string(raw) != `{"echo":""}` {
+		t.Errorf("unexpected raw body: %s", raw)
+	}
+
+	if _, err := os.Stat(path); !os.IsNotExist(err) {
+		t.Errorf("file should be written on Close")
+	}
+	if err := rec.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	doc := &harDocument{}
+	file, _ := os.ReadFile(path)
+	if err := json.Unmarshal(file, doc); err != nil {
+		t.Fatal(err)
+	}
+	entries := doc.Log.Entries
+	if len(entries) != 3 {
+		t.Fatalf("expect 3 entries, got %d", len(entries))
+	}
+	if entries[0].Request.PostData == nil || entries[0].Request.PostData.Text != "hello" || entries[0].Response.Content.Text != `{"echo":"hello"}` {
+		t.Errorf("unexpected entry: %+v", entries[0])
+	}
+	if c := entries[1].Response.Content; c.Encoding != "base64" || c.Text != "/wD+" || c.Size != 3 {
+		t.Errorf("unexpected binary content: %+v", c)
+	}
+	if entries[2].Response.Content.Text != string(raw) {
+		t.Errorf("unexpected raw content: %+v", entries[2].Response.Content)
+	}
+}
+
+func TestCSRF(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method == http.MethodGet {
+			w.Header().Set("X-CSRF-Token", "tok")
+			return
+		}
+
+		var body io.Reader = r.Body
+		if r.Header.Get("Content-Encoding") == EncodingGzip {
+			gz, err := gzip.NewReader(r.Body)
+			if err != nil {
+				w.WriteHeader(http.StatusBadRequest)
+				return
+			}
+			body = gz
+			w.Write([]byte("gzip:"))
+		}
+		data, _ := io.ReadAll(body)
+		fmt.Fprintf(w, "%s|%s", r.Header.Get("X-CSRF-Token"), data)
+	}))
+	defer srv.Close()
+
+	csrf := NewCSRF("", "")
+	csrf.FieldName = "csrf"
+	c := NewClient(WithCSRF(csrf), WithContentType(TypeForm))
+	if _, err := c.Get(srv.URL, nil); err != nil {
+		t.Fatal(err)
+	}
+	if csrf.Token() != "tok" {
+		t.Fatalf("token not extracted: %q", csrf.Token())
+	}
+
+	var text string
+	if _, err := c.Post(srv.URL, map[string]string{"a": "b c"}, &text); err != nil {
+		t.Fatal(err)
+	}
+	if text != "tok|a=b+c&csrf=tok" {
+		t.Errorf("unexpected form: %q", text)
+	}
+
+	// 已有字段时替换
+	if _, err := c.Post(srv.URL, "csrf=old&a=1", &text); err != nil {
+		t.Fatal(err)
+	}
+	if text != "tok|a=1&csrf=tok" {
+		t.Errorf("unexpected form: %q", text)
+	}
+
+	// 压缩时在压缩前附加,进度统计包含token
+	// 先上传再下载,记录第一个完成的统计
+	var uploaded int64
+	progress := func(n, t int64) {
+		if uploaded == 0 && n == t {
+			uploaded = n
+		}
+	}
+	if _, err := c.Post(srv.URL, map[string]string{"a": "b"}, &text, WithRequestCompression(EncodingGzip)); err != nil {
+		t.Fatal(err)
+	}
+	if text != "gzip:tok|a=b&csrf=tok" {
+		t.Errorf("unexpected compressed form: %q", text)
+	}
+	if _, err := c.Post(srv.URL, map[string]string{"a": "b"}, &text, WithProgress(progress)); err != nil {
+		t.Fatal(err)
+	}
+	if text != "tok|a=b&csrf=tok" || uploaded != int64(len("a=b&csrf=tok")) {
+		t.Errorf("unexpected progress form: %q %v", text, uploaded)
+	}
+
+	// GET和非表单的body不附加字段
+	if _, err := c.Post(srv.URL, map[string]string{"a": "b"}, &text, WithContentType(TypeJSON)); err != nil {
+		t.Fatal(err)
+	}
+	if text != `tok|{"a":"b"}` {
+		t.Errorf("unexpected json body: %q", text)
+	}
+
+	// Do传入的请求只附加header,不修改body
+	req, _ := http.NewRequest(http.MethodPost, srv.URL, strings.NewReader("a=b"))
+	req.Header.Set("Content-Type", TypeForm)
+	if _, err := c.Do(req, &text); err != nil {
+		t.Fatal(err)
+	}
+	if text != "tok|a=b" {
+		t.Errorf("unexpected Do body: %q", text)
+	}
+}
+
+func TestToCurl(t *testing.T) {
+	binary := []byte{0x00, 0xff, '%', '\\', '\'', '\n', 'a'}
+	cases := []struct {
+		name   string
+		header http.Header
+		body   []byte
+		args   []string
+	}{
+		{
+			name:   "quote",
+			header: http.Header{"X-Name": {"it's"}},
+			body:   []byte(`{"msg":"don't"}`),
+			args:   []string{"-X", "POST", "-H", "X-Name: it's", "--data-binary", `{"msg":"don't"}`},
+		},
+		{
+			name:   "multi header",
+			header: http.Header{"Accept": {"a", "b"}},
+			args:   []string{"-X", "POST", "-H", "Accept: a", "-H", "Accept: b"},
+		},
+		{
+			name: "binary",
+			body: binary,
+			args: []string{"-X", "POST", "--data-binary", "@-"},
+		},
+	}
+
+	sh, shErr := exec.LookPath("sh")
+	for _, tc := range cases {
+		req, _ := http.NewRequest(http.MethodPost, "http://example.com/a?b='c'", nil)
+		req.Header = tc.header
+		if req.Header == nil {
+			req.Header = http.Header{}
+		}
+		cmd := ToCurl(req, tc.body)
+		if tc.name == "binary" && !strings.HasPrefix(cmd, "printf ") {
+			t.Errorf("binary body should be piped: %v", cmd)
+		}
+		if shErr != nil {
+			continue
+		}
+
+		// 用shell函数代替curl,输出解析后的参数和stdin
+		script := `curl() { for a; do printf '%s\0' "$a"; done; cat; }; ` + cmd
+		run := exec.Command(sh, "-c", script)
+		run.Stdin = strings.NewReader("")
+		out, err := run.Output()
+		if err != nil {
+			t.Fatalf("%v: %v, %v", tc.name, cmd, err)
+		}
+
+		want := append(tc.args, "http://example.com/a?b='c'")
+		got := bytes.SplitN(out, []byte{0}, len(want)+1)
+		if len(got) != len(want)+1 {
+			t.Fatalf("%v: unexpected args %q", tc.name, out)
+		}
+		for i, arg := range want {
+			if string(got[i]) != arg {
+				t.Errorf("%v: arg %d got %q, want %q", tc.name, i, got[i], arg)
+			}
+		}
+		stdin := []byte{}
+		if tc.name == "binary" {
+			stdin = tc.body
+		}
+		if !bytes.Equal(got[len(want)], stdin) {
+			t.Errorf("%v: unexpected stdin %q", tc.name, got[len(want)])
+		}
+	}
+}
+
+func TestProgress(t *testing.T) {
+	data := strings.Repeat("x", 3000)
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		io.Copy(io.Discard, r.Body)
+		if r.URL.Path == "/chunked" {
+			// 分两次发送,没有Content-Length
+			w.Write([]byte(data[:1000]))
+			w.(http.Flusher).Flush()
+			w.Write([]byte(data[1000:]))
+			return
+		}
+		w.Header().Set("Content-Length", strconv.Itoa(len(data)))
+		w.Write([]byte(data))
+	}))
+	defer srv.Close()
+
+	type record struct{ n, total int64 }
+	for _, tc := range []struct {
+		path  string
+		total int64
+	}{{"/", 3000}, {"/chunked", -1}} {
+		var mux sync.Mutex
+		var records []record
+		progress := func(n, total int64) {
+			mux.Lock()
+			records = append(records, record{n, total})
+			mux.Unlock()
+		}
+
+		var text string
+		if _, err := Post(srv.URL+tc.path, strings.Repeat("y", 1000), &text, WithContentType(TypeText), WithProgress(progress)); err != nil {
+			t.Fatal(err)
+		}
+
+		// 先上传,再下载,每个阶段的进度递增,最后一次为全部字节数
+		var upload, download []record
+		for _, r := range records {
+			if r.total == 1000 {
+				upload = append(upload, r)
+			} else {
+				download = append(download, r)
+			}
+		}
+		if len(upload) == 0 || upload[len(upload)-1] != (record{1000, 1000}) {
+			t.Errorf("%v: unexpected upload progress %v", tc.path, upload)
+		}
+		if len(download) == 0 || download[len(download)-1] != (record{3000, tc.total}) {
+			t.Errorf("%v: unexpected download progress %v", tc.path, download)
+		}
+		for i := 1; i < len(download); i++ {
+			if download[i].n <= download[i-1].n || download[i].total != tc.total {
+				t.Errorf("%v: progress not increasing %v", tc.path, download)
+			}
+		}
+		if len(upload) > 0 && records[len(upload)-1] != upload[len(upload)-1] {
+			t.Errorf("%v: upload should finish before download %v", tc.path, records)
+		}
+	}
+}
+
+func TestPoolOptions(t *testing.T) {
+	ht, ok := NewClient().client.Transport.(*http.Transport)
+	if !ok {
+		t.Fatal("expect *http.Transport")
+	}
+	if ht.MaxIdleConns != defaultMaxIdleConns || ht.MaxIdleConnsPerHost != defaultMaxIdlePerHost || ht.IdleConnTimeout != defaultIdleConnTimeout {
+		t.Errorf("unexpected default pool: %v, %v, %v", ht.MaxIdleConns, ht.MaxIdleConnsPerHost, ht.IdleConnTimeout)
+	}
+
+	c := NewClient(WithMaxIdleConns(7), WithMaxIdleConnsPerHost(3), WithMaxConnsPerHost(5), WithIdleConnTimeout(time.Minute),
+		WithResponseHeaderTimeout(2*time.Second), WithExpectContinueTimeout(3*time.Second), WithDisableKeepAlives(true))
+	ht = c.client.Transport.(*http.Transport)
+	if ht.MaxIdleConns != 7 || ht.MaxIdleConnsPerHost != 3 || ht.MaxConnsPerHost != 5 || ht.IdleConnTimeout != time.Minute ||
+		ht.ResponseHeaderTimeout != 2*time.Second || ht.ExpectContinueTimeout != 3*time.Second || !ht.DisableKeepAlives {
+		t.Errorf("pool options not applied: %+v", ht)
+	}
+
+	// 0表示使用http.Transport的默认行为
+	ht = NewClient(WithMaxIdleConns(0), WithIdleConnTimeout(0)).client.Transport.(*http.Transport)
+	if ht.MaxIdleConns != 0 || ht.IdleConnTimeout != 0 {
+		t.Errorf("unexpected zero pool: %v, %v", ht.MaxIdleConns, ht.IdleConnTimeout)
+	}
+
+	// 只在NewClient时有效
+	if NewClient().With(WithMaxIdleConns(1)).client.Transport.(*http.Transport).MaxIdleConns == 1 {
+		t.Error("With should not change transport")
+	}
+}
+
+func TestHTTPClient(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte("ok"))
+	}))
+	defer srv.Close()
+
+	var calls, others int32
+	transport := RoundTripperFunc(func(req *Request) (*Response, error) {
+		atomic.AddInt32(&calls, 1)
+		return http.DefaultTransport.RoundTrip(req)
+	})
+	redirect := func(req *http.Request, via []*http.Request) error { return nil }
+	hc := &http.Client{Transport: transport, Timeout: 5 * time.Second, CheckRedirect: redirect}
+	snapshot := *hc
+
+	unchanged := func() {
+		t.Helper()
+		if hc.Transport == nil || hc.Timeout != snapshot.Timeout || hc.Jar != snapshot.Jar || hc.CheckRedirect == nil {
+			t.Errorf("http.Client was modified: %+v", hc)
+		}
+	}
+
+	c := NewClient(WithHTTPClient(hc), WithTimeout(time.Second), WithMaxIdleConns(1), WithHostOptions(srv.URL, WithHeader("X-A", "1")))
+	if c.Std() != hc {
+		t.Error("expect the supplied http.Client")
+	}
+	var text string
+	if _, err := c.Get(srv.URL, &text); err != nil || text != "ok" || atomic.LoadInt32(&calls) != 1 {
+		t.Errorf("unexpected result: %q, %v, calls=%d", text, err, calls)
+	}
+	if _, err := c.With(WithTimeout(time.Minute)).Get(srv.URL, &text); err != nil || atomic.LoadInt32(&calls) != 2 {
+		t.Errorf("unexpected result with: %v, calls=%d", err, calls)
+	}
+	unchanged()
+
+	// 同时设置Transport时使用副本
+	other := RoundTripperFunc(func(req *Request) (*Response, error) {
+		atomic.AddInt32(&others, 1)
+		return http.DefaultTransport.RoundTrip(req)
+	})
+	c = NewClient(WithHTTPClient(hc), WithTransport(other))
+	if c.Std() == hc || c.Std().Timeout != hc.Timeout {
+		t.Error("expect a copy of the supplied http.Client")
+	}
+	if _, err := c.Get(srv.URL, &text); err != nil || atomic.LoadInt32(&others) != 1 || atomic.LoadInt32(&calls) != 2 {
+		t.Errorf("unexpected transport: %v, calls=%d, others=%d", err, calls, others)
+	}
+	unchanged()
+}
@@ -0,0 +1,213 @@
+package ghttp
+
+import (
+	"bytes"
+	"encoding/json"
+	"encoding/xml"
+	"net/url"
+	"sync"
+
+	"github.com/fxamacker/cbor/v2"
+	"google.golang.org/protobuf/proto"
+	"gopkg.in/yaml.v3"
+)
+
+// Codec 按Content-Type编解码body
+type Codec interface {
+	Marshal(v interface{}) ([]byte, error)
+	Unmarshal(data []byte, v interface{}) error
+}
+
+// Marshaler 请求body自定义编码,优先于Content-Type对应的Codec,结果作为最终的body
+// 没有对应的Codec时也支持encoding.BinaryMarshaler,如application/octet-stream
+type Marshaler interface {
+	MarshalBody() ([]byte, error)
+}
+
+// Unmarshaler 应答body自定义解码,优先于Content-Type对应的Codec
+// 没有对应的Codec时也支持encoding.BinaryUnmarshaler
+type Unmarshaler interface {
+	UnmarshalBody(data []byte) error
+}
+
+var (
+	codecMux sync.RWMutex
+	codecs   = map[string]Codec{
+		TypeJSON:          jsonCodec{},
+		TypeXML:           xmlCodec{},
+		typeXMLText:       xmlCodec{},
+		TypeForm:          formCodec{},
+		TypeProtobuf:      protoCodec{},
+		typeProtobufAlias: protoCodec{},
+		TypeYAML:          yamlCodec{},
+		typeYAMLText:      yamlCodec{},
+		TypeCBOR:          cborCodec{},
+	}
+)
+
+// RegisterCodec 注册Content-Type对应的Codec,已存在时覆盖
+func RegisterCodec(contentType string, c Codec) {
+	codecMux.Lock()
+	codecs[contentType] = c
+	codecMux.Unlock()
+}
+
+// getCodec 返回请求使用的Codec,部分Codec依赖请求参数
+func (o *Options) getCodec(contentType string) (Codec, bool) {
+	switch contentType {
+	case TypeForm:
+		return formCodec{enc: o.newValueEncoder("form")}, true
+	case TypeJSON:
+		if o.JSONMarshal != nil || o.JSONUnmarshal != nil || o.JSONConfig != nil {
+			return jsonCodec{marshal: o.JSONMarshal, unmarshal: o.JSONUnmarshal, config: o.JSONConfig}, true
+		}
+	}
+
+	return getCodec(contentType)
+}
+
+func getCodec(contentType string) (Codec, bool) {
+	codecMux.RLock()
+	c, ok := codecs[contentType]
+	codecMux.RUnlock()
+	return c, ok
+}
+
+type MarshalFunc func(v interface{}) ([]byte, error)
+type UnmarshalFunc func(data []byte, v interface{}) error
+
+// JSONConfig 标准库json解码参数
+type JSONConfig struct {
+	UseNumber             bool // 数字解码为json.Number
+	DisallowUnknownFields bool // 存在未知字段时报错
+}
+
+// jsonCodec 默认使用标准库,可替换为jsoniter,go-json等实现
+type jsonCodec struct {
+	marshal   MarshalFunc
+	unmarshal UnmarshalFunc
+	config    *JSONConfig
+}
+
+func (c jsonCodec) Marshal(v interface{}) ([]byte, error) {
+	if c.marshal != nil {
+		return c.marshal(v)
+	}
+
+	return json.Marshal(v)
+}
+
+func (c jsonCodec) Unmarshal(data []byte, v interface{}) error {
+	if c.unmarshal != nil {
+		return c.unmarshal(data, v)
+	}
+
+	if c.config == nil {
+		return json.Unmarshal(data, v)
+	}
+
+	dec := json.NewDecoder(bytes.NewReader(data))
+	if c.config.UseNumber {
+		dec.UseNumber()
+	}
+	if c.config.DisallowUnknownFields {
+		dec.DisallowUnknownFields()
+	}
+
+	return dec.Decode(v)
+}
+
+type xmlCodec struct{}
+
+func (xmlCodec) Marshal(v interface{}) ([]byte, error) {
+	return xml.Marshal(v)
+}
+
+func (xmlCodec) Unmarshal(data []byte, v interface{}) error {
+	return xml.Unmarshal(data, v)
+}
+
+type formCodec struct {
+	enc *valueEncoder
+}
+
+func (c formCodec) Marshal(v interface{}) ([]byte, error) {
+	enc := c.enc
+	if enc == nil {
+		enc = &valueEncoder{tag: "form"}
+	}
+
+	uv, err := toUrlValue(v, enc)
+	if err != nil {
+		return nil, err
+	}
+
+	return []byte(uv.Encode()), nil
+}
+
+func (formCodec) Unmarshal(data []byte, v interface{}) error {
+	values, err := url.ParseQuery(string(data))
+	if err != nil {
+		return err
+	}
+
+	return parseUrlValue(values, v)
+}
+
+type yamlCodec struct{}
+
+func (yamlCodec) Marshal(v interface{}) ([]byte, error) {
+	return yaml.Marshal(v)
+}
+
+func (yamlCodec) Unmarshal(data []byte, v interface{}) error {
+	return yaml.Unmarshal(data, v)
+}
+
+type cborCodec struct{}
+
+func (cborCodec) Marshal(v interface{}) ([]byte, error) {
+	return cbor.Marshal(v)
+}
+
+func (cborCodec) Unmarshal(data []byte, v interface{}) error {
+	return cbor.Unmarshal(data, v)
+}
+
+// protoCodec 要求数据实现proto.Message
+type protoCodec struct{}
+
+func (protoCodec) Marshal(v interface{}) ([]byte, error) {
+	if d, ok := v.(*DynamicProto); ok {
+		return proto.Marshal(d.Message)
+	}
+
+	m, ok := v.(proto.Message)
+	if !ok {
+		return nil, ErrInvalidType
+	}
+
+	return proto.Marshal(m)
+}
+
+func (protoCodec) Unmarshal(data []byte, v interface{}) error {
+	if d, ok := v.(*DynamicProto); ok {
+		return d.unmarshal(data)
+	}
+
+	m, ok := v.(proto.Message)
+	if !ok {
+		return ErrInvalidType
+	}
+
+	return proto.Unmarshal(data, m)
+}
+
+func isProtoMessage(v interface{}) bool {
+	switch v.(type) {
+	case proto.Message, *DynamicProto:
+		return true
+	default:
+		return false
+	}
+}
@@ -0,0 +1,192 @@
+package ghttp
+
+import (
+	"bufio"
+	"bytes"
+	"compress/flate"
+	"compress/gzip"
+	"compress/zlib"
+	"io"
+	"net/http"
+	"strings"
+
+	"github.com/andybalholm/brotli"
+	"github.com/klauspost/compress/zstd"
+)
+
+const (
+	EncodingGzip    = "gzip"
+	EncodingDeflate = "deflate"
+	EncodingBrotli  = "br"
+	EncodingZstd    = "zstd"
+)
+
+// compress 压缩请求body
+func compress(encoding string, data []byte) ([]byte, error) {
+	buf := &bytes.Buffer{}
+	var w io.WriteCloser
+	var err error
+	switch encoding {
+	case EncodingGzip:
+		w = gzip.NewWriter(buf)
+	case EncodingDeflate:
+		w = zlib.NewWriter(buf)
+	case EncodingBrotli:
+		w = brotli.NewWriter(buf)
+	case EncodingZstd:
+		w, err = zstd.NewWriter(buf)
+	default:
+		return nil, ErrNotSupport
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	if _, err := w.Write(data); err != nil {
+		w.Close()
+		return nil, err
+	}
+
+	if err := w.Close(); err != nil {
+		return nil, err
+	}
+
+	return buf.Bytes(), nil
+}
+
+// CompressionInfo 应答body的压缩信息,大小在body读取时累计
+// 使用chunked传输时没有Content-Length,只能通过读取得到
+type CompressionInfo struct {
+	Encoding         string // 应答的Content-Encoding,未压缩时为空
+	Compressed       bool   // 是否经过压缩
+	CompressedSize   int64  // 传输的字节数
+	UncompressedSize int64  // 解压后的字节数
+}
+
+// isCompressionRejected 服务器不支持压缩的请求body时通常返回415,也有返回400的
+func isCompressionRejected(code int) bool {
+	return code == http.StatusUnsupportedMediaType || code == http.StatusBadRequest
+}
+
+// decompressResponse 根据Content-Encoding透明解压body,不支持的编码保持不变
+// 当设置了Accept-Encoding时,标准库不会自动解压gzip
+// 解压以流的方式进行,不会缓存压缩数据
+func decompressResponse(rsp *Response, info *CompressionInfo) error {
+	if info != nil && rsp.Body != nil {
+		*info = CompressionInfo{}
+		rsp.Body = &countBody{ReadCloser: rsp.Body, n: &info.CompressedSize}
+		defer func() {
+			rsp.Body = &countBody{ReadCloser: rsp.Body, n: &info.UncompressedSize}
+		}()
+	}
+
+	encoding := strings.ToLower(strings.TrimSpace(rsp.Header.Get("Content-Encoding")))
+	if encoding == "" || encoding == "identity" || rsp.Body == nil || noBody(rsp) {
+		return nil
+	}
+
+	var open func(r io.Reader) (io.Reader, func(), error)
+	switch encoding {
+	case EncodingGzip, "x-gzip":
+		open = func(r io.Reader) (io.Reader, func(), error) {
+			gr, err := gzip.NewReader(r)
+			return gr, nil, err
+		}
+	case EncodingDeflate:
+		open = func(r io.Reader) (io.Reader, func(), error) {
+			return newDeflateReader(r), nil, nil
+		}
+	case EncodingBrotli:
+		open = func(r io.Reader) (io.Reader, func(), error) {
+			return brotli.NewReader(r), nil, nil
+		}
+	case EncodingZstd:
+		open = func(r io.Reader) (io.Reader, func(), error) {
+			zr, err := zstd.NewReader(r)
+			if err != nil {
+				return nil, nil, err
+			}
+			return zr, zr.Close, nil
+		}
+	default:
+		return nil
+	}
+
+	if info != nil {
+		info.Encoding = encoding
+		info.Compressed = true
+	}
+
+	rsp.Body = &decompressBody{body: rsp.Body, open: open}
+	rsp.Header.Del("Content-Encoding")
+	rsp.Header.Del("Content-Length")
+	rsp.ContentLength = -1
+	rsp.Uncompressed = true
+	return nil
+}
+
+// noBody 没有body的应答,如HEAD,1xx,204和304,服务器可能依然带有Content-Encoding
+func noBody(rsp *Response) bool {
+	if rsp.Request != nil && rsp.Request.Method == http.MethodHead {
+		return true
+	}
+
+	code := rsp.StatusCode
+	return (code >= 100 && code < 200) || code == http.StatusNoContent || code == http.StatusNotModified || rsp.ContentLength == 0
+}
+
+// newDeflateReader deflate通常是zlib格式,但也有服务器直接发送raw deflate
+func newDeflateReader(r io.Reader) io.Reader {
+	br := bufio.NewReader(r)
+	header, err := br.Peek(2)
+	if err == nil && header[0]&0x0f == 8 && (uint16(header[0])<<8|uint16(header[1]))%31 == 0 {
+		if zr, err := zlib.NewReader(br); err == nil {
+			return zr
+		}
+	}
+
+	return flate.NewReader(br)
+}
+
+// decompressBody 第一次读取时才创建解压的Reader,空body不会因为缺少gzip头而出错
+type decompressBody struct {
+	body   io.ReadCloser
+	open   func(r io.Reader) (io.Reader, func(), error)
+	reader io.Reader
+	closer func()
+	err    error
+}
+
+func (b *decompressBody) Read(p []byte) (int, error) {
+	if b.reader == nil && b.err == nil {
+		b.reader, b.closer, b.err = b.open(b.body)
+	}
+	if b.err != nil {
+		return 0, b.err
+	}
+
+	return b.reader.Read(p)
+}
+
+func (b *decompressBody) Close() error {
+	if c, ok := b.reader.(io.Closer); ok {
+		c.Close()
+	}
+	if b.closer != nil {
+		b.closer()
+	}
+
+	return b.body.Close()
+}
+
+// countBody 累计读取的字节数
+type countBody struct {
+	io.ReadCloser
+	n *int64
+}
+
+func (b *countBody) Read(p []byte) (int, error) {
+	n, err := b.ReadCloser.Read(p)
+	*b.n += int64(n)
+	return n, err
+}
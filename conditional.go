@@ -0,0 +1,48 @@
+package ghttp
+
+import (
+	"net/http"
+)
+
+// doConditional 记录每个url的ETag和Last-Modified,下次请求时发送条件请求
+// 服务器返回304时使用上次的body,与doCache不同,不考虑Cache-Control,每次都会验证,raw时不保存新的应答
+func doConditional(storage CacheStorage, req *Request, raw bool, send func(req *Request) (*Response, error)) (*Response, string, error) {
+	if req.Method != http.MethodGet || req.Header.Get("If-None-Match") != "" || req.Header.Get("If-Modified-Since") != "" || req.Header.Get("Range") != "" {
+		rsp, err := send(req)
+		return rsp, "", err
+	}
+
+	key := cacheKey(req)
+	var rsp *Response
+	var err error
+	e := loadCache(storage, key, req)
+	if e != nil {
+		rsp, err = e.revalidate(req, send)
+	} else {
+		rsp, err = send(req)
+	}
+	if err != nil {
+		return nil, "", err
+	}
+
+	if rsp.StatusCode == http.StatusNotModified && e != nil {
+		e.update(rsp)
+		e.save(storage, key)
+		return e.response(req), CacheRevalidated, nil
+	}
+
+	if raw || rsp.StatusCode != http.StatusOK {
+		return rsp, CacheMiss, nil
+	}
+
+	e = newCacheEntry(req, rsp)
+	if e == nil || !e.hasValidator() {
+		return rsp, CacheMiss, nil
+	}
+
+	if err := e.saveBody(storage, key, rsp); err != nil {
+		return nil, "", err
+	}
+
+	return rsp, CacheMiss, nil
+}
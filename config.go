@@ -0,0 +1,198 @@
+package ghttp
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"encoding/json"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"gopkg.in/yaml.v3"
+)
+
+// Duration 可以从配置文件中解析的时长,支持"1.5s"格式的字符串,数字表示秒
+type Duration time.Duration
+
+func (d *Duration) UnmarshalJSON(data []byte) error {
+	var v interface{}
+	if err := json.Unmarshal(data, &v); err != nil {
+		return err
+	}
+
+	return d.set(v)
+}
+
+func (d *Duration) UnmarshalYAML(node *yaml.Node) error {
+	var v interface{}
+	if err := node.Decode(&v); err != nil {
+		return err
+	}
+
+	return d.set(v)
+}
+
+func (d *Duration) set(v interface{}) error {
+	switch x := v.(type) {
+	case string:
+		t, err := time.ParseDuration(x)
+		if err != nil {
+			return err
+		}
+		*d = Duration(t)
+	case float64:
+		*d = Duration(x * float64(time.Second))
+	case int:
+		*d = Duration(time.Duration(x) * time.Second)
+	default:
+		return ErrInvalidConfig
+	}
+
+	return nil
+}
+
+// TLSFileConfig 配置文件中的TLS参数
+type TLSFileConfig struct {
+	CAFile             string `json:"ca_file" yaml:"ca_file"`                           // 为空时使用系统CA
+	CertFile           string `json:"cert_file" yaml:"cert_file"`                       // 客户端证书
+	KeyFile            string `json:"key_file" yaml:"key_file"`                         // 客户端私钥
+	ServerName         string `json:"server_name" yaml:"server_name"`                   // SNI
+	InsecureSkipVerify bool   `json:"insecure_skip_verify" yaml:"insecure_skip_verify"` // 不校验服务器证书,仅用于测试
+}
+
+// Config 以配置文件表示的Client参数,零值表示使用默认值
+// Timeout为每次执行的超时时间,Backoff为重试前固定的等待时间,Proxy为代理地址,为env时使用环境变量
+type Config struct {
+	BaseURL             string            `json:"base_url" yaml:"base_url"`
+	Timeout             Duration          `json:"timeout" yaml:"timeout"`
+	OverallTimeout      Duration          `json:"overall_timeout" yaml:"overall_timeout"`
+	DialTimeout         Duration          `json:"dial_timeout" yaml:"dial_timeout"`
+	Retry               int               `json:"retry" yaml:"retry"`
+	Backoff             Duration          `json:"backoff" yaml:"backoff"`
+	Headers             map[string]string `json:"headers" yaml:"headers"`
+	UserAgent           string            `json:"user_agent" yaml:"user_agent"`
+	Proxy               string            `json:"proxy" yaml:"proxy"`
+	TLS                 *TLSFileConfig    `json:"tls" yaml:"tls"`
+	MaxIdleConns        int               `json:"max_idle_conns" yaml:"max_idle_conns"`
+	MaxIdleConnsPerHost int               `json:"max_idle_conns_per_host" yaml:"max_idle_conns_per_host"`
+	MaxConnsPerHost     int               `json:"max_conns_per_host" yaml:"max_conns_per_host"`
+	IdleConnTimeout     Duration          `json:"idle_conn_timeout" yaml:"idle_conn_timeout"`
+}
+
+// LoadConfig 读取json或yaml格式的配置文件,按扩展名区分,.yaml和.yml为yaml,其他为json
+func LoadConfig(path string) (*Config, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+
+	cfg := &Config{}
+	switch strings.ToLower(filepath.Ext(path)) {
+	case ".yaml", ".yml":
+		err = yaml.Unmarshal(data, cfg)
+	default:
+		err = json.Unmarshal(data, cfg)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	return cfg, nil
+}
+
+// Options 转为Option,读取TLS证书失败时返回错误
+func (cfg *Config) Options() ([]Option, error) {
+	var opts []Option
+	if cfg.BaseURL != "" {
+		opts = append(opts, WithBaseURL(cfg.BaseURL))
+	}
+	if cfg.Timeout > 0 {
+		opts = append(opts, WithTimeout(time.Duration(cfg.Timeout)))
+	}
+	if cfg.OverallTimeout > 0 {
+		opts = append(opts, WithOverallTimeout(time.Duration(cfg.OverallTimeout)))
+	}
+	if cfg.DialTimeout > 0 {
+		opts = append(opts, WithDialTimeout(time.Duration(cfg.DialTimeout)))
+	}
+	if cfg.Retry > 0 {
+		opts = append(opts, WithRetry(cfg.Retry))
+	}
+	if cfg.Backoff > 0 {
+		opts = append(opts, WithBackoff(NewConstantBackoff(time.Duration(cfg.Backoff))))
+	}
+	for k, v := range cfg.Headers {
+		opts = append(opts, WithHeader(k, v))
+	}
+	if cfg.UserAgent != "" {
+		opts = append(opts, WithUserAgent(cfg.UserAgent))
+	}
+	switch cfg.Proxy {
+	case "":
+	case "env":
+		opts = append(opts, WithProxy(http.ProxyFromEnvironment))
+	default:
+		opts = append(opts, WithProxyURL(cfg.Proxy))
+	}
+	if cfg.TLS != nil {
+		tlsConfig, err := cfg.TLS.build()
+		if err != nil {
+			return nil, err
+		}
+		opts = append(opts, WithTLSConfig(tlsConfig))
+		if cfg.TLS.ServerName != "" {
+			opts = append(opts, WithServerName(cfg.TLS.ServerName))
+		}
+	}
+	if cfg.MaxIdleConns > 0 {
+		opts = append(opts, WithMaxIdleConns(cfg.MaxIdleConns))
+	}
+	if cfg.MaxIdleConnsPerHost > 0 {
+		opts = append(opts, WithMaxIdleConnsPerHost(cfg.MaxIdleConnsPerHost))
+	}
+	if cfg.MaxConnsPerHost > 0 {
+		opts = append(opts, WithMaxConnsPerHost(cfg.MaxConnsPerHost))
+	}
+	if cfg.IdleConnTimeout > 0 {
+		opts = append(opts, WithIdleConnTimeout(time.Duration(cfg.IdleConnTimeout)))
+	}
+
+	return opts, nil
+}
+
+func (c *TLSFileConfig) build() (*tls.Config, error) {
+	cfg := &tls.Config{InsecureSkipVerify: c.InsecureSkipVerify}
+	if c.CAFile != "" {
+		data, err := os.ReadFile(c.CAFile)
+		if err != nil {
+			return nil, err
+		}
+		pool := x509.NewCertPool()
+		if !pool.AppendCertsFromPEM(data) {
+			return nil, ErrInvalidConfig
+		}
+		cfg.RootCAs = pool
+	}
+
+	if c.CertFile != "" || c.KeyFile != "" {
+		cert, err := tls.LoadX509KeyPair(c.CertFile, c.KeyFile)
+		if err != nil {
+			return nil, err
+		}
+		cfg.Certificates = []tls.Certificate{cert}
+	}
+
+	return cfg, nil
+}
+
+// NewClientFromConfig 按配置创建Client,opts在配置之后应用,可以覆盖配置
+func NewClientFromConfig(cfg Config, opts ...Option) (*Client, error) {
+	all, err := cfg.Options()
+	if err != nil {
+		return nil, err
+	}
+
+	return NewClient(append(all, opts...)...), nil
+}
@@ -0,0 +1,122 @@
+package ghttp
+
+import (
+	"net/http"
+	"net/url"
+	"sync"
+)
+
+const defaultCSRFHeader = "X-CSRF-Token"
+
+// CSRF 从应答的header或cookie中提取CSRF token,并自动附加到后续POST/PUT/PATCH/DELETE请求
+// 通常在NewClient时通过WithCSRF注册,使同一个Client内的请求共享token
+type CSRF struct {
+	HeaderName string // 从应答头中提取,并附加到请求头,默认X-CSRF-Token
+	CookieName string // 非空时从同名cookie中提取,并在请求中回传该cookie
+	FieldName  string // 非空时同时以表单字段附加,仅对Client编码的application/x-www-form-urlencoded有效
+	mux        sync.RWMutex
+	token      string
+}
+
+func NewCSRF(headerName, cookieName string) *CSRF {
+	if headerName == "" {
+		headerName = defaultCSRFHeader
+	}
+	return &CSRF{HeaderName: headerName, CookieName: cookieName}
+}
+
+func (c *CSRF) Token() string {
+	c.mux.RLock()
+	defer c.mux.RUnlock()
+	return c.token
+}
+
+func (c *CSRF) SetToken(token string) {
+	c.mux.Lock()
+	c.token = token
+	c.mux.Unlock()
+}
+
+// Hook 请求前附加token,应答后提取token
+func (c *CSRF) Hook(ev *Event) error {
+	switch ev.Type {
+	case EventPrev:
+		c.attach(ev.Req)
+	case EventPost:
+		if ev.Rsp != nil {
+			c.extract(ev.Rsp)
+		}
+	}
+
+	return nil
+}
+
+func (c *CSRF) extract(rsp *Response) {
+	if c.HeaderName != "" {
+		if token := rsp.Header.Get(c.HeaderName); token != "" {
+			c.SetToken(token)
+			return
+		}
+	}
+
+	if c.CookieName != "" {
+		for _, cookie := range rsp.Cookies() {
+			if cookie.Name == c.CookieName && cookie.Value != "" {
+				c.SetToken(cookie.Value)
+				return
+			}
+		}
+	}
+}
+
+func (c *CSRF) attach(req *Request) {
+	token := c.Token()
+	if token == "" || !isMutatingMethod(req.Method) {
+		return
+	}
+
+	if c.HeaderName != "" {
+		req.Header.Set(c.HeaderName, token)
+	}
+
+	if c.CookieName != "" {
+		if _, err := req.Cookie(c.CookieName); err == http.ErrNoCookie {
+			req.AddCookie(&http.Cookie{Name: c.CookieName, Value: token})
+		}
+	}
+}
+
+// encodeField 编码表单后附加token字段,在压缩和进度统计之前执行,不修改已经处理过的body
+func (c *CSRF) encodeField(method string, body []byte) ([]byte, error) {
+	token := c.Token()
+	if c.FieldName == "" || token == "" || !isMutatingMethod(method) {
+		return body, nil
+	}
+
+	values, err := url.ParseQuery(string(body))
+	if err != nil {
+		return nil, err
+	}
+
+	// 已有同名字段时替换,否则追加,保持其他字段的原始编码
+	if _, ok := values[c.FieldName]; ok {
+		values.Set(c.FieldName, token)
+		return []byte(values.Encode()), nil
+	}
+
+	field := url.QueryEscape(c.FieldName) + "=" + url.QueryEscape(token)
+	if len(body) == 0 {
+		return []byte(field), nil
+	}
+
+	return append(body[:len(body):len(body)], "&"+field...), nil
+}
+
+func isMutatingMethod(method string) bool {
+	switch method {
+	case http.MethodPost, http.MethodPut, http.MethodPatch, http.MethodDelete:
+		return true
+	default:
+		return false
+	}
+}
@@ -0,0 +1,103 @@
+package ghttp
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"sort"
+	"strings"
+	"unicode/utf8"
+)
+
+// ToCurl 生成与请求等价的curl命令,body为nil时尝试通过GetBody读取
+// 二进制body无法作为参数传递,通过printf经管道以--data-binary @-发送
+func ToCurl(req *Request, body []byte) string {
+	if body == nil && req.GetBody != nil {
+		if rc, err := req.GetBody(); err == nil {
+			body, _ = ioutil.ReadAll(rc)
+			rc.Close()
+		}
+	}
+
+	b := &strings.Builder{}
+	b.WriteString("curl")
+
+	method := req.Method
+	if method == "" {
+		method = http.MethodGet
+	}
+	if method != http.MethodGet || len(body) > 0 {
+		fmt.Fprintf(b, " -X %s", method)
+	}
+
+	keys := make([]string, 0, len(req.Header))
+	for k := range req.Header {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	for _, k := range keys {
+		for _, v := range req.Header[k] {
+			fmt.Fprintf(b, " -H %s", shellQuote(k+": "+v))
+		}
+	}
+
+	if req.Host != "" && req.Host != req.URL.Host {
+		fmt.Fprintf(b, " -H %s", shellQuote("Host: "+req.Host))
+	}
+
+	if len(body) > 0 {
+		if isBinary(body) {
+			fmt.Fprint(b, " --data-binary @-")
+		} else {
+			fmt.Fprintf(b, " --data-binary %s", shellQuote(string(body)))
+		}
+	}
+
+	fmt.Fprintf(b, " %s", shellQuote(req.URL.String()))
+	if len(body) > 0 && isBinary(body) {
+		return "printf " + printfQuote(body) + " | " + b.String()
+	}
+
+	return b.String()
+}
+
+// CurlHook 发送请求前将curl命令写入w,用于调试
+func CurlHook(w io.Writer) Hook {
+	return func(ev *Event) error {
+		if ev.Type == EventPrev {
+			fmt.Fprintln(w, ToCurl(ev.Req, nil))
+		}
+		return nil
+	}
+}
+
+// isBinary 非utf8或者包含NUL的body
+func isBinary(body []byte) bool {
+	return !utf8.Valid(body) || bytes.IndexByte(body, 0) >= 0
+}
+
+// printfQuote 生成printf的格式串,不可打印的字节使用八进制转义
+func printfQuote(body []byte) string {
+	b := &strings.Builder{}
+	for _, c := range body {
+		switch {
+		case c == '%':
+			b.WriteString("%%")
+		case c == '\\':
+			b.WriteString(`\\`)
+		case c >= 0x20 && c < 0x7f:
+			b.WriteByte(c)
+		default:
+			fmt.Fprintf(b, "\\%03o", c)
+		}
+	}
+
+	return shellQuote(b.String())
+}
+
+// shellQuote 使用单引号转义,保证命令可以直接复制执行
+func shellQuote(s string) string {
+	return "'" + strings.Replace(s, "'", `'\''`, -1) + "'"
+}
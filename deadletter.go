@@ -0,0 +1,61 @@
+package ghttp
+
+import (
+	"net/http"
+	"time"
+)
+
+// DeadLetter 重试次数耗尽后仍然失败的请求,包含重新发送所需的全部信息
+// ghttp没有后台队列,DeadLetter在请求返回前同步产生,由调用者保存并通过Redeliver重发
+type DeadLetter struct {
+	Method   string            //
+	URL      string            //
+	Header   http.Header       //
+	Body     []byte            // 编码后的请求body
+	Attempts int               // 执行次数
+	Rsp      *Response         // 最后一次应答,可能为nil
+	Err      error             // 最后一次错误,可能为nil(如满足RetryCondition的应答)
+	Time     time.Time         // 放弃的时间
+	Datas    map[string]string // 用户扩展字段
+}
+
+// DeadLetterFunc 处理DeadLetter,如记录日志或保存后人工重发,在请求的goroutine中调用
+type DeadLetterFunc func(dl *DeadLetter)
+
+// Redeliver 重新发送DeadLetter
+func (c *Client) Redeliver(dl *DeadLetter, result interface{}, opts ...Option) (*Response, error) {
+	all := make([]Option, 0, len(dl.Header)+len(opts))
+	for k, v := range dl.Header {
+		all = append(all, WithHeader(k, v))
+	}
+	all = append(all, WithDatas(dl.Datas))
+	all = append(all, opts...)
+
+	var body interface{}
+	if dl.Body != nil {
+		body = dl.Body
+	}
+
+	return c.DoRequest(dl.Method, dl.URL, body, result, all...)
+}
+
+func newDeadLetter(req *Request, body []byte, attempts int, rsp *Response, err error, datas map[string]string) *DeadLetter {
+	header := make(http.Header, len(req.Header))
+	for k, v := range req.Header {
+		header[k] = append([]string(nil), v...)
+	}
+	// Body保存的是压缩前的数据
+	header.Del("Content-Encoding")
+
+	return &DeadLetter{
+		Method:   req.Method,
+		URL:      req.URL.String(),
+		Header:   header,
+		Body:     body,
+		Attempts: attempts,
+		Rsp:      rsp,
+		Err:      err,
+		Time:     time.Now(),
+		Datas:    datas,
+	}
+}
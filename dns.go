@@ -0,0 +1,98 @@
+package ghttp
+
+import (
+	"context"
+	"net"
+	"sync"
+	"time"
+)
+
+const defaultDNSCacheTTL = time.Minute
+
+// HostResolver 解析域名,*net.Resolver实现了此接口,也可以是DNS-over-HTTPS等实现
+type HostResolver interface {
+	LookupHost(ctx context.Context, host string) ([]string, error)
+}
+
+// DNSCache 进程内的DNS缓存,避免高QPS时每次建立连接都解析域名
+// net.Resolver不返回记录的TTL,因此所有记录使用相同的TTL
+type DNSCache struct {
+	resolver HostResolver
+	ttl      time.Duration
+	mutex    sync.Mutex
+	entries  map[string]dnsEntry
+}
+
+type dnsEntry struct {
+	addrs  []string
+	expire time.Time
+}
+
+// NewDNSCache 创建DNSCache,resolver为nil时使用net.DefaultResolver,ttl<=0时默认1分钟
+func NewDNSCache(resolver HostResolver, ttl time.Duration) *DNSCache {
+	if resolver == nil {
+		resolver = net.DefaultResolver
+	}
+	if ttl <= 0 {
+		ttl = defaultDNSCacheTTL
+	}
+
+	return &DNSCache{resolver: resolver, ttl: ttl, entries: make(map[string]dnsEntry)}
+}
+
+// LookupHost 优先使用缓存,过期后重新解析,解析失败不缓存
+func (c *DNSCache) LookupHost(ctx context.Context, host string) ([]string, error) {
+	if net.ParseIP(host) != nil {
+		return []string{host}, nil
+	}
+
+	now := time.Now()
+	c.mutex.Lock()
+	entry, ok := c.entries[host]
+	c.mutex.Unlock()
+	if ok && now.Before(entry.expire) {
+		return entry.addrs, nil
+	}
+
+	addrs, err := c.resolver.LookupHost(ctx, host)
+	if err != nil {
+		return nil, err
+	}
+
+	c.mutex.Lock()
+	c.entries[host] = dnsEntry{addrs: addrs, expire: now.Add(c.ttl)}
+	c.mutex.Unlock()
+	return addrs, nil
+}
+
+// Clear 清空缓存
+func (c *DNSCache) Clear() {
+	c.mutex.Lock()
+	c.entries = make(map[string]dnsEntry)
+	c.mutex.Unlock()
+}
+
+// wrap 先通过缓存解析域名,再依次尝试每个地址
+func (c *DNSCache) wrap(dial DialFunc) DialFunc {
+	return func(ctx context.Context, network, addr string) (net.Conn, error) {
+		host, port, err := net.SplitHostPort(addr)
+		if err != nil {
+			return nil, err
+		}
+
+		ips, err := c.LookupHost(ctx, host)
+		if err != nil {
+			return nil, err
+		}
+
+		for _, ip := range ips {
+			var conn net.Conn
+			conn, err = dial(ctx, network, net.JoinHostPort(ip, port))
+			if err == nil {
+				return conn, nil
+			}
+		}
+
+		return nil, err
+	}
+}
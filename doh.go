@@ -0,0 +1,114 @@
+package ghttp
+
+import (
+	"context"
+	"encoding/base64"
+	"net"
+	"sync"
+
+	"golang.org/x/net/dns/dnsmessage"
+)
+
+const typeDNSMessage = "application/dns-message"
+
+// DoHResolver 通过DNS-over-HTTPS(RFC 8484)解析域名,用于明文DNS被屏蔽或不可信的环境
+// 请求通过ghttp发送,Bootstrap不为空时直接连接此地址,不需要解析DoH服务器的域名
+type DoHResolver struct {
+	URL       string // DoH服务器地址,如https://1.1.1.1/dns-query
+	Bootstrap string // DoH服务器的ip:port,为空时使用URL中的host
+	once      sync.Once
+	client    *Client
+}
+
+// NewDoHResolver 创建DoHResolver
+func NewDoHResolver(serverURL string) *DoHResolver {
+	return &DoHResolver{URL: serverURL}
+}
+
+func (r *DoHResolver) getClient() *Client {
+	r.once.Do(func() {
+		dialer := &net.Dialer{}
+		r.client = NewClient(WithDialContext(func(ctx context.Context, network, addr string) (net.Conn, error) {
+			if r.Bootstrap != "" {
+				addr = r.Bootstrap
+			}
+			return dialer.DialContext(ctx, network, addr)
+		}))
+	})
+
+	return r.client
+}
+
+// LookupHost 同时查询A和AAAA记录
+func (r *DoHResolver) LookupHost(ctx context.Context, host string) ([]string, error) {
+	var addrs []string
+	var lastErr error
+	for _, typ := range []dnsmessage.Type{dnsmessage.TypeA, dnsmessage.TypeAAAA} {
+		result, err := r.query(ctx, host, typ)
+		if err != nil {
+			lastErr = err
+			continue
+		}
+		addrs = append(addrs, result...)
+	}
+
+	if len(addrs) == 0 {
+		if lastErr == nil {
+			lastErr = ErrNoDNSAnswer
+		}
+		return nil, &net.DNSError{Err: lastErr.Error(), Name: host, Server: r.URL}
+	}
+
+	return addrs, nil
+}
+
+func (r *DoHResolver) query(ctx context.Context, host string, typ dnsmessage.Type) ([]string, error) {
+	name, err := dnsmessage.NewName(dnsName(host))
+	if err != nil {
+		return nil, err
+	}
+
+	msg := dnsmessage.Message{
+		Header:    dnsmessage.Header{RecursionDesired: true},
+		Questions: []dnsmessage.Question{{Name: name, Type: typ, Class: dnsmessage.ClassINET}},
+	}
+	packed, err := msg.Pack()
+	if err != nil {
+		return nil, err
+	}
+
+	var data []byte
+	_, err = r.getClient().Get(r.URL, &data,
+		WithContext(ctx),
+		WithQuery("dns", base64.RawURLEncoding.EncodeToString(packed)),
+		WithHeader("Accept", typeDNSMessage))
+	if err != nil {
+		return nil, err
+	}
+
+	var reply dnsmessage.Message
+	if err := reply.Unpack(data); err != nil {
+		return nil, err
+	}
+
+	var addrs []string
+	for _, answer := range reply.Answers {
+		switch body := answer.Body.(type) {
+		case *dnsmessage.AResource:
+			addrs = append(addrs, net.IP(body.A[:]).String())
+		case *dnsmessage.AAAAResource:
+			addrs = append(addrs, net.IP(body.AAAA[:]).String())
+		}
+	}
+
+	return addrs, nil
+}
+
+// dnsName 转为以.结尾的完整域名
+func dnsName(host string) string {
+	if len(host) > 0 && host[len(host)-1] == '.' {
+		return host
+	}
+
+	return host + "."
+}
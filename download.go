@@ -0,0 +1,129 @@
+package ghttp
+
+import (
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+)
+
+const etagSuffix = ".etag"
+
+// DownloadResume 下载到path,如果存在未完成的文件则通过Range续传
+// ETag保存在path.etag中,用于If-Range校验,与文件一起保留,再次下载时服务器文件未变化则直接返回
+// 已有文件但没有ETag时无法校验,重新下载整个文件
+// 传输中断时按Retry和Backoff自动重试
+func (c *Client) DownloadResume(url string, path string, opts ...Option) error {
+	o := c.buildOptions(opts...)
+	for i := 0; ; i++ {
+		err := c.downloadOnce(url, path, opts)
+		if err == nil {
+			return nil
+		}
+
+		// 状态码错误重试也无法恢复
+		if IsStatusErr(err) || i >= o.Retry {
+			return err
+		}
+
+		select {
+		case <-o.Context.Done():
+			return o.Context.Err()
+		case <-time.After(o.retryWait(nil)):
+		}
+	}
+}
+
+func (c *Client) downloadOnce(url string, path string, opts []Option) error {
+	etagPath := path + etagSuffix
+	var offset int64
+	etag := ""
+	if fi, err := os.Stat(path); err == nil {
+		offset = fi.Size()
+		if data, err := ioutil.ReadFile(etagPath); err == nil {
+			etag = strings.TrimSpace(string(data))
+		}
+	}
+
+	all := make([]Option, 0, len(opts)+3)
+	all = append(all, opts...)
+	// 重试由DownloadResume控制
+	all = append(all, WithRetry(0))
+	// 没有ETag时无法确认已有的部分是否属于同一个文件,不续传
+	if offset > 0 && etag != "" {
+		all = append(all, WithHeader("Range", fmt.Sprintf("bytes=%d-", offset)))
+		all = append(all, WithHeader("If-Range", etag))
+	}
+
+	rsp, err := c.Get(url, nil, all...)
+	if err != nil {
+		// 文件已经完整
+		if se, ok := AsStatusErr(err); ok && se.Code == http.StatusRequestedRangeNotSatisfiable && offset > 0 && etag != "" {
+			return nil
+		}
+		return err
+	}
+	defer rsp.Body.Close()
+
+	flag := os.O_CREATE | os.O_WRONLY
+	switch rsp.StatusCode {
+	case http.StatusPartialContent:
+		if start, ok := parseContentRangeStart(rsp.Header.Get("Content-Range")); !ok || start != offset {
+			return fmt.Errorf("unexpected content range: %s", rsp.Header.Get("Content-Range"))
+		}
+		if newTag := rsp.Header.Get("ETag"); etag != "" && newTag != "" && newTag != etag {
+			// 文件已经变化,丢弃已下载的部分
+			os.Remove(path)
+			os.Remove(etagPath)
+			return ErrETagMismatch
+		}
+		flag |= os.O_APPEND
+	default:
+		// 服务器不支持Range或文件已变化,重新下载
+		flag |= os.O_TRUNC
+		if newTag := rsp.Header.Get("ETag"); newTag != "" {
+			if err := ioutil.WriteFile(etagPath, []byte(newTag), 0644); err != nil {
+				return err
+			}
+		} else {
+			os.Remove(etagPath)
+		}
+	}
+
+	f, err := os.OpenFile(path, flag, 0644)
+	if err != nil {
+		return err
+	}
+
+	if _, err := io.Copy(f, rsp.Body); err != nil {
+		f.Close()
+		return err
+	}
+
+	return f.Close()
+}
+
+// parseContentRangeStart 解析Content-Range: bytes start-end/total中的start
+func parseContentRangeStart(value string) (int64, bool) {
+	value = strings.TrimSpace(value)
+	if !strings.HasPrefix(value, "bytes ") {
+		return 0, false
+	}
+
+	value = strings.TrimPrefix(value, "bytes ")
+	idx := strings.IndexByte(value, '-')
+	if idx == -1 {
+		return 0, false
+	}
+
+	start, err := strconv.ParseInt(value[:idx], 10, 64)
+	if err != nil {
+		return 0, false
+	}
+
+	return start, true
+}
@@ -0,0 +1,481 @@
+package ghttp
+
+import (
+	"archive/tar"
+	"archive/zip"
+	"bytes"
+	"compress/flate"
+	"compress/gzip"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func TestDownloadResume(t *testing.T) {
+	content := bytes.Repeat([]byte("0123456789"), 1000)
+	var version atomic.Value
+	version.Store(content)
+	var calls int32
+	var ranges []string
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		data := version.Load().([]byte)
+		w.Header().Set("ETag", fmt.Sprintf(`"v%d"`, len(data)))
+		ranges = append(ranges, r.Header.Get("Range"))
+		// 第一次请求只发送一半后断开
+		if atomic.AddInt32(&calls, 1) == 1 {
+			w.Header().Set("Content-Length", "10000")
+			w.Write(data[:5000])
+			hj, _ := w.(http.Hijacker)
+			conn, _, _ := hj.Hijack()
+			conn.Close()
+			return
+		}
+		http.ServeContent(w, r, "file", time.Time{}, bytes.NewReader(data))
+	}))
+	defer srv.Close()
+
+	path := filepath.Join(t.TempDir(), "file")
+	check := func(want []byte) {
+		t.Helper()
+		data, _ := os.ReadFile(path)
+		if !bytes.Equal(data, want) {
+			t.Fatalf("content mismatch, size=%v", len(data))
+		}
+	}
+
+	if err := DownloadResume(srv.URL, path, WithRetry(1), WithBackoff(NewConstantBackoff(time.Millisecond))); err != nil {
+		t.Fatal(err)
+	}
+	check(content)
+	if len(ranges) != 2 || ranges[1] != "bytes=5000-" {
+		t.Errorf("expect resume from 5000, got %q", ranges)
+	}
+
+	// ETag与文件一起保留,已完整的文件服务器返回416
+	if _, err := os.Stat(path + etagSuffix); err != nil {
+		t.Fatal("etag file should be kept")
+	}
+	if err := DownloadResume(srv.URL, path); err != nil {
+		t.Fatal(err)
+	}
+	check(content)
+
+	// 服务器文件变化后If-Range不匹配,重新下载并截断
+	changed := []byte("changed")
+	version.Store(changed)
+	if err := DownloadResume(srv.URL, path); err != nil {
+		t.Fatal(err)
+	}
+	check(changed)
+
+	// 已有文件但没有ETag时不续传,重新下载
+	os.WriteFile(path, []byte("stale data"), 0644)
+	os.Remove(path + etagSuffix)
+	ranges = nil
+	if err := DownloadResume(srv.URL, path); err != nil {
+		t.Fatal(err)
+	}
+	check(changed)
+	if len(ranges) != 1 || ranges[0] != "" {
+		t.Errorf("expect plain GET without Range, got %q", ranges)
+	}
+}
+
+func TestMaxResponseBytes(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", TypeText)
+		w.Header().Set("Cache-Control", "max-age=60")
+		w.Header().Set("ETag", `"v1"`)
+		if r.URL.Path == "/chunked" {
+			// 没有Content-Length,只能在读取时判断
+			w.Write(bytes.Repeat([]byte("a"), 100))
+			w.(http.Flusher).Flush()
+		}
+		w.Write(bytes.Repeat([]byte("a"), 200))
+	}))
+	defer srv.Close()
+
+	for _, path := range []string{"/length", "/chunked"} {
+		var data []byte
+		if _, err := Get(srv.URL+path, &data, WithMaxResponseBytes(150)); err != ErrResponseTooLarge {
+			t.Errorf("%v: expect ErrResponseTooLarge, got %v", path, err)
+		}
+	}
+
+	var data []byte
+	if _, err := Get(srv.URL+"/length", &data, WithMaxResponseBytes(200)); err != nil || len(data) != 200 {
+		t.Errorf("unexpected result: %v, %v", len(data), err)
+	}
+
+	// 缓存,条件请求和合并请求读取body前同样限制大小
+	storage := NewMemoryStorage(0)
+	for _, opt := range []Option{WithCache(storage), WithConditional(storage), WithSingleflight()} {
+		c := NewClient(opt, WithMaxResponseBytes(150))
+		if _, err := c.Get(srv.URL+"/chunked", nil); !errors.Is(err, ErrResponseTooLarge) {
+			t.Errorf("expect ErrResponseTooLarge, got %v", err)
+		}
+	}
+	if _, ok := storage.Get(cacheKey(httptest.NewRequest(http.MethodGet, srv.URL+"/chunked", nil))); ok {
+		t.Errorf("oversize response cached")
+	}
+}
+
+func TestCompressionInfo(t *testing.T) {
+	content := bytes.Repeat([]byte("0123456789"), 1000)
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", TypeText)
+		if r.URL.Path == "/plain" {
+			w.Write(content)
+			return
+		}
+		// chunked + gzip,没有Content-Length
+		w.Header().Set("Content-Encoding", EncodingGzip)
+		gw := gzip.NewWriter(w)
+		gw.Write(content[:5000])
+		gw.Flush()
+		w.(http.Flusher).Flush()
+		gw.Write(content[5000:])
+		gw.Close()
+	}))
+	defer srv.Close()
+
+	var data []byte
+	info := &CompressionInfo{}
+	if _, err := Get(srv.URL+"/gzip", &data, WithCompressionInfo(info)); err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(data, content) || !info.Compressed || info.Encoding != EncodingGzip ||
+		info.UncompressedSize != int64(len(content)) || info.CompressedSize <= 0 || info.CompressedSize >= info.UncompressedSize {
+		t.Errorf("unexpected info: %+v", info)
+	}
+
+	if _, err := Get(srv.URL+"/plain", &data, WithCompressionInfo(info)); err != nil {
+		t.Fatal(err)
+	}
+	if info.Compressed || info.CompressedSize != int64(len(content)) || info.UncompressedSize != int64(len(content)) {
+		t.Errorf("unexpected info: %+v", info)
+	}
+}
+
+func TestRequestCompressionFallback(t *testing.T) {
+	var calls, compressed int
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		calls++
+		if r.Header.Get("Content-Encoding") != "" {
+			compressed++
+			w.WriteHeader(http.StatusUnsupportedMediaType)
+			return
+		}
+		w.Header().Set("Content-Type", TypeJSON)
+		io.Copy(w, r.Body)
+	}))
+	defer srv.Close()
+
+	c := NewClient(WithRequestCompression(EncodingGzip))
+	for i := 0; i < 2; i++ {
+		var result map[string]int
+		if _, err := c.Post(srv.URL, map[string]int{"a": 1}, &result); err != nil || result["a"] != 1 {
+			t.Fatalf("unexpected result: %v, %v", result, err)
+		}
+	}
+
+	if calls != 3 || compressed != 1 {
+		t.Errorf("unexpected calls: %v, compressed: %v", calls, compressed)
+	}
+}
+
+func TestMmapBody(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		data, _ := io.ReadAll(r.Body)
+		w.Header().Set("X-Content-Type", r.Header.Get("Content-Type"))
+		w.Write(data)
+	}))
+	defer srv.Close()
+
+	path := filepath.Join(t.TempDir(), "upload.bin")
+	content := bytes.Repeat([]byte("0123456789"), 1000)
+	if err := os.WriteFile(path, content, 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	var echo []byte
+	rsp, err := NewClient().Post(srv.URL, nil, &echo, WithMmapBody(path))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(echo, content) || rsp.Header.Get("X-Content-Type") != TypeOctetStream {
+		t.Errorf("unexpected echo: %d bytes, %s", len(echo), rsp.Header.Get("X-Content-Type"))
+	}
+
+	m, err := openMapped(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	r := m.reader()
+	m.release()
+	if !m.mapped {
+		t.Errorf("unmapped while reader is open")
+	}
+	r.Close()
+	r.Close()
+	if m.mapped || m.refs != 0 {
+		t.Errorf("unexpected state: mapped=%v, refs=%d", m.mapped, m.refs)
+	}
+}
+
+func TestBodyFile(t *testing.T) {
+	var calls int32
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		data, _ := io.ReadAll(r.Body)
+		if atomic.AddInt32(&calls, 1) == 1 {
+			w.WriteHeader(http.StatusServiceUnavailable)
+			return
+		}
+		w.Header().Set("X-Content-Type", r.Header.Get("Content-Type"))
+		w.Header().Set("X-Content-Length", strconv.FormatInt(r.ContentLength, 10))
+		w.Write(data)
+	}))
+	defer srv.Close()
+
+	dir := t.TempDir()
+	path := filepath.Join(dir, "report.json")
+	content := []byte(`{"name":"report"}`)
+	if err := os.WriteFile(path, content, 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	// 第一次失败后重新打开文件发送
+	var echo []byte
+	rsp, err := NewClient().Put(srv.URL, nil, &echo, WithBodyFile(path), WithRetry(1), WithBackoff(nil),
+		WithRetryCondition(func(rsp *Response, body []byte, err error) bool { return rsp != nil && rsp.StatusCode == 503 }))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(echo, content) || rsp.Header.Get("X-Content-Type") != TypeJSON || rsp.Header.Get("X-Content-Length") != strconv.Itoa(len(content)) {
+		t.Errorf("unexpected echo: %s, %v", echo, rsp.Header)
+	}
+
+	rsp, err = NewClient().Post(srv.URL, BodyFromFile(filepath.Join(dir, "report.json")), nil, WithContentType(TypeText))
+	if err != nil || rsp.Header.Get("X-Content-Type") != TypeText {
+		t.Errorf("unexpected result: %v", err)
+	}
+
+	if _, err := NewClient().Post(srv.URL, BodyFromFile(dir), nil); !errors.Is(err, ErrNotSupport) {
+		t.Errorf("expect ErrNotSupport for directory, got %v", err)
+	}
+}
+
+func TestDecompress(t *testing.T) {
+	content := bytes.Repeat([]byte("0123456789"), 1000)
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		enc := strings.TrimPrefix(r.URL.Path, "/")
+		switch enc {
+		case "nocontent":
+			w.Header().Set("Content-Encoding", EncodingGzip)
+			w.WriteHeader(http.StatusNoContent)
+			return
+		case "empty":
+			w.Header().Set("Content-Encoding", EncodingGzip)
+			return
+		case "raw-deflate":
+			w.Header().Set("Content-Encoding", EncodingDeflate)
+			fw, _ := flate.NewWriter(w, flate.DefaultCompression)
+			fw.Write(content)
+			fw.Close()
+			return
+		}
+
+		data, err := compress(enc, content)
+		if err != nil {
+			w.WriteHeader(http.StatusBadRequest)
+			return
+		}
+		w.Header().Set("Content-Encoding", enc)
+		w.Write(data)
+	}))
+	defer srv.Close()
+
+	c := NewClient(WithCompression(EncodingGzip, EncodingDeflate, EncodingBrotli, EncodingZstd))
+	for _, enc := range []string{EncodingGzip, EncodingDeflate, EncodingBrotli, EncodingZstd, "raw-deflate"} {
+		var data []byte
+		info := &CompressionInfo{}
+		if _, err := c.Get(srv.URL+"/"+enc, &data, WithCompressionInfo(info)); err != nil {
+			t.Fatalf("%s: %v", enc, err)
+		}
+		if !bytes.Equal(data, content) || !info.Compressed || info.UncompressedSize != int64(len(content)) {
+			t.Errorf("%s: unexpected result: %d bytes, %+v", enc, len(data), info)
+		}
+	}
+
+	// 没有body的应答不解压
+	for _, path := range []string{"/nocontent", "/empty"} {
+		var data []byte
+		if _, err := c.Get(srv.URL+path, &data); err != nil && err != ErrNoData {
+			t.Errorf("%s: %v", path, err)
+		}
+	}
+	if _, err := c.DoRequest(http.MethodHead, srv.URL+"/"+EncodingGzip, nil, nil); err != nil {
+		t.Errorf("head: %v", err)
+	}
+}
+
+type archiveEntry struct {
+	name string
+	body string
+	link string // 非空时为符号链接
+}
+
+func buildTarGz(t *testing.T, entries []archiveEntry) []byte {
+	buf := &bytes.Buffer{}
+	gz := gzip.NewWriter(buf)
+	tw := tar.NewWriter(gz)
+	for _, e := range entries {
+		hdr := &tar.Header{Name: e.name, Mode: 0644, Size: int64(len(e.body)), Typeflag: tar.TypeReg}
+		if e.link != "" {
+			hdr.Typeflag = tar.TypeSymlink
+			hdr.Linkname = e.link
+			hdr.Size = 0
+		}
+		if err := tw.WriteHeader(hdr); err != nil {
+			t.Fatal(err)
+		}
+		if e.link == "" {
+			tw.Write([]byte(e.body))
+		}
+	}
+	tw.Close()
+	gz.Close()
+	return buf.Bytes()
+}
+
+func buildZip(t *testing.T, entries []archiveEntry) []byte {
+	buf := &bytes.Buffer{}
+	zw := zip.NewWriter(buf)
+	for _, e := range entries {
+		hdr := &zip.FileHeader{Name: e.name, Method: zip.Deflate}
+		hdr.SetMode(0644)
+		body := e.body
+		if e.link != "" {
+			hdr.SetMode(os.ModeSymlink | 0777)
+			body = e.link
+		}
+		w, err := zw.CreateHeader(hdr)
+		if err != nil {
+			t.Fatal(err)
+		}
+		w.Write([]byte(body))
+	}
+	zw.Close()
+	return buf.Bytes()
+}
+
+func TestDownloadAndExtract(t *testing.T) {
+	var archive atomic.Value
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write(archive.Load().([]byte))
+	}))
+	defer srv.Close()
+
+	formats := map[string]func(*testing.T, []archiveEntry) []byte{
+		"tar.gz": buildTarGz,
+		"zip":    buildZip,
+	}
+
+	cases := []struct {
+		name    string
+		entries []archiveEntry
+		opts    []Option
+		err     error
+		files   []string
+	}{
+		{
+			name:    "normal",
+			entries: []archiveEntry{{name: "a.txt", body: "a"}, {name: "sub/b.txt", body: "b"}},
+			files:   []string{"a.txt", "sub/b.txt"},
+		},
+		{
+			name:    "dotdot",
+			entries: []archiveEntry{{name: "../evil.txt", body: "evil"}},
+			err:     ErrInvalidArchivePath,
+		},
+		{
+			name:    "nested dotdot",
+			entries: []archiveEntry{{name: "sub/../../evil.txt", body: "evil"}},
+			err:     ErrInvalidArchivePath,
+		},
+		{
+			name:    "absolute",
+			entries: []archiveEntry{{name: "/evil.txt", body: "evil"}},
+			err:     ErrInvalidArchivePath,
+		},
+		{
+			name: "symlink",
+			entries: []archiveEntry{
+				{name: "link", link: "../"},
+				{name: "abs", link: "/"},
+				{name: "ok.txt", body: "ok"},
+			},
+			files: []string{"ok.txt"},
+		},
+		{
+			name:    "oversize",
+			entries: []archiveEntry{{name: "small.txt", body: "12345"}, {name: "big.txt", body: strings.Repeat("x", 100)}},
+			opts:    []Option{WithMaxExtractSize(50)},
+			err:     ErrResponseTooLarge,
+		},
+	}
+
+	for format, build := range formats {
+		for _, tc := range cases {
+			t.Run(format+"/"+tc.name, func(t *testing.T) {
+				root := t.TempDir()
+				dir := filepath.Join(root, "dst")
+				archive.Store(build(t, tc.entries))
+
+				err := DownloadAndExtract(srv.URL, dir, tc.opts...)
+				if tc.err != nil {
+					if !errors.Is(err, tc.err) {
+						t.Fatalf("want %v, got %v", tc.err, err)
+					}
+				} else if err != nil {
+					t.Fatal(err)
+				}
+
+				// dst之外不能有任何文件
+				items, _ := os.ReadDir(root)
+				if len(items) != 1 || items[0].Name() != "dst" {
+					t.Fatalf("written outside dst: %v", items)
+				}
+				if _, err := os.Stat(filepath.Join(filepath.Dir(root), "evil.txt")); err == nil {
+					t.Fatal("written outside temp dir")
+				}
+
+				// 符号链接不能被创建,超限的文件不能残留
+				filepath.WalkDir(dir, func(path string, d os.DirEntry, err error) error {
+					if err == nil && d.Type()&os.ModeSymlink != 0 {
+						t.Errorf("symlink created: %v", path)
+					}
+					return nil
+				})
+				if _, err := os.Stat(filepath.Join(dir, "big.txt")); err == nil {
+					t.Error("oversize member should be removed")
+				}
+
+				for _, name := range tc.files {
+					if _, err := os.Stat(filepath.Join(dir, name)); err != nil {
+						t.Errorf("missing %v: %v", name, err)
+					}
+				}
+			})
+		}
+	}
+}
@@ -0,0 +1,240 @@
+package ghttp
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"mime"
+	"net/http"
+	"net/url"
+	"sort"
+	"strings"
+	"unicode/utf8"
+)
+
+const (
+	redacted        = "[REDACTED]"
+	maxDumpBodySize = 1024
+)
+
+// DefaultRedactKeys Dump时默认隐藏值的header,query参数,表单和json字段,不区分大小写
+var DefaultRedactKeys = []string{
+	"Authorization", "Proxy-Authorization", "Cookie", "Set-Cookie", "X-Auth-Token", "X-Jwt-Token", "X-Api-Key",
+	"token", "access_token", "refresh_token", "api_key", "apikey", "password", "secret", "sign", "signature",
+}
+
+// DumpRequest 生成可读的请求描述,按Redact隐藏敏感的header,query参数和body字段,可以用于错误信息和问题报告
+// body为nil时尝试通过GetBody读取,过长时截断
+func (o *Options) DumpRequest(req *Request, body []byte) string {
+	b := &strings.Builder{}
+	dumpRequest(b, req, body, o.Redact)
+	return b.String()
+}
+
+// DumpResponse 生成可读的应答描述,按Redact隐藏敏感的header和body字段,body需要调用者传入,过长时截断
+func (o *Options) DumpResponse(rsp *Response, body []byte) string {
+	b := &strings.Builder{}
+	dumpResponse(b, rsp, body, o.Redact)
+	return b.String()
+}
+
+// Dump 生成最后一次发送的请求和每次执行结果的描述,隐藏敏感信息
+func (info *RequestInfo) Dump() string {
+	b := &strings.Builder{}
+	if info.Request != nil {
+		dumpRequest(b, info.Request, nil, info.redact)
+	}
+	for i, a := range info.Attempts {
+		if a.Err != nil {
+			fmt.Fprintf(b, "#%d %v error: %v\n", i+1, a.Duration, a.Err)
+		} else {
+			fmt.Fprintf(b, "#%d %v status: %d\n", i+1, a.Duration, a.Status)
+		}
+	}
+	return b.String()
+}
+
+// Dump 生成请求和应答头的描述,隐藏敏感信息,应答body已经读取,不包含在内
+func (r *Result) Dump() string {
+	b := &strings.Builder{}
+	if r.Response != nil {
+		if r.Response.Request != nil {
+			dumpRequest(b, r.Response.Request, nil, r.redact)
+			b.WriteString("\n")
+		}
+		dumpResponse(b, r.Response, nil, r.redact)
+	}
+	if r.Err != nil {
+		fmt.Fprintf(b, "error: %v\n", r.Err)
+	}
+	return b.String()
+}
+
+func dumpRequest(b *strings.Builder, req *Request, body []byte, keys []string) {
+	if body == nil && req.GetBody != nil {
+		if rc, err := req.GetBody(); err == nil {
+			body, _ = ioutil.ReadAll(rc)
+			rc.Close()
+		}
+	}
+
+	method := req.Method
+	if method == "" {
+		method = http.MethodGet
+	}
+
+	keys = redactKeys(keys)
+	fmt.Fprintf(b, "%s %s\n", method, redactURL(req, keys))
+	dumpHeader(b, req.Header, keys)
+	dumpBody(b, redactBody(req.Header.Get("Content-Type"), body, keys))
+}
+
+func dumpResponse(b *strings.Builder, rsp *Response, body []byte, keys []string) {
+	keys = redactKeys(keys)
+	fmt.Fprintf(b, "%s %s\n", rsp.Proto, rsp.Status)
+	dumpHeader(b, rsp.Header, keys)
+	dumpBody(b, redactBody(rsp.Header.Get("Content-Type"), body, keys))
+}
+
+func redactKeys(keys []string) []string {
+	if keys == nil {
+		return DefaultRedactKeys
+	}
+
+	return keys
+}
+
+func isRedactKey(keys []string, key string) bool {
+	for _, k := range keys {
+		if strings.EqualFold(k, key) {
+			return true
+		}
+	}
+
+	return false
+}
+
+func redactURL(req *Request, keys []string) string {
+	if req.URL == nil {
+		return ""
+	}
+
+	u := *req.URL
+	u.User = nil
+	if u.RawQuery != "" {
+		query := u.Query()
+		if redactValues(query, keys) {
+			u.RawQuery = query.Encode()
+		}
+	}
+
+	return u.String()
+}
+
+func redactValues(values url.Values, keys []string) bool {
+	found := false
+	for k := range values {
+		if isRedactKey(keys, k) {
+			values[k] = []string{redacted}
+			found = true
+		}
+	}
+
+	return found
+}
+
+// redactBody 隐藏表单和json中的敏感字段,其他格式或解析失败时原样返回
+func redactBody(contentType string, body []byte, keys []string) []byte {
+	if len(body) == 0 {
+		return body
+	}
+
+	mediaType, _, _ := mime.ParseMediaType(contentType)
+	switch {
+	case mediaType == TypeForm:
+		values, err := url.ParseQuery(string(body))
+		if err == nil && redactValues(values, keys) {
+			return []byte(values.Encode())
+		}
+	case mediaType == TypeJSON || strings.HasSuffix(mediaType, "+json"):
+		var v interface{}
+		d := json.NewDecoder(bytes.NewReader(body))
+		d.UseNumber()
+		if d.Decode(&v) == nil && redactJSON(v, keys) {
+			buf := &bytes.Buffer{}
+			e := json.NewEncoder(buf)
+			e.SetEscapeHTML(false)
+			if e.Encode(v) == nil {
+				return bytes.TrimSuffix(buf.Bytes(), []byte("\n"))
+			}
+		}
+	}
+
+	return body
+}
+
+func redactJSON(v interface{}, keys []string) bool {
+	found := false
+	switch x := v.(type) {
+	case map[string]interface{}:
+		for k, item := range x {
+			if isRedactKey(keys, k) {
+				x[k] = redacted
+				found = true
+			} else if redactJSON(item, keys) {
+				found = true
+			}
+		}
+	case []interface{}:
+		for _, item := range x {
+			if redactJSON(item, keys) {
+				found = true
+			}
+		}
+	}
+
+	return found
+}
+
+func dumpHeader(b *strings.Builder, header http.Header, keys []string) {
+	names := make([]string, 0, len(header))
+	for k := range header {
+		names = append(names, k)
+	}
+	sort.Strings(names)
+
+	for _, k := range names {
+		for _, v := range header[k] {
+			if isRedactKey(keys, k) {
+				v = redacted
+			}
+			fmt.Fprintf(b, "%s: %s\n", k, v)
+		}
+	}
+}
+
+func dumpBody(b *strings.Builder, body []byte) {
+	if len(body) == 0 {
+		return
+	}
+
+	b.WriteString("\n")
+	if !utf8.Valid(body) {
+		fmt.Fprintf(b, "<%d bytes binary>\n", len(body))
+		return
+	}
+
+	if len(body) > maxDumpBodySize {
+		// 截断位置不能在多字节字符的中间
+		n := maxDumpBodySize
+		for n > 0 && !utf8.RuneStart(body[n]) {
+			n--
+		}
+		fmt.Fprintf(b, "%s... (%d bytes)\n", body[:n], len(body))
+		return
+	}
+
+	b.Write(body)
+	b.WriteString("\n")
+}
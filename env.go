@@ -0,0 +1,63 @@
+package ghttp
+
+import (
+	"os"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// 环境变量的前缀,如GHTTP_TIMEOUT
+const envPrefix = "GHTTP_"
+
+// WithEnvDefaults 从环境变量读取配置,便于运维不重新发布即可调整,需要显式开启
+// 支持GHTTP_BASE_URL,GHTTP_TIMEOUT,GHTTP_OVERALL_TIMEOUT,GHTTP_DIAL_TIMEOUT,GHTTP_RETRY,GHTTP_BACKOFF,
+// GHTTP_PROXY,GHTTP_USER_AGENT,GHTTP_MAX_IDLE_CONNS,GHTTP_MAX_IDLE_CONNS_PER_HOST,GHTTP_MAX_CONNS_PER_HOST,GHTTP_IDLE_CONN_TIMEOUT
+// 时长为"1.5s"格式或者表示秒的数字,含义同Config,格式错误的值会被忽略
+// 在当前位置生效,放在最前面时作为默认值,之后的Option可以覆盖
+// 调用时读取环境变量,之后的请求使用相同的值
+func WithEnvDefaults() Option {
+	cfg := envConfig()
+	opts, _ := cfg.Options()
+	return func(o *Options) {
+		for _, fn := range opts {
+			fn(o)
+		}
+	}
+}
+
+func envConfig() Config {
+	cfg := Config{}
+	cfg.BaseURL = os.Getenv(envPrefix + "BASE_URL")
+	cfg.Timeout = envDuration("TIMEOUT")
+	cfg.OverallTimeout = envDuration("OVERALL_TIMEOUT")
+	cfg.DialTimeout = envDuration("DIAL_TIMEOUT")
+	cfg.Retry = envInt("RETRY")
+	cfg.Backoff = envDuration("BACKOFF")
+	cfg.Proxy = os.Getenv(envPrefix + "PROXY")
+	cfg.UserAgent = os.Getenv(envPrefix + "USER_AGENT")
+	cfg.MaxIdleConns = envInt("MAX_IDLE_CONNS")
+	cfg.MaxIdleConnsPerHost = envInt("MAX_IDLE_CONNS_PER_HOST")
+	cfg.MaxConnsPerHost = envInt("MAX_CONNS_PER_HOST")
+	cfg.IdleConnTimeout = envDuration("IDLE_CONN_TIMEOUT")
+	return cfg
+}
+
+func envInt(key string) int {
+	v, _ := strconv.Atoi(strings.TrimSpace(os.Getenv(envPrefix + key)))
+	return v
+}
+
+func envDuration(key string) Duration {
+	val := strings.TrimSpace(os.Getenv(envPrefix + key))
+	if val == "" {
+		return 0
+	}
+
+	if sec, err := strconv.ParseFloat(val, 64); err == nil {
+		return Duration(sec * float64(time.Second))
+	}
+
+	d, _ := time.ParseDuration(val)
+	return Duration(d)
+}
@@ -0,0 +1,141 @@
+package ghttp
+
+import (
+	"context"
+	"crypto/tls"
+	"crypto/x509"
+	"errors"
+	"net"
+	"net/http"
+	"syscall"
+)
+
+// NetError 按原因分类的网络错误,通过errors.Is(err, ErrTimeout)等判断分类,errors.As可以获取原始错误
+type NetError struct {
+	Kind  error  // ErrTimeout,ErrDNS,ErrConnectionRefused,ErrTLS或ErrCanceled
+	Err   error  // 原始错误
+	ReqID string // WithRequestID时的请求ID
+}
+
+func (e *NetError) Error() string {
+	if e.ReqID != "" {
+		return e.Err.Error() + ", request_id=" + e.ReqID
+	}
+
+	return e.Err.Error()
+}
+
+func (e *NetError) Unwrap() error {
+	return e.Err
+}
+
+func (e *NetError) Is(target error) bool {
+	return target == e.Kind
+}
+
+// Timeout 实现net.Error
+func (e *NetError) Timeout() bool {
+	return e.Kind == ErrTimeout || isTimeoutErr(e.Err)
+}
+
+// Temporary 实现net.Error
+func (e *NetError) Temporary() bool {
+	return false
+}
+
+// MediaTypeError 应答的Content-Type无法解码或不符合Accept,errors.Is可以匹配ErrUnsupportedMediaType和ErrNotSupport
+type MediaTypeError struct {
+	ContentType string // 收到的Content-Type
+	Accept      string // 请求的Accept
+}
+
+func (e *MediaTypeError) Error() string {
+	if e.Accept != "" {
+		return "unsupported media type: " + e.ContentType + ", accept=" + e.Accept
+	}
+
+	return "unsupported media type: " + e.ContentType
+}
+
+func (e *MediaTypeError) Is(target error) bool {
+	return target == ErrUnsupportedMediaType || target == ErrNotSupport
+}
+
+// classifyErr 将发送请求的错误包装为NetError,无法分类时原样返回
+func classifyErr(err error) error {
+	if err == nil {
+		return nil
+	}
+
+	var ne *NetError
+	if errors.As(err, &ne) {
+		return err
+	}
+
+	if kind := errKind(err); kind != nil {
+		return &NetError{Kind: kind, Err: err}
+	}
+
+	return err
+}
+
+func errKind(err error) error {
+	var dnsErr *net.DNSError
+	var recordErr tls.RecordHeaderError
+	var alertErr tls.AlertError
+	var verifyErr *tls.CertificateVerificationError
+	var authorityErr x509.UnknownAuthorityError
+	var hostnameErr x509.HostnameError
+	var invalidErr x509.CertificateInvalidError
+
+	switch {
+	case errors.Is(err, context.Canceled):
+		return ErrCanceled
+	case errors.As(err, &dnsErr):
+		return ErrDNS
+	case errors.Is(err, syscall.ECONNREFUSED):
+		return ErrConnectionRefused
+	case errors.As(err, &recordErr), errors.As(err, &alertErr), errors.As(err, &verifyErr),
+		errors.As(err, &authorityErr), errors.As(err, &hostnameErr), errors.As(err, &invalidErr):
+		return ErrTLS
+	case errors.Is(err, context.DeadlineExceeded), isTimeoutErr(err):
+		return ErrTimeout
+	default:
+		return nil
+	}
+}
+
+// IsRetryable 判断错误是否可以通过重试恢复,如超时,连接被拒绝,连接被重置,DNS临时错误
+// 以及408,429,502,503,504状态码,取消和TLS错误不可重试
+// 熔断打开和没有可用的host时立即重试只会再次失败,也不可重试
+func IsRetryable(err error) bool {
+	if err == nil {
+		return false
+	}
+
+	var se *StatusErr
+	if errors.As(err, &se) {
+		switch se.Code {
+		case http.StatusRequestTimeout, http.StatusTooManyRequests, http.StatusBadGateway,
+			http.StatusServiceUnavailable, http.StatusGatewayTimeout:
+			return true
+		default:
+			return false
+		}
+	}
+
+	var dnsErr *net.DNSError
+	switch {
+	case errors.Is(err, ErrCanceled), errors.Is(err, context.Canceled), errors.Is(err, ErrTLS),
+		errors.Is(err, ErrCircuitOpen), errors.Is(err, ErrNoAvailableHost):
+		return false
+	case errors.As(err, &dnsErr):
+		return dnsErr.IsTimeout || dnsErr.IsTemporary
+	case errors.Is(err, ErrTimeout), errors.Is(err, ErrConnectionRefused), isTimeoutErr(err):
+		return true
+	case errors.Is(err, ErrTooManyRequests):
+		return true
+	default:
+		return isStaleConnErr(err)
+	}
+}
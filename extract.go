@@ -0,0 +1,194 @@
+package ghttp
+
+import (
+	"archive/tar"
+	"archive/zip"
+	"bufio"
+	"bytes"
+	"compress/gzip"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+const defaultMaxExtractSize = 1 << 30
+
+// errExtractTooLarge 超出MaxExtractSize,errors.Is可以匹配ErrArchiveTooLarge和ErrResponseTooLarge
+var errExtractTooLarge = fmt.Errorf("%w: %w", ErrArchiveTooLarge, ErrResponseTooLarge)
+
+var (
+	magicZip  = []byte("PK\x03\x04")
+	magicGzip = []byte{0x1f, 0x8b}
+)
+
+// DownloadAndExtract 下载tar.gz或zip压缩包并解压到dir目录
+// 解压后的总大小受MaxExtractSize限制,且不允许文件写到dir之外
+func (c *Client) DownloadAndExtract(url string, dir string, opts ...Option) error {
+	o := c.buildOptions(opts...)
+	maxSize := o.MaxExtractSize
+	if maxSize <= 0 {
+		maxSize = defaultMaxExtractSize
+	}
+
+	rsp, err := c.Get(url, nil, opts...)
+	if err != nil {
+		return err
+	}
+	defer rsp.Body.Close()
+
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return err
+	}
+
+	br := bufio.NewReader(rsp.Body)
+	magic, _ := br.Peek(4)
+	switch {
+	case bytes.HasPrefix(magic, magicGzip):
+		return extractTarGz(br, dir, maxSize)
+	case bytes.HasPrefix(magic, magicZip):
+		return extractZip(br, dir, maxSize)
+	default:
+		return ErrNotSupport
+	}
+}
+
+func extractTarGz(r io.Reader, dir string, maxSize int64) error {
+	gz, err := gzip.NewReader(r)
+	if err != nil {
+		return err
+	}
+	defer gz.Close()
+
+	remain := maxSize
+	tr := tar.NewReader(gz)
+	for {
+		hdr, err := tr.Next()
+		if err == io.EOF {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+
+		target, err := extractPath(dir, hdr.Name)
+		if err != nil {
+			return err
+		}
+
+		switch hdr.Typeflag {
+		case tar.TypeDir:
+			if err := os.MkdirAll(target, 0755); err != nil {
+				return err
+			}
+		case tar.TypeReg:
+			n, err := extractFile(target, tr, os.FileMode(hdr.Mode).Perm(), remain)
+			if err != nil {
+				return err
+			}
+			remain -= n
+		default:
+			// 忽略符号链接等特殊文件,避免通过链接写到目录之外
+		}
+	}
+}
+
+func extractZip(r io.Reader, dir string, maxSize int64) error {
+	// zip需要随机读取,先保存到临时文件
+	tmp, err := ioutil.TempFile("", "ghttp-*.zip")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(tmp.Name())
+	defer tmp.Close()
+
+	size, err := io.Copy(tmp, io.LimitReader(r, maxSize+1))
+	if err != nil {
+		return err
+	}
+	if size > maxSize {
+		return errExtractTooLarge
+	}
+
+	zr, err := zip.NewReader(tmp, size)
+	if err != nil {
+		return err
+	}
+
+	remain := maxSize
+	for _, f := range zr.File {
+		target, err := extractPath(dir, f.Name)
+		if err != nil {
+			return err
+		}
+
+		if f.FileInfo().IsDir() {
+			if err := os.MkdirAll(target, 0755); err != nil {
+				return err
+			}
+			continue
+		}
+
+		if !f.Mode().IsRegular() {
+			continue
+		}
+
+		rc, err := f.Open()
+		if err != nil {
+			return err
+		}
+		n, err := extractFile(target, rc, f.Mode().Perm(), remain)
+		rc.Close()
+		if err != nil {
+			return err
+		}
+		remain -= n
+	}
+
+	return nil
+}
+
+// extractPath 计算解压路径,拒绝绝对路径和..等跳出dir的路径
+func extractPath(dir string, name string) (string, error) {
+	if filepath.IsAbs(name) || strings.HasPrefix(name, "/") {
+		return "", ErrInvalidArchivePath
+	}
+
+	target := filepath.Join(dir, name)
+	root := filepath.Clean(dir) + string(os.PathSeparator)
+	if target != filepath.Clean(dir) && !strings.HasPrefix(target, root) {
+		return "", ErrInvalidArchivePath
+	}
+
+	return target, nil
+}
+
+func extractFile(target string, r io.Reader, perm os.FileMode, remain int64) (int64, error) {
+	if err := os.MkdirAll(filepath.Dir(target), 0755); err != nil {
+		return 0, err
+	}
+
+	if perm == 0 {
+		perm = 0644
+	}
+
+	f, err := os.OpenFile(target, os.O_CREATE|os.O_WRONLY|os.O_TRUNC, perm)
+	if err != nil {
+		return 0, err
+	}
+
+	n, err := io.Copy(f, io.LimitReader(r, remain+1))
+	f.Close()
+	if err == nil && n > remain {
+		err = errExtractTooLarge
+	}
+	if err != nil {
+		// 不保留不完整的文件
+		os.Remove(target)
+		return n, err
+	}
+
+	return n, nil
+}
@@ -0,0 +1,122 @@
+package ghttp
+
+import (
+	"encoding/json"
+	"net/url"
+	"reflect"
+)
+
+const (
+	defaultMaxPages = 1000
+	defaultMaxItems = 100000
+)
+
+// PageExtractor 从一页的应答中提取本页的元素和下一页的url,next为空时结束
+// items为元素数组编码后的数据,按应答的Content-Type解码,next可以是相对地址
+type PageExtractor func(rsp *Response, data []byte) (items []byte, next string, err error)
+
+// JSONPageExtractor 应答为json对象时,从itemsField中获取元素,从nextField中获取下一页url
+// nextField为空时使用Link头中rel="next"的地址
+func JSONPageExtractor(itemsField string, nextField string) PageExtractor {
+	return func(rsp *Response, data []byte) ([]byte, string, error) {
+		var page map[string]json.RawMessage
+		if err := json.Unmarshal(data, &page); err != nil {
+			return nil, "", err
+		}
+
+		next := ""
+		if nextField == "" {
+			next = ParseLinkHeader(rsp)["next"]
+		} else if raw, ok := page[nextField]; ok {
+			// null或非字符串时认为没有下一页
+			json.Unmarshal(raw, &next)
+		}
+
+		return page[itemsField], next, nil
+	}
+}
+
+// defaultPageExtractor 应答为元素数组,下一页使用Link头中rel="next"的地址
+func defaultPageExtractor(rsp *Response, data []byte) ([]byte, string, error) {
+	return data, ParseLinkHeader(rsp)["next"], nil
+}
+
+// FetchAll 自动翻页直到结束,将每页的元素追加到items中,items必须是slice的指针
+// 通过WithPageExtractor指定如何解析每一页,超过WithPageLimit的限制时返回ErrPageLimit,已获取的元素保留在items中
+func (c *Client) FetchAll(url string, items interface{}, opts ...Option) error {
+	rv := reflect.ValueOf(items)
+	if rv.Kind() != reflect.Ptr || rv.Elem().Kind() != reflect.Slice {
+		return ErrInvalidType
+	}
+	slice := rv.Elem()
+
+	o := c.buildOptions(opts...)
+	extract := o.PageExtractor
+	if extract == nil {
+		extract = defaultPageExtractor
+	}
+	maxPages := o.MaxPages
+	if maxPages <= 0 {
+		maxPages = defaultMaxPages
+	}
+	maxItems := o.MaxItems
+	if maxItems <= 0 {
+		maxItems = defaultMaxItems
+	}
+
+	for pages := 0; url != ""; pages++ {
+		if pages >= maxPages {
+			return ErrPageLimit
+		}
+
+		var data []byte
+		rsp, err := c.Get(url, &data, opts...)
+		if err != nil {
+			return err
+		}
+
+		raw, next, err := extract(rsp, data)
+		if err != nil {
+			return err
+		}
+
+		if len(raw) > 0 {
+			contentType := parseContentType(rsp.Header.Get("Content-Type"))
+			if _, ok := o.getCodec(contentType); !ok {
+				contentType = TypeJSON
+			}
+
+			page := reflect.New(slice.Type())
+			if err := o.decode(contentType, raw, page.Interface()); err != nil {
+				return err
+			}
+
+			slice.Set(reflect.AppendSlice(slice, page.Elem()))
+			if slice.Len() > maxItems {
+				slice.SetLen(maxItems)
+				return ErrPageLimit
+			}
+		}
+
+		url, err = resolveNext(rsp, next)
+		if err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// resolveNext 相对地址基于当前请求的url
+func resolveNext(rsp *Response, next string) (string, error) {
+	if next == "" || rsp.Request == nil {
+		return next, nil
+	}
+
+	u, err := url.Parse(next)
+	if err != nil {
+		return "", err
+	}
+
+	return rsp.Request.URL.ResolveReference(u).String(), nil
+}
@@ -0,0 +1,74 @@
+package ghttp
+
+import (
+	"context"
+	"net/http"
+)
+
+// Future 异步请求的结果,result在Wait返回后才能读取
+type Future struct {
+	done   chan struct{}
+	cancel context.CancelFunc
+	rsp    *Response
+	err    error
+}
+
+// Done 请求结束时关闭
+func (f *Future) Done() <-chan struct{} {
+	return f.done
+}
+
+// Wait 等待请求结束
+func (f *Future) Wait() (*Response, error) {
+	<-f.done
+	return f.rsp, f.err
+}
+
+// Cancel 取消请求,已经结束时无影响
+func (f *Future) Cancel() {
+	f.cancel()
+}
+
+// WaitAll 等待所有请求结束,返回第一个错误
+func WaitAll(futures ...*Future) error {
+	var first error
+	for _, f := range futures {
+		if _, err := f.Wait(); err != nil && first == nil {
+			first = err
+		}
+	}
+
+	return first
+}
+
+// DoAsync 在goroutine中执行DoRequest,使用WithContext设置的context,Cancel或context取消时请求中止
+// result为nil时需要调用者关闭Response.Body
+func (c *Client) DoAsync(method string, url string, req interface{}, result interface{}, opts ...Option) *Future {
+	ctx, cancel := context.WithCancel(context.Background())
+	f := &Future{done: make(chan struct{}), cancel: cancel}
+	opts = append(opts[:len(opts):len(opts)], func(o *Options) {
+		var inner context.CancelFunc
+		o.Context, inner = context.WithCancel(o.Context)
+		context.AfterFunc(ctx, inner)
+	})
+
+	go func() {
+		defer close(f.done)
+		f.rsp, f.err = c.DoRequest(method, url, req, result, opts...)
+		if f.err == nil && result == nil {
+			f.rsp.Body = &cancelBody{ReadCloser: f.rsp.Body, cancel: cancel}
+		} else {
+			cancel()
+		}
+	}()
+
+	return f
+}
+
+func (c *Client) GetAsync(url string, result interface{}, opts ...Option) *Future {
+	return c.DoAsync(http.MethodGet, url, nil, result, opts...)
+}
+
+func (c *Client) PostAsync(url string, req interface{}, result interface{}, opts ...Option) *Future {
+	return c.DoAsync(http.MethodPost, url, req, result, opts...)
+}
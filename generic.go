@@ -0,0 +1,23 @@
+package ghttp
+
+// GetJSON 发送GET请求并解码为T,c为nil时使用Default
+func GetJSON[T any](c *Client, url string, opts ...Option) (T, *Response, error) {
+	var result T
+	if c == nil {
+		c = Default
+	}
+
+	rsp, err := c.Get(url, &result, opts...)
+	return result, rsp, err
+}
+
+// DoTyped 发送请求并解码为TRsp,c为nil时使用Default
+func DoTyped[TReq any, TRsp any](c *Client, method string, url string, req TReq, opts ...Option) (TRsp, *Response, error) {
+	var result TRsp
+	if c == nil {
+		c = Default
+	}
+
+	rsp, err := c.DoRequest(method, url, req, &result, opts...)
+	return result, rsp, err
+}
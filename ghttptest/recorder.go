@@ -0,0 +1,247 @@
+package ghttptest
+
+import (
+	"bytes"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"io/ioutil"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"unicode/utf8"
+
+	"gopkg.in/yaml.v3"
+)
+
+var ErrInteractionNotFound = errors.New("ghttptest: interaction not found in cassette")
+
+// Mode 录制模式
+type Mode int
+
+const (
+	ModeAuto   Mode = iota // 磁带存在则回放,否则录制
+	ModeRecord             // 总是请求真实服务器并录制
+	ModeReplay             // 只回放,找不到时返回ErrInteractionNotFound
+)
+
+// DefaultFilterHeaders 默认不写入磁带的header
+var DefaultFilterHeaders = []string{"Authorization", "Cookie", "Set-Cookie", "X-Auth-Token", "X-Jwt-Token"}
+
+// Cassette 录制的请求和应答,根据文件扩展名保存为json或yaml
+type Cassette struct {
+	Interactions []*Interaction `json:"interactions" yaml:"interactions"`
+}
+
+type Interaction struct {
+	Request  CassetteRequest  `json:"request" yaml:"request"`
+	Response CassetteResponse `json:"response" yaml:"response"`
+}
+
+type CassetteRequest struct {
+	Method   string      `json:"method" yaml:"method"`
+	URL      string      `json:"url" yaml:"url"`
+	Header   http.Header `json:"header,omitempty" yaml:"header,omitempty"`
+	Body     string      `json:"body,omitempty" yaml:"body,omitempty"`
+	Encoding string      `json:"encoding,omitempty" yaml:"encoding,omitempty"`
+}
+
+type CassetteResponse struct {
+	Status   int         `json:"status" yaml:"status"`
+	Header   http.Header `json:"header,omitempty" yaml:"header,omitempty"`
+	Body     string      `json:"body,omitempty" yaml:"body,omitempty"`
+	Encoding string      `json:"encoding,omitempty" yaml:"encoding,omitempty"`
+}
+
+// Recorder 录制/回放Transport,首次运行时录制真实应答,之后确定性回放
+type Recorder struct {
+	Path          string            // 磁带文件路径,.yaml/.yml使用yaml,否则使用json
+	Mode          Mode              //
+	Transport     http.RoundTripper // 录制时使用的真实Transport,nil时使用http.DefaultTransport
+	FilterHeaders []string          // 不写入磁带的header,防止泄露密钥
+	mux           sync.Mutex
+	cassette      *Cassette
+	used          []bool
+	replay        bool
+}
+
+// NewRecorder 创建Recorder,回放模式下会加载磁带
+func NewRecorder(path string, mode Mode, transport http.RoundTripper) (*Recorder, error) {
+	r := &Recorder{Path: path, Mode: mode, Transport: transport, FilterHeaders: DefaultFilterHeaders, cassette: &Cassette{}}
+	if mode == ModeRecord {
+		return r, nil
+	}
+
+	data, err := ioutil.ReadFile(path)
+	if err != nil {
+		if os.IsNotExist(err) && mode == ModeAuto {
+			return r, nil
+		}
+		return nil, err
+	}
+
+	if isYAML(path) {
+		err = yaml.Unmarshal(data, r.cassette)
+	} else {
+		err = json.Unmarshal(data, r.cassette)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	r.replay = true
+	r.used = make([]bool, len(r.cassette.Interactions))
+	return r, nil
+}
+
+// RoundTrip 实现http.RoundTripper
+func (r *Recorder) RoundTrip(req *http.Request) (*http.Response, error) {
+	var body []byte
+	if req.Body != nil {
+		data, err := ioutil.ReadAll(req.Body)
+		req.Body.Close()
+		if err != nil {
+			return nil, err
+		}
+		body = data
+		req.Body = ioutil.NopCloser(bytes.NewReader(body))
+	}
+
+	if r.replay {
+		return r.replayRequest(req, body)
+	}
+
+	return r.record(req, body)
+}
+
+func (r *Recorder) replayRequest(req *http.Request, body []byte) (*http.Response, error) {
+	r.mux.Lock()
+	defer r.mux.Unlock()
+
+	url := req.URL.String()
+	for i, it := range r.cassette.Interactions {
+		if r.used[i] || it.Request.Method != req.Method || it.Request.URL != url {
+			continue
+		}
+
+		reqBody, err := decodeBody(it.Request.Body, it.Request.Encoding)
+		if err != nil {
+			return nil, err
+		}
+		if !bytes.Equal(reqBody, body) {
+			continue
+		}
+
+		rspBody, err := decodeBody(it.Response.Body, it.Response.Encoding)
+		if err != nil {
+			return nil, err
+		}
+
+		r.used[i] = true
+		rsp := NewResponse(it.Response.Status, cloneHeader(it.Response.Header), rspBody)
+		rsp.Request = req
+		return rsp, nil
+	}
+
+	return nil, ErrInteractionNotFound
+}
+
+func (r *Recorder) record(req *http.Request, body []byte) (*http.Response, error) {
+	transport := r.Transport
+	if transport == nil {
+		transport = http.DefaultTransport
+	}
+
+	rsp, err := transport.RoundTrip(req)
+	if err != nil {
+		return nil, err
+	}
+
+	data, err := ioutil.ReadAll(rsp.Body)
+	rsp.Body.Close()
+	if err != nil {
+		return nil, err
+	}
+	rsp.Body = ioutil.NopCloser(bytes.NewReader(data))
+
+	it := &Interaction{
+		Request:  CassetteRequest{Method: req.Method, URL: req.URL.String(), Header: r.filter(req.Header)},
+		Response: CassetteResponse{Status: rsp.StatusCode, Header: r.filter(rsp.Header)},
+	}
+	it.Request.Body, it.Request.Encoding = encodeBody(body)
+	it.Response.Body, it.Response.Encoding = encodeBody(data)
+
+	r.mux.Lock()
+	r.cassette.Interactions = append(r.cassette.Interactions, it)
+	r.mux.Unlock()
+
+	if err := r.Save(); err != nil {
+		return nil, err
+	}
+
+	return rsp, nil
+}
+
+// Save 将磁带写入文件
+func (r *Recorder) Save() error {
+	r.mux.Lock()
+	defer r.mux.Unlock()
+
+	var data []byte
+	var err error
+	if isYAML(r.Path) {
+		data, err = yaml.Marshal(r.cassette)
+	} else {
+		data, err = json.MarshalIndent(r.cassette, "", "  ")
+	}
+	if err != nil {
+		return err
+	}
+
+	if err := os.MkdirAll(filepath.Dir(r.Path), 0755); err != nil {
+		return err
+	}
+
+	return ioutil.WriteFile(r.Path, data, 0644)
+}
+
+func (r *Recorder) filter(header http.Header) http.Header {
+	result := cloneHeader(header)
+	for _, k := range r.FilterHeaders {
+		result.Del(k)
+	}
+
+	return result
+}
+
+func cloneHeader(header http.Header) http.Header {
+	result := make(http.Header, len(header))
+	for k, v := range header {
+		result[k] = append([]string(nil), v...)
+	}
+
+	return result
+}
+
+func encodeBody(data []byte) (string, string) {
+	if utf8.Valid(data) {
+		return string(data), ""
+	}
+
+	return base64.StdEncoding.EncodeToString(data), "base64"
+}
+
+func decodeBody(body, encoding string) ([]byte, error) {
+	if encoding == "base64" {
+		return base64.StdEncoding.DecodeString(body)
+	}
+
+	return []byte(body), nil
+}
+
+func isYAML(path string) bool {
+	ext := strings.ToLower(filepath.Ext(path))
+	return ext == ".yaml" || ext == ".yml"
+}
@@ -0,0 +1,132 @@
+package ghttptest
+
+import (
+	"bytes"
+	"errors"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestRecorder(t *testing.T) {
+	binary := []byte{0x00, 0xff, 0xfe, 0x80, 0x01}
+	calls := 0
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		calls++
+		w.Header().Set("Set-Cookie", "session=secret")
+		w.Header().Set("X-Trace", "1")
+		if r.URL.Path == "/binary" {
+			w.Write(binary)
+			return
+		}
+		data, _ := ioutil.ReadAll(r.Body)
+		w.Write(data)
+	}))
+	defer srv.Close()
+
+	for _, ext := range []string{".json", ".yaml"} {
+		t.Run(ext, func(t *testing.T) {
+			calls = 0
+			path := filepath.Join(t.TempDir(), "cassette"+ext)
+
+			// 录制
+			rec, err := NewRecorder(path, ModeAuto, nil)
+			if err != nil {
+				t.Fatal(err)
+			}
+			c := &http.Client{Transport: rec}
+			doRecorder(t, c, srv.URL, binary)
+			if calls != 2 {
+				t.Fatalf("expect 2 real calls, got %d", calls)
+			}
+
+			data, err := ioutil.ReadFile(path)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if bytes.Contains(data, []byte("secret")) {
+				t.Errorf("filtered header written to cassette: %s", data)
+			}
+			if !bytes.Contains(data, []byte("base64")) {
+				t.Errorf("binary body should be base64 encoded: %s", data)
+			}
+
+			// 回放,不再请求服务器
+			rec, err = NewRecorder(path, ModeReplay, nil)
+			if err != nil {
+				t.Fatal(err)
+			}
+			c = &http.Client{Transport: rec}
+			rsps := doRecorder(t, c, srv.URL, binary)
+			if calls != 2 {
+				t.Fatalf("replay should not call server, got %d", calls)
+			}
+			for _, rsp := range rsps {
+				if rsp.Header.Get("Set-Cookie") != "" || rsp.Header.Get("X-Trace") != "1" {
+					t.Errorf("unexpected replay header: %v", rsp.Header)
+				}
+			}
+
+			// 每条记录只回放一次,以及不匹配的请求
+			for _, req := range []*http.Request{
+				mustRequest(t, http.MethodGet, srv.URL+"/binary", ""),
+				mustRequest(t, http.MethodPost, srv.URL+"/echo", "other"),
+				mustRequest(t, http.MethodGet, srv.URL+"/missing", ""),
+			} {
+				if _, err := c.Do(req); !errors.Is(err, ErrInteractionNotFound) {
+					t.Errorf("%v %v: expect ErrInteractionNotFound, got %v", req.Method, req.URL, err)
+				}
+			}
+		})
+	}
+
+	if _, err := NewRecorder(filepath.Join(t.TempDir(), "none.json"), ModeReplay, nil); !os.IsNotExist(err) {
+		t.Errorf("replay without cassette should fail, got %v", err)
+	}
+}
+
+func doRecorder(t *testing.T, c *http.Client, url string, binary []byte) []*http.Response {
+	t.Helper()
+
+	req := mustRequest(t, http.MethodPost, url+"/echo", "hello")
+	req.Header.Set("Authorization", "Bearer secret")
+	rsp, err := c.Do(req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	data, _ := ioutil.ReadAll(rsp.Body)
+	rsp.Body.Close()
+	if string(data) != "hello" {
+		t.Errorf("unexpected echo body: %q", data)
+	}
+
+	rsp2, err := c.Do(mustRequest(t, http.MethodGet, url+"/binary", ""))
+	if err != nil {
+		t.Fatal(err)
+	}
+	data, _ = ioutil.ReadAll(rsp2.Body)
+	rsp2.Body.Close()
+	if !bytes.Equal(data, binary) {
+		t.Errorf("unexpected binary body: %v", data)
+	}
+
+	return []*http.Response{rsp, rsp2}
+}
+
+func mustRequest(t *testing.T, method, url, body string) *http.Request {
+	t.Helper()
+
+	req, err := http.NewRequest(method, url, strings.NewReader(body))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if body == "" {
+		req.Body = nil
+	}
+
+	return req
+}
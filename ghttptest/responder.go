@@ -0,0 +1,90 @@
+package ghttptest
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"reflect"
+	"strings"
+)
+
+// NewResponse 创建应答
+func NewResponse(status int, header http.Header, body []byte) *http.Response {
+	if header == nil {
+		header = make(http.Header)
+	}
+
+	return &http.Response{
+		Status:        fmt.Sprintf("%d %s", status, http.StatusText(status)),
+		StatusCode:    status,
+		Proto:         "HTTP/1.1",
+		ProtoMajor:    1,
+		ProtoMinor:    1,
+		Header:        header,
+		Body:          ioutil.NopCloser(bytes.NewReader(body)),
+		ContentLength: int64(len(body)),
+	}
+}
+
+func NewBytesResponder(status int, body []byte) Responder {
+	return func(req *http.Request) (*http.Response, error) {
+		return NewResponse(status, nil, body), nil
+	}
+}
+
+func NewStringResponder(status int, body string) Responder {
+	return func(req *http.Request) (*http.Response, error) {
+		header := http.Header{"Content-Type": {"text/plain; charset=utf-8"}}
+		return NewResponse(status, header, []byte(body)), nil
+	}
+}
+
+func NewJSONResponder(status int, v interface{}) Responder {
+	return func(req *http.Request) (*http.Response, error) {
+		data, err := json.Marshal(v)
+		if err != nil {
+			return nil, err
+		}
+		header := http.Header{"Content-Type": {"application/json"}}
+		return NewResponse(status, header, data), nil
+	}
+}
+
+func NewErrorResponder(err error) Responder {
+	return func(req *http.Request) (*http.Response, error) {
+		return nil, err
+	}
+}
+
+// BodyEquals body完全相同
+func BodyEquals(s string) Matcher {
+	return func(req *http.Request, body []byte) bool {
+		return string(body) == s
+	}
+}
+
+// BodyContains body包含子串
+func BodyContains(s string) Matcher {
+	return func(req *http.Request, body []byte) bool {
+		return strings.Contains(string(body), s)
+	}
+}
+
+// BodyJSON body与v的json语义相同,忽略字段顺序和空白
+func BodyJSON(v interface{}) Matcher {
+	return func(req *http.Request, body []byte) bool {
+		expect, err := json.Marshal(v)
+		if err != nil {
+			return false
+		}
+
+		var x, y interface{}
+		if json.Unmarshal(expect, &x) != nil || json.Unmarshal(body, &y) != nil {
+			return false
+		}
+
+		return reflect.DeepEqual(x, y)
+	}
+}
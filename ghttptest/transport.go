@@ -0,0 +1,167 @@
+// Package ghttptest 提供用于单元测试的桩Transport,配合ghttp.WithTransport使用,无需真实的服务器
+package ghttptest
+
+import (
+	"bytes"
+	"errors"
+	"io/ioutil"
+	"net/http"
+	"regexp"
+	"strings"
+	"sync"
+	"testing"
+)
+
+var ErrNoResponder = errors.New("ghttptest: no responder found")
+
+// Responder 根据请求生成应答
+type Responder func(req *http.Request) (*http.Response, error)
+
+// Matcher 根据请求和body判断是否匹配
+type Matcher func(req *http.Request, body []byte) bool
+
+type stub struct {
+	method    string
+	pattern   string
+	regex     *regexp.Regexp
+	matcher   Matcher
+	responder Responder
+	calls     int
+}
+
+func (s *stub) match(req *http.Request, body []byte) bool {
+	if s.method != "" && s.method != "*" && !strings.EqualFold(s.method, req.Method) {
+		return false
+	}
+
+	if !s.matchURL(req) {
+		return false
+	}
+
+	return s.matcher == nil || s.matcher(req, body)
+}
+
+func (s *stub) matchURL(req *http.Request) bool {
+	if s.regex != nil {
+		return s.regex.MatchString(req.URL.String())
+	}
+
+	if strings.HasPrefix(s.pattern, "/") {
+		return s.pattern == req.URL.Path || s.pattern == req.URL.RequestURI()
+	}
+
+	u := *req.URL
+	if s.pattern == u.String() {
+		return true
+	}
+	u.RawQuery = ""
+	return s.pattern == u.String()
+}
+
+// Transport 桩RoundTripper,按注册顺序匹配请求
+type Transport struct {
+	mux   sync.Mutex
+	stubs []*stub
+	total int
+	// NoResponder 没有匹配时使用,默认返回ErrNoResponder
+	NoResponder Responder
+}
+
+func NewTransport() *Transport {
+	return &Transport{}
+}
+
+// RegisterResponder 注册应答
+// method为空或*时匹配所有方法
+// urlPattern可以是完整URL(可忽略query),以/开头的路径,或者以=~开头的正则表达式
+func (t *Transport) RegisterResponder(method, urlPattern string, responder Responder) {
+	t.RegisterMatcherResponder(method, urlPattern, nil, responder)
+}
+
+// RegisterMatcherResponder 注册应答,并额外要求body满足matcher
+func (t *Transport) RegisterMatcherResponder(method, urlPattern string, matcher Matcher, responder Responder) {
+	s := &stub{method: method, pattern: urlPattern, matcher: matcher, responder: responder}
+	if strings.HasPrefix(urlPattern, "=~") {
+		s.regex = regexp.MustCompile(urlPattern[2:])
+	}
+
+	t.mux.Lock()
+	t.stubs = append(t.stubs, s)
+	t.mux.Unlock()
+}
+
+// RoundTrip 实现http.RoundTripper
+func (t *Transport) RoundTrip(req *http.Request) (*http.Response, error) {
+	var body []byte
+	if req.Body != nil {
+		data, err := ioutil.ReadAll(req.Body)
+		req.Body.Close()
+		if err != nil {
+			return nil, err
+		}
+		body = data
+		req.Body = ioutil.NopCloser(bytes.NewReader(body))
+	}
+
+	t.mux.Lock()
+	t.total++
+	var responder Responder
+	for _, s := range t.stubs {
+		if s.match(req, body) {
+			s.calls++
+			responder = s.responder
+			break
+		}
+	}
+	if responder == nil {
+		responder = t.NoResponder
+	}
+	t.mux.Unlock()
+
+	if responder == nil {
+		return nil, ErrNoResponder
+	}
+
+	rsp, err := responder(req)
+	if rsp != nil && rsp.Request == nil {
+		rsp.Request = req
+	}
+	return rsp, err
+}
+
+// CallCount 返回注册的method和urlPattern被调用的次数
+func (t *Transport) CallCount(method, urlPattern string) int {
+	t.mux.Lock()
+	defer t.mux.Unlock()
+	n := 0
+	for _, s := range t.stubs {
+		if s.method == method && s.pattern == urlPattern {
+			n += s.calls
+		}
+	}
+
+	return n
+}
+
+// TotalCalls 返回总调用次数,包括没有匹配的请求
+func (t *Transport) TotalCalls() int {
+	t.mux.Lock()
+	defer t.mux.Unlock()
+	return t.total
+}
+
+// AssertCalled 断言调用次数
+func (t *Transport) AssertCalled(tb testing.TB, method, urlPattern string, n int) {
+	tb.Helper()
+	if c := t.CallCount(method, urlPattern); c != n {
+		tb.Errorf("ghttptest: %s %s called %d times, want %d", method, urlPattern, c, n)
+	}
+}
+
+// Reset 清空注册的应答和调用计数
+func (t *Transport) Reset() {
+	t.mux.Lock()
+	t.stubs = nil
+	t.total = 0
+	t.mux.Unlock()
+}
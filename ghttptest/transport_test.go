@@ -0,0 +1,42 @@
+package ghttptest
+
+import (
+	"net/http"
+	"testing"
+
+	"github.com/jeckbjy/ghttp"
+)
+
+func TestTransport(t *testing.T) {
+	tr := NewTransport()
+	tr.RegisterResponder(http.MethodGet, "http://api.test/users", NewJSONResponder(200, map[string]string{"name": "jeck"}))
+	tr.RegisterMatcherResponder(http.MethodPost, "=~^http://api.test/users", BodyJSON(map[string]int{"id": 1}), NewStringResponder(200, "created"))
+
+	c := ghttp.NewClient(ghttp.WithTransport(tr))
+
+	var user map[string]string
+	if _, err := c.Get("http://api.test/users?page=1", &user); err != nil {
+		t.Fatal(err)
+	}
+	if user["name"] != "jeck" {
+		t.Errorf("unexpected result: %+v", user)
+	}
+
+	var text string
+	if _, err := c.Post("http://api.test/users", map[string]int{"id": 1}, &text); err != nil {
+		t.Fatal(err)
+	}
+	if text != "created" {
+		t.Errorf("unexpected result: %+v", text)
+	}
+
+	if _, err := c.Post("http://api.test/users", map[string]int{"id": 2}, &text); err == nil {
+		t.Error("expect no responder error")
+	}
+
+	tr.AssertCalled(t, http.MethodGet, "http://api.test/users", 1)
+	tr.AssertCalled(t, http.MethodPost, "=~^http://api.test/users", 1)
+	if tr.TotalCalls() != 3 {
+		t.Errorf("unexpected total calls: %d", tr.TotalCalls())
+	}
+}
@@ -0,0 +1,74 @@
+package ghttp
+
+import (
+	"net/http"
+	"sync"
+)
+
+// Middleware 包装RoundTripper,如注入trace头或脱敏日志
+type Middleware func(next http.RoundTripper) http.RoundTripper
+
+// RoundTripperFunc 将函数转为http.RoundTripper
+type RoundTripperFunc func(req *Request) (*Response, error)
+
+func (f RoundTripperFunc) RoundTrip(req *Request) (*Response, error) {
+	return f(req)
+}
+
+var global struct {
+	mutex       sync.RWMutex
+	hooks       Hooks
+	middlewares []Middleware
+}
+
+// RegisterGlobalHook 注册对所有Client生效的Hook,包括Default,先于请求的Hooks执行
+// 通常在init中调用
+func RegisterGlobalHook(hooks ...Hook) {
+	global.mutex.Lock()
+	global.hooks = append(global.hooks, hooks...)
+	global.mutex.Unlock()
+}
+
+// RegisterMiddleware 注册对所有Client生效的Middleware,先注册的在最外层
+// 通常在init中调用
+func RegisterMiddleware(middlewares ...Middleware) {
+	global.mutex.Lock()
+	global.middlewares = append(global.middlewares, middlewares...)
+	global.mutex.Unlock()
+}
+
+// getHooks 合并全局Hook和请求的Hook
+func getHooks(hooks Hooks) Hooks {
+	global.mutex.RLock()
+	defer global.mutex.RUnlock()
+	if len(global.hooks) == 0 {
+		return hooks
+	}
+
+	result := make(Hooks, 0, len(global.hooks)+len(hooks))
+	result = append(result, global.hooks...)
+	return append(result, hooks...)
+}
+
+// do 经过全局Middleware发送请求,应答在缓存和合并请求读取之前就限制大小
+func (c *Client) do(req *Request) (*Response, error) {
+	return c.doClient(c.client, req)
+}
+
+// doClient 同do,使用指定的http.Client发送
+func (c *Client) doClient(client *http.Client, req *Request) (*Response, error) {
+	global.mutex.RLock()
+	middlewares := global.middlewares
+	global.mutex.RUnlock()
+
+	if len(middlewares) == 0 {
+		return limitResponse(client.Do(req))
+	}
+
+	var rt http.RoundTripper = RoundTripperFunc(client.Do)
+	for i := len(middlewares) - 1; i >= 0; i-- {
+		rt = middlewares[i](rt)
+	}
+
+	return limitResponse(rt.RoundTrip(req))
+}
@@ -1,3 +1,15 @@
 module github.com/jeckbjy/ghttp
 
-go 1.14
+go 1.23.0
+
+require (
+	github.com/andybalholm/brotli v1.2.5
+	github.com/fxamacker/cbor/v2 v2.9.4
+	github.com/klauspost/compress v1.18.0
+	golang.org/x/net v0.43.0
+	golang.org/x/text v0.28.0
+	google.golang.org/protobuf v1.36.12
+	gopkg.in/yaml.v3 v3.0.1
+)
+
+require github.com/x448/float16 v0.8.4 // indirect
@@ -0,0 +1,84 @@
+package ghttp
+
+import (
+	"encoding/json"
+	"fmt"
+	"strings"
+)
+
+// GraphQLLocation 错误在query中的位置
+type GraphQLLocation struct {
+	Line   int `json:"line"`
+	Column int `json:"column"`
+}
+
+// GraphQLError 服务器返回的GraphQL错误,可以通过errors.As获取
+type GraphQLError struct {
+	Message    string                 `json:"message"`
+	Locations  []GraphQLLocation      `json:"locations,omitempty"`
+	Path       []interface{}          `json:"path,omitempty"`
+	Extensions map[string]interface{} `json:"extensions,omitempty"`
+}
+
+func (e *GraphQLError) Error() string {
+	if len(e.Path) > 0 {
+		return fmt.Sprintf("graphql: %s, path=%v", e.Message, e.Path)
+	}
+
+	return "graphql: " + e.Message
+}
+
+// GraphQLErrors 一次请求返回的所有错误,errors.As返回第一个GraphQLError
+type GraphQLErrors []*GraphQLError
+
+func (es GraphQLErrors) Error() string {
+	msgs := make([]string, 0, len(es))
+	for _, e := range es {
+		msgs = append(msgs, e.Error())
+	}
+
+	return strings.Join(msgs, "; ")
+}
+
+func (es GraphQLErrors) Unwrap() []error {
+	errs := make([]error, 0, len(es))
+	for _, e := range es {
+		errs = append(errs, e)
+	}
+
+	return errs
+}
+
+type graphQLRequest struct {
+	Query     string      `json:"query"`
+	Variables interface{} `json:"variables,omitempty"`
+}
+
+type graphQLResponse struct {
+	Data   json.RawMessage `json:"data"`
+	Errors GraphQLErrors   `json:"errors"`
+}
+
+// GraphQL 发送GraphQL查询,将data解码到result中,服务器返回errors时返回GraphQLErrors
+// 部分成功时result中依然有数据
+func (c *Client) GraphQL(url string, query string, variables interface{}, result interface{}, opts ...Option) (*Response, error) {
+	body := graphQLResponse{}
+	reqOpts := append(opts[:len(opts):len(opts)], WithContentType(TypeJSON))
+	rsp, err := c.Post(url, &graphQLRequest{Query: query, Variables: variables}, &body, reqOpts...)
+	if err != nil {
+		return rsp, err
+	}
+
+	if result != nil && len(body.Data) > 0 && string(body.Data) != "null" {
+		o := c.buildOptions(opts...)
+		if err := o.decode(TypeJSON, body.Data, result); err != nil {
+			return rsp, err
+		}
+	}
+
+	if len(body.Errors) > 0 {
+		return rsp, body.Errors
+	}
+
+	return rsp, nil
+}
@@ -0,0 +1,45 @@
+package ghttp
+
+import (
+	"context"
+	"crypto/tls"
+	"net"
+	"net/http"
+
+	"golang.org/x/net/http2"
+)
+
+// h2cTransport http使用h2c(prior knowledge),https仍使用原有的Transport
+type h2cTransport struct {
+	h2c  *http2.Transport
+	base http.RoundTripper
+}
+
+func newH2CTransport(dial DialFunc, base http.RoundTripper) *h2cTransport {
+	return &h2cTransport{
+		h2c: &http2.Transport{
+			AllowHTTP: true,
+			DialTLSContext: func(ctx context.Context, network, addr string, _ *tls.Config) (net.Conn, error) {
+				// 不使用TLS,直接以http2协议通信
+				return dial(ctx, network, addr)
+			},
+		},
+		base: base,
+	}
+}
+
+func (t *h2cTransport) RoundTrip(req *Request) (*Response, error) {
+	if req.URL.Scheme == "http" {
+		return t.h2c.RoundTrip(req)
+	}
+
+	return t.base.RoundTrip(req)
+}
+
+// CloseIdleConnections 关闭两个Transport的空闲连接
+func (t *h2cTransport) CloseIdleConnections() {
+	t.h2c.CloseIdleConnections()
+	if c, ok := t.base.(interface{ CloseIdleConnections() }); ok {
+		c.CloseIdleConnections()
+	}
+}
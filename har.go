@@ -0,0 +1,326 @@
+package ghttp
+
+import (
+	"bytes"
+	"encoding/base64"
+	"encoding/json"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"sync"
+	"time"
+	"unicode/utf8"
+)
+
+// HARRecorder 记录请求和应答,并输出为HAR 1.2格式,可直接导入浏览器devtools
+type HARRecorder struct {
+	mux     sync.Mutex
+	entries []*harEntry
+	writer  io.Writer
+	path    string
+}
+
+// NewHARRecorder 创建HARRecorder,调用Flush时写入w
+func NewHARRecorder(w io.Writer) *HARRecorder {
+	return &HARRecorder{writer: w}
+}
+
+// NewHARFileRecorder 创建HARRecorder,调用Close时写入文件
+func NewHARFileRecorder(path string) *HARRecorder {
+	return &HARRecorder{path: path}
+}
+
+// maxHARBody 每个body最多记录的字节数,超出的部分不记录,BodySize依然是实际大小
+const maxHARBody = 1 << 20
+
+// Hook 记录请求,可通过AddHook注册
+// 应答body在调用者读取时记录,不会提前读取,关闭body后记录完整
+func (r *HARRecorder) Hook(ev *Event) error {
+	if ev.Type != EventPost {
+		return nil
+	}
+
+	entry, err := newHAREntry(ev)
+	if err != nil {
+		return err
+	}
+
+	r.mux.Lock()
+	r.entries = append(r.entries, entry)
+	r.mux.Unlock()
+
+	if ev.Err == nil && ev.Rsp != nil && ev.Rsp.Body != nil {
+		ev.Rsp.Body = &harBody{ReadCloser: ev.Rsp.Body, r: r, entry: entry}
+	}
+
+	return nil
+}
+
+// Close 写入NewHARFileRecorder指定的文件或NewHARRecorder指定的Writer
+func (r *HARRecorder) Close() error {
+	if r.path != "" {
+		return r.Save(r.path)
+	}
+
+	return r.Flush()
+}
+
+// Len 返回已记录的条数
+func (r *HARRecorder) Len() int {
+	r.mux.Lock()
+	defer r.mux.Unlock()
+	return len(r.entries)
+}
+
+// Flush 写入NewHARRecorder指定的Writer
+func (r *HARRecorder) Flush() error {
+	if r.writer == nil {
+		return nil
+	}
+
+	_, err := r.WriteTo(r.writer)
+	return err
+}
+
+// WriteTo 将全部记录以HAR格式写入w
+func (r *HARRecorder) WriteTo(w io.Writer) (int64, error) {
+	r.mux.Lock()
+	doc := &harDocument{Log: harLog{
+		Version: "1.2",
+		Creator: harCreator{Name: "ghttp", Version: Version},
+		Entries: r.entries,
+	}}
+	data, err := json.MarshalIndent(doc, "", "  ")
+	r.mux.Unlock()
+	if err != nil {
+		return 0, err
+	}
+
+	n, err := w.Write(data)
+	return int64(n), err
+}
+
+// Save 将全部记录写入文件
+func (r *HARRecorder) Save(path string) error {
+	buf := &bytes.Buffer{}
+	if _, err := r.WriteTo(buf); err != nil {
+		return err
+	}
+
+	return ioutil.WriteFile(path, buf.Bytes(), 0644)
+}
+
+func newHAREntry(ev *Event) (*harEntry, error) {
+	req := ev.Req
+	entry := &harEntry{
+		StartedDateTime: ev.Start.Format(time.RFC3339Nano),
+		Time:            toMillis(time.Since(ev.Start)),
+		Request: harRequest{
+			Method:      req.Method,
+			URL:         req.URL.String(),
+			HTTPVersion: req.Proto,
+			Cookies:     toHARCookies(req.Cookies()),
+			Headers:     toHARPairs(req.Header),
+			QueryString: toHARPairs(req.URL.Query()),
+			HeadersSize: -1,
+			BodySize:    0,
+		},
+		Cache:   struct{}{},
+		Timings: harTimings{Blocked: -1, DNS: -1, Connect: -1, SSL: -1},
+	}
+	entry.Timings.Wait = entry.Time
+
+	if req.GetBody != nil {
+		rc, err := req.GetBody()
+		if err != nil {
+			return nil, err
+		}
+		data, err := ioutil.ReadAll(io.LimitReader(rc, maxHARBody))
+		rc.Close()
+		if err != nil {
+			return nil, err
+		}
+		entry.Request.BodySize = int(req.ContentLength)
+		entry.Request.PostData = &harPostData{MimeType: req.Header.Get("Content-Type"), Text: string(data)}
+	}
+
+	if ev.Err != nil {
+		entry.Response = harResponse{Status: 0, HTTPVersion: req.Proto, HeadersSize: -1, BodySize: -1, Error: ev.Err.Error()}
+		return entry, nil
+	}
+
+	rsp := ev.Rsp
+	entry.Response = harResponse{
+		Status:      rsp.StatusCode,
+		StatusText:  http.StatusText(rsp.StatusCode),
+		HTTPVersion: rsp.Proto,
+		Cookies:     toHARCookies(rsp.Cookies()),
+		Headers:     toHARPairs(rsp.Header),
+		Content:     harContent{MimeType: rsp.Header.Get("Content-Type")},
+		RedirectURL: rsp.Header.Get("Location"),
+		HeadersSize: -1,
+		BodySize:    -1,
+	}
+
+	return entry, nil
+}
+
+// harBody 调用者读取时记录body,读取完或关闭时写入entry,不影响流式读取和RawResponse
+type harBody struct {
+	io.ReadCloser
+	r     *HARRecorder
+	entry *harEntry
+	buf   bytes.Buffer
+	size  int
+	once  sync.Once
+}
+
+func (b *harBody) Read(p []byte) (int, error) {
+	n, err := b.ReadCloser.Read(p)
+	if keep := maxHARBody - b.buf.Len(); keep > 0 {
+		b.buf.Write(p[:min(n, keep)])
+	}
+	b.size += n
+	if err == io.EOF {
+		b.finish()
+	}
+
+	return n, err
+}
+
+func (b *harBody) Close() error {
+	err := b.ReadCloser.Close()
+	b.finish()
+	return err
+}
+
+func (b *harBody) finish() {
+	b.once.Do(func() {
+		data := b.buf.Bytes()
+		b.r.mux.Lock()
+		defer b.r.mux.Unlock()
+		content := &b.entry.Response.Content
+		content.Size = b.size
+		if utf8.Valid(data) {
+			content.Text = string(data)
+		} else {
+			content.Text = base64.StdEncoding.EncodeToString(data)
+			content.Encoding = "base64"
+		}
+		b.entry.Response.BodySize = b.size
+	})
+}
+
+func toMillis(d time.Duration) float64 {
+	return float64(d) / float64(time.Millisecond)
+}
+
+func toHARPairs(dict map[string][]string) []harPair {
+	pairs := make([]harPair, 0, len(dict))
+	for k, v := range dict {
+		for _, x := range v {
+			pairs = append(pairs, harPair{Name: k, Value: x})
+		}
+	}
+
+	return pairs
+}
+
+func toHARCookies(cookies []*http.Cookie) []harCookie {
+	result := make([]harCookie, 0, len(cookies))
+	for _, c := range cookies {
+		hc := harCookie{Name: c.Name, Value: c.Value, Path: c.Path, Domain: c.Domain, HTTPOnly: c.HttpOnly, Secure: c.Secure}
+		if !c.Expires.IsZero() {
+			hc.Expires = c.Expires.Format(time.RFC3339)
+		}
+		result = append(result, hc)
+	}
+
+	return result
+}
+
+type harDocument struct {
+	Log harLog `json:"log"`
+}
+
+type harLog struct {
+	Version string      `json:"version"`
+	Creator harCreator  `json:"creator"`
+	Entries []*harEntry `json:"entries"`
+}
+
+type harCreator struct {
+	Name    string `json:"name"`
+	Version string `json:"version"`
+}
+
+type harEntry struct {
+	StartedDateTime string      `json:"startedDateTime"`
+	Time            float64     `json:"time"`
+	Request         harRequest  `json:"request"`
+	Response        harResponse `json:"response"`
+	Cache           struct{}    `json:"cache"`
+	Timings         harTimings  `json:"timings"`
+}
+
+type harRequest struct {
+	Method      string       `json:"method"`
+	URL         string       `json:"url"`
+	HTTPVersion string       `json:"httpVersion"`
+	Cookies     []harCookie  `json:"cookies"`
+	Headers     []harPair    `json:"headers"`
+	QueryString []harPair    `json:"queryString"`
+	PostData    *harPostData `json:"postData,omitempty"`
+	HeadersSize int          `json:"headersSize"`
+	BodySize    int          `json:"bodySize"`
+}
+
+type harResponse struct {
+	Status      int         `json:"status"`
+	StatusText  string      `json:"statusText"`
+	HTTPVersion string      `json:"httpVersion"`
+	Cookies     []harCookie `json:"cookies"`
+	Headers     []harPair   `json:"headers"`
+	Content     harContent  `json:"content"`
+	RedirectURL string      `json:"redirectURL"`
+	HeadersSize int         `json:"headersSize"`
+	BodySize    int         `json:"bodySize"`
+	Error       string      `json:"_error,omitempty"`
+}
+
+type harPair struct {
+	Name  string `json:"name"`
+	Value string `json:"value"`
+}
+
+type harCookie struct {
+	Name     string `json:"name"`
+	Value    string `json:"value"`
+	Path     string `json:"path,omitempty"`
+	Domain   string `json:"domain,omitempty"`
+	Expires  string `json:"expires,omitempty"`
+	HTTPOnly bool   `json:"httpOnly"`
+	Secure   bool   `json:"secure"`
+}
+
+type harPostData struct {
+	MimeType string `json:"mimeType"`
+	Text     string `json:"text"`
+}
+
+type harContent struct {
+	Size     int    `json:"size"`
+	MimeType string `json:"mimeType"`
+	Text     string `json:"text,omitempty"`
+	Encoding string `json:"encoding,omitempty"`
+}
+
+type harTimings struct {
+	Blocked float64 `json:"blocked"`
+	DNS     float64 `json:"dns"`
+	Connect float64 `json:"connect"`
+	Send    float64 `json:"send"`
+	Wait    float64 `json:"wait"`
+	Receive float64 `json:"receive"`
+	SSL     float64 `json:"ssl"`
+}
@@ -0,0 +1,92 @@
+package ghttp
+
+import (
+	"context"
+	"net/http"
+	"time"
+)
+
+// isIdempotent 幂等的请求才能同时发送多份
+func isIdempotent(method string) bool {
+	switch method {
+	case http.MethodGet, http.MethodHead, http.MethodOptions, http.MethodTrace, http.MethodPut, http.MethodDelete:
+		return true
+	default:
+		return false
+	}
+}
+
+type hedgeResult struct {
+	idx int
+	rsp *Response
+	err error
+}
+
+// hedge 超过delay没有应答时再发送一份请求,最多同时发送max份,返回最先成功的应答并取消其他请求
+func (c *Client) hedge(req *Request, delay time.Duration, max int) (*Response, error) {
+	results := make(chan hedgeResult, max)
+	cancels := make([]context.CancelFunc, 0, max)
+	launch := func() {
+		idx := len(cancels)
+		ctx, cancel := context.WithCancel(req.Context())
+		cancels = append(cancels, cancel)
+		r := req.Clone(ctx)
+		if req.GetBody != nil {
+			body, err := req.GetBody()
+			if err != nil {
+				results <- hedgeResult{idx: idx, err: err}
+				return
+			}
+			r.Body = body
+		}
+
+		go func() {
+			rsp, err := c.do(r)
+			results <- hedgeResult{idx: idx, rsp: rsp, err: err}
+		}()
+	}
+
+	launch()
+	timer := time.NewTimer(delay)
+	defer timer.Stop()
+
+	var lastErr error
+	for received := 0; received < len(cancels); {
+		select {
+		case <-timer.C:
+			if len(cancels) < max {
+				launch()
+				timer.Reset(delay)
+			}
+		case r := <-results:
+			received++
+			if r.err != nil {
+				cancels[r.idx]()
+				lastErr = r.err
+				continue
+			}
+
+			// 取消其他请求,并关闭之后到达的应答
+			for i, cancel := range cancels {
+				if i != r.idx {
+					cancel()
+				}
+			}
+			go drainHedge(results, len(cancels)-received)
+
+			// body读取完毕后才能释放context
+			r.rsp.Body = &cancelBody{ReadCloser: r.rsp.Body, cancel: cancels[r.idx]}
+			return r.rsp, nil
+		}
+	}
+
+	return nil, lastErr
+}
+
+func drainHedge(results chan hedgeResult, n int) {
+	for i := 0; i < n; i++ {
+		if r := <-results; r.rsp != nil {
+			r.rsp.Body.Close()
+		}
+	}
+}
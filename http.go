@@ -1,6 +1,9 @@
 package ghttp
 
-import "net/http"
+import (
+	"context"
+	"net/http"
+)
 
 var Default = NewClient()
 
@@ -15,3 +18,19 @@ func Put(url string, req interface{}, result interface{}, opts ...Option) (*http
 func Get(url string, result interface{}, opts ...Option) (*http.Response, error) {
 	return Default.Get(url, result, opts...)
 }
+
+func DownloadAndExtract(url string, dir string, opts ...Option) error {
+	return Default.DownloadAndExtract(url, dir, opts...)
+}
+
+func GetStream(url string, fn StreamFunc, opts ...Option) error {
+	return Default.GetStream(url, fn, opts...)
+}
+
+func DownloadResume(url string, path string, opts ...Option) error {
+	return Default.DownloadResume(url, path, opts...)
+}
+
+func Parallel(ctx context.Context, specs []Spec, limit int) ([]Result, error) {
+	return Default.Parallel(ctx, specs, limit)
+}
@@ -0,0 +1,29 @@
+package ghttp
+
+import (
+	"crypto/rand"
+	"fmt"
+)
+
+const idempotencyHeader = "Idempotency-Key"
+
+// newUUID 生成随机的UUID v4
+func newUUID() string {
+	var b [16]byte
+	rand.Read(b[:])
+	b[6] = b[6]&0x0f | 0x40
+	b[8] = b[8]&0x3f | 0x80
+	return fmt.Sprintf("%x-%x-%x-%x-%x", b[0:4], b[4:6], b[6:8], b[8:10], b[10:])
+}
+
+// setIdempotencyKey 在构建请求时设置一次,之后的重试使用相同的key
+func (o *Options) setIdempotencyKey(req *Request) {
+	key := o.IdempotencyKey
+	if key == "" && o.AutoIdempotency && !isIdempotent(req.Method) && req.Header.Get(idempotencyHeader) == "" {
+		key = newUUID()
+	}
+
+	if key != "" {
+		req.Header.Set(idempotencyHeader, key)
+	}
+}
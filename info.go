@@ -0,0 +1,31 @@
+package ghttp
+
+import (
+	"time"
+)
+
+// RequestInfo 请求的实际执行情况,通过WithRequestInfo获取,用于记录实际发送的内容和每次执行的耗时
+type RequestInfo struct {
+	Request  *Request  // 最后一次发送的请求,已经过hook修改
+	Attempts []Attempt // 每次执行的情况,包括重试
+	redact   []string
+}
+
+// Attempt 单次执行
+type Attempt struct {
+	Start    time.Time     // 开始时间
+	Duration time.Duration // 收到应答头或出错的耗时,不包括读取body
+	Status   int           // 应答状态码,出错时为0
+	Err      error         // 出错时非nil
+	Cache    string        // 缓存结果,如CacheHit,未使用缓存时为空
+}
+
+func (info *RequestInfo) add(ev *Event) {
+	a := Attempt{Start: ev.Start, Duration: time.Since(ev.Start), Err: ev.Err, Cache: ev.Cache}
+	if ev.Rsp != nil {
+		a.Status = ev.Rsp.StatusCode
+	}
+
+	info.Request = ev.Req
+	info.Attempts = append(info.Attempts, a)
+}
@@ -0,0 +1,65 @@
+package ghttp
+
+import (
+	"encoding/json"
+	"iter"
+)
+
+// Items 以迭代器的方式逐个返回NDJSON或JSON数组中的元素,循环开始时才发送请求
+// 出错时返回一次零值和错误后结束,提前break会关闭连接
+func Items[T any](c *Client, url string, opts ...Option) iter.Seq2[T, error] {
+	if c == nil {
+		c = Default
+	}
+
+	return func(yield func(T, error) bool) {
+		stopped := false
+		err := c.GetStream(url, func(dec *json.Decoder) error {
+			var item T
+			if err := dec.Decode(&item); err != nil {
+				return err
+			}
+			if !yield(item, nil) {
+				stopped = true
+				return ErrStopStream
+			}
+			return nil
+		}, opts...)
+
+		if err != nil && !stopped {
+			var zero T
+			yield(zero, err)
+		}
+	}
+}
+
+// NextPageFunc 根据当前页的应答和数据返回下一页的url,返回空字符串时结束
+type NextPageFunc[P any] func(rsp *Response, page P) string
+
+// Pages 以迭代器的方式逐页请求,每次循环时才请求下一页
+// 出错时返回一次零值和错误后结束,每次range都从第一页重新开始
+func Pages[P any](c *Client, url string, next NextPageFunc[P], opts ...Option) iter.Seq2[P, error] {
+	if c == nil {
+		c = Default
+	}
+
+	return func(yield func(P, error) bool) {
+		// 不修改捕获的url,迭代器可以多次使用
+		u := url
+		for u != "" {
+			var page P
+			rsp, err := c.Get(u, &page, opts...)
+			if err != nil {
+				var zero P
+				yield(zero, err)
+				return
+			}
+
+			if !yield(page, nil) {
+				return
+			}
+
+			u = next(rsp, page)
+		}
+	}
+}
@@ -0,0 +1,42 @@
+package ghttp
+
+import "sync"
+
+// Lazy 延迟解码的结果,DoRequest只读取body,第一次调用Get时才解码
+// 用于热点路径上可能不需要结果的场景,如只关心状态码
+type Lazy[T any] struct {
+	once   sync.Once
+	data   []byte
+	decode func(result interface{}) error
+	value  T
+	err    error
+}
+
+// lazyResult 用于在DoRequest中识别Lazy
+type lazyResult interface {
+	setLazy(data []byte, decode func(result interface{}) error)
+}
+
+func (l *Lazy[T]) setLazy(data []byte, decode func(result interface{}) error) {
+	l.data = data
+	l.decode = decode
+}
+
+// Get 解码并返回结果,只会解码一次
+func (l *Lazy[T]) Get() (T, error) {
+	l.once.Do(func() {
+		if l.decode == nil {
+			l.err = ErrNoData
+			return
+		}
+
+		l.err = l.decode(&l.value)
+	})
+
+	return l.value, l.err
+}
+
+// Bytes 返回原始body,已转为utf-8
+func (l *Lazy[T]) Bytes() []byte {
+	return l.data
+}
@@ -0,0 +1,69 @@
+package ghttp
+
+import (
+	"context"
+	"sync"
+)
+
+// InflightLimiter 限制同时执行的请求数,从发送请求到body关闭期间占用名额
+// 超过时排队等待,或者在FailFast时直接返回ErrTooManyRequests
+type InflightLimiter struct {
+	Max      int  // 最大并发数
+	PerHost  bool // 是否按host分别限制
+	FailFast bool // 超过时不等待,直接返回错误
+	mutex    sync.Mutex
+	slots    map[string]chan struct{}
+}
+
+// NewInflightLimiter 创建InflightLimiter
+func NewInflightLimiter(max int, perHost bool) *InflightLimiter {
+	return &InflightLimiter{Max: max, PerHost: perHost}
+}
+
+func (l *InflightLimiter) get(host string) chan struct{} {
+	key := ""
+	if l.PerHost {
+		key = host
+	}
+
+	l.mutex.Lock()
+	defer l.mutex.Unlock()
+	if l.slots == nil {
+		l.slots = make(map[string]chan struct{})
+	}
+
+	ch, ok := l.slots[key]
+	if !ok {
+		ch = make(chan struct{}, l.Max)
+		l.slots[key] = ch
+	}
+
+	return ch
+}
+
+// acquire 获取名额,返回释放函数,可以多次调用
+func (l *InflightLimiter) acquire(ctx context.Context, host string) (func(), error) {
+	if l.Max <= 0 {
+		return func() {}, nil
+	}
+
+	ch := l.get(host)
+	if l.FailFast {
+		select {
+		case ch <- struct{}{}:
+		default:
+			return nil, ErrTooManyRequests
+		}
+	} else {
+		select {
+		case ch <- struct{}{}:
+		case <-ctx.Done():
+			return nil, ctx.Err()
+		}
+	}
+
+	once := sync.Once{}
+	return func() {
+		once.Do(func() { <-ch })
+	}, nil
+}
@@ -0,0 +1,118 @@
+package ghttp
+
+import (
+	"net/url"
+	"strings"
+)
+
+// ParseLinkHeader 解析应答中的Link头(RFC 8288),返回rel到地址的映射,如next,prev,first,last
+// 相对地址按请求地址转换为绝对地址,同一rel出现多次时使用第一个
+func ParseLinkHeader(rsp *Response) map[string]string {
+	var base *url.URL
+	if rsp.Request != nil {
+		base = rsp.Request.URL
+	}
+
+	links := make(map[string]string)
+	for _, v := range rsp.Header.Values("Link") {
+		for v != "" {
+			var target string
+			var rels []string
+			target, rels, v = parseLink(v)
+			if target == "" {
+				continue
+			}
+			if base != nil {
+				if u, err := base.Parse(target); err == nil {
+					target = u.String()
+				}
+			}
+			for _, rel := range rels {
+				rel = strings.ToLower(rel)
+				if _, ok := links[rel]; !ok {
+					links[rel] = target
+				}
+			}
+		}
+	}
+
+	return links
+}
+
+// parseLink 解析一个link-value,返回地址,rel和剩余部分,格式错误时跳过到下一个逗号
+func parseLink(s string) (string, []string, string) {
+	s = strings.TrimLeft(s, " \t,")
+	if !strings.HasPrefix(s, "<") {
+		return "", nil, skipLink(s)
+	}
+	end := strings.IndexByte(s, '>')
+	if end < 0 {
+		return "", nil, ""
+	}
+	target := strings.TrimSpace(s[1:end])
+	s = s[end+1:]
+
+	var rels []string
+	for {
+		s = strings.TrimLeft(s, " \t")
+		if !strings.HasPrefix(s, ";") {
+			break
+		}
+		s = strings.TrimLeft(s[1:], " \t")
+
+		// link-param = token BWS [ "=" BWS ( token / quoted-string ) ]
+		i := strings.IndexAny(s, "=;,")
+		if i < 0 {
+			i = len(s)
+		}
+		key := strings.ToLower(strings.TrimSpace(s[:i]))
+		s = s[i:]
+		value := ""
+		if strings.HasPrefix(s, "=") {
+			value, s = parseParamValue(strings.TrimLeft(s[1:], " \t"))
+		}
+		// rel只使用第一次出现的值,可以包含多个以空格分隔的关系
+		if key == "rel" && rels == nil {
+			rels = strings.Fields(value)
+		}
+	}
+
+	return target, rels, skipLink(s)
+}
+
+// parseParamValue 解析token或quoted-string
+func parseParamValue(s string) (string, string) {
+	if !strings.HasPrefix(s, `"`) {
+		i := strings.IndexAny(s, ";,")
+		if i < 0 {
+			i = len(s)
+		}
+		return strings.TrimSpace(s[:i]), s[i:]
+	}
+
+	b := strings.Builder{}
+	for i := 1; i < len(s); i++ {
+		switch s[i] {
+		case '\\':
+			if i+1 < len(s) {
+				i++
+				b.WriteByte(s[i])
+			}
+		case '"':
+			return b.String(), s[i+1:]
+		default:
+			b.WriteByte(s[i])
+		}
+	}
+
+	return b.String(), ""
+}
+
+// skipLink 跳过到下一个link-value
+func skipLink(s string) string {
+	if i := strings.IndexByte(s, ','); i >= 0 {
+		return s[i+1:]
+	}
+
+	return ""
+}
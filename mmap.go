@@ -0,0 +1,80 @@
+package ghttp
+
+import (
+	"bytes"
+	"io"
+	"io/ioutil"
+	"os"
+	"runtime"
+	"strconv"
+	"sync"
+	"sync/atomic"
+)
+
+// mappedFile 通过mmap映射的文件,用于大文件上传,避免读取到堆中
+// 每个body持有一个引用,全部Close后解除映射,未Close的由finalizer兜底
+type mappedFile struct {
+	data   []byte
+	mapped bool
+	refs   int32
+}
+
+// openMapped 64位平台且为普通文件时使用mmap,否则或失败时回退为读取整个文件
+func openMapped(path string) (*mappedFile, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	fi, err := f.Stat()
+	if err != nil {
+		return nil, err
+	}
+
+	if strconv.IntSize == 64 && fi.Mode().IsRegular() && fi.Size() > 0 {
+		if data, err := mmap(f, fi.Size()); err == nil {
+			m := &mappedFile{data: data, mapped: true, refs: 1}
+			runtime.SetFinalizer(m, (*mappedFile).unmap)
+			return m, nil
+		}
+	}
+
+	data, err := ioutil.ReadAll(f)
+	if err != nil {
+		return nil, err
+	}
+
+	return &mappedFile{data: data, refs: 1}, nil
+}
+
+// reader 创建body,Close时释放引用
+func (m *mappedFile) reader() io.ReadCloser {
+	atomic.AddInt32(&m.refs, 1)
+	return &mappedReader{Reader: bytes.NewReader(m.data), m: m}
+}
+
+func (m *mappedFile) release() {
+	if atomic.AddInt32(&m.refs, -1) == 0 {
+		m.unmap()
+	}
+}
+
+func (m *mappedFile) unmap() {
+	if m.mapped {
+		m.mapped = false
+		runtime.SetFinalizer(m, nil)
+		munmap(m.data)
+	}
+}
+
+type mappedReader struct {
+	*bytes.Reader
+	m    *mappedFile
+	once sync.Once
+}
+
+func (r *mappedReader) Close() error {
+	r.once.Do(r.m.release)
+	return nil
+}
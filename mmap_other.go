@@ -0,0 +1,16 @@
+//go:build !unix
+
+package ghttp
+
+import (
+	"os"
+)
+
+// mmap 不支持时回退为普通读取
+func mmap(f *os.File, size int64) ([]byte, error) {
+	return nil, ErrNotSupport
+}
+
+func munmap(data []byte) error {
+	return nil
+}
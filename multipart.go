@@ -0,0 +1,114 @@
+package ghttp
+
+import (
+	"bytes"
+	"io/ioutil"
+	"mime/multipart"
+	"net/textproto"
+	"path/filepath"
+	"sort"
+	"strings"
+)
+
+// Form 同时包含普通字段和文件的multipart表单,作为请求body使用
+// 普通字段按名字排序在前,文件按添加顺序在后,保证每次生成的顺序一致
+type Form struct {
+	Fields interface{} // url.Values,map或struct,按form tag编码
+	Files  []*FormFile //
+}
+
+// FormFile 表单中的文件
+type FormFile struct {
+	Field       string // 字段名
+	FileName    string // 文件名
+	ContentType string // 为空时使用application/octet-stream
+	Data        []byte // 文件内容,为nil时读取Path
+	Path        string //
+}
+
+// NewForm 创建表单,fields可以为nil
+func NewForm(fields interface{}) *Form {
+	return &Form{Fields: fields}
+}
+
+// AddFile 添加本地文件,文件名使用path中的文件名
+func (f *Form) AddFile(field string, path string) *Form {
+	f.Files = append(f.Files, &FormFile{Field: field, FileName: filepath.Base(path), Path: path})
+	return f
+}
+
+// AddFileData 添加内存中的文件
+func (f *Form) AddFileData(field string, fileName string, data []byte) *Form {
+	f.Files = append(f.Files, &FormFile{Field: field, FileName: fileName, Data: data})
+	return f
+}
+
+// encodeMultipart 编码Form,返回body和带boundary的Content-Type
+func (o *Options) encodeMultipart(f *Form) ([]byte, string, error) {
+	buf := &bytes.Buffer{}
+	w := multipart.NewWriter(buf)
+
+	if f.Fields != nil {
+		values, err := toUrlValue(f.Fields, o.newValueEncoder("form"))
+		if err != nil {
+			return nil, "", err
+		}
+
+		keys := make([]string, 0, len(values))
+		for k := range values {
+			keys = append(keys, k)
+		}
+		sort.Strings(keys)
+
+		for _, k := range keys {
+			for _, v := range values[k] {
+				data, err := encodeCharset([]byte(v), o.Charset)
+				if err != nil {
+					return nil, "", err
+				}
+				if err := w.WriteField(k, string(data)); err != nil {
+					return nil, "", err
+				}
+			}
+		}
+	}
+
+	for _, file := range f.Files {
+		data := file.Data
+		if data == nil && file.Path != "" {
+			var err error
+			if data, err = ioutil.ReadFile(file.Path); err != nil {
+				return nil, "", err
+			}
+		}
+
+		contentType := file.ContentType
+		if contentType == "" {
+			contentType = TypeOctetStream
+		}
+
+		h := make(textproto.MIMEHeader)
+		h.Set("Content-Disposition", `form-data; name="`+escapeQuotes(file.Field)+`"; filename="`+escapeQuotes(file.FileName)+`"`)
+		h.Set("Content-Type", contentType)
+		part, err := w.CreatePart(h)
+		if err != nil {
+			return nil, "", err
+		}
+		if _, err := part.Write(data); err != nil {
+			return nil, "", err
+		}
+	}
+
+	if err := w.Close(); err != nil {
+		return nil, "", err
+	}
+
+	return buf.Bytes(), w.FormDataContentType(), nil
+}
+
+var quoteEscaper = strings.NewReplacer("\\", "\\\\", `"`, "\\\"")
+
+// escapeQuotes 同mime/multipart中的实现
+func escapeQuotes(s string) string {
+	return quoteEscaper.Replace(s)
+}
@@ -0,0 +1,174 @@
+package ntlm
+
+import (
+	"bytes"
+	"crypto/hmac"
+	"crypto/md5"
+	"crypto/rand"
+	"encoding/binary"
+	"errors"
+	"strings"
+	"time"
+	"unicode/utf16"
+)
+
+var ErrInvalidChallenge = errors.New("ntlm: invalid challenge message")
+
+var signature = []byte("NTLMSSP\x00")
+
+const (
+	flagUnicode                 = 0x00000001
+	flagRequestTarget           = 0x00000004
+	flagNTLM                    = 0x00000200
+	flagAlwaysSign              = 0x00008000
+	flagExtendedSessionSecurity = 0x00080000
+	flagTargetInfo              = 0x00800000
+	flag128                     = 0x20000000
+	flag56                      = 0x80000000
+
+	defaultFlags = flagUnicode | flagRequestTarget | flagNTLM | flagAlwaysSign |
+		flagExtendedSessionSecurity | flagTargetInfo | flag128 | flag56
+)
+
+const (
+	avEOL       = 0x0000
+	avTimestamp = 0x0007
+)
+
+// negotiateMessage Type1消息,不携带域名和工作站
+func negotiateMessage() []byte {
+	msg := make([]byte, 32)
+	copy(msg, signature)
+	binary.LittleEndian.PutUint32(msg[8:], 1)
+	binary.LittleEndian.PutUint32(msg[12:], defaultFlags)
+	// domain和workstation的security buffer为空,偏移指向消息末尾
+	binary.LittleEndian.PutUint32(msg[20:], 32)
+	binary.LittleEndian.PutUint32(msg[28:], 32)
+	return msg
+}
+
+// challenge Type2消息中需要的字段
+type challenge struct {
+	flags      uint32
+	server     []byte
+	targetInfo []byte
+}
+
+func parseChallenge(msg []byte) (*challenge, error) {
+	if len(msg) < 48 || !bytes.Equal(msg[:8], signature) || binary.LittleEndian.Uint32(msg[8:]) != 2 {
+		return nil, ErrInvalidChallenge
+	}
+
+	c := &challenge{
+		flags:  binary.LittleEndian.Uint32(msg[20:]),
+		server: msg[24:32],
+	}
+
+	n := int(binary.LittleEndian.Uint16(msg[40:]))
+	off := int(binary.LittleEndian.Uint32(msg[44:]))
+	if n > 0 {
+		if off+n > len(msg) {
+			return nil, ErrInvalidChallenge
+		}
+		c.targetInfo = msg[off : off+n]
+	}
+
+	return c, nil
+}
+
+// timestamp 返回TargetInfo中的MsvAvTimestamp
+func (c *challenge) timestamp() ([]byte, bool) {
+	info := c.targetInfo
+	for len(info) >= 4 {
+		id := binary.LittleEndian.Uint16(info)
+		n := int(binary.LittleEndian.Uint16(info[2:]))
+		if id == avEOL || 4+n > len(info) {
+			break
+		}
+		if id == avTimestamp && n == 8 {
+			return info[4:12], true
+		}
+		info = info[4+n:]
+	}
+
+	return nil, false
+}
+
+func toUnicode(s string) []byte {
+	u := utf16.Encode([]rune(s))
+	b := make([]byte, len(u)*2)
+	for i, c := range u {
+		binary.LittleEndian.PutUint16(b[i*2:], c)
+	}
+	return b
+}
+
+func hmacMD5(key []byte, data ...[]byte) []byte {
+	h := hmac.New(md5.New, key)
+	for _, d := range data {
+		h.Write(d)
+	}
+	return h.Sum(nil)
+}
+
+// ntowfv2 MS-NLMP 3.3.2
+func ntowfv2(user, password, domain string) []byte {
+	return hmacMD5(md4(toUnicode(password)), toUnicode(strings.ToUpper(user)+domain))
+}
+
+// filetime 从1601-01-01开始的100纳秒数
+func filetime(t time.Time) []byte {
+	b := make([]byte, 8)
+	binary.LittleEndian.PutUint64(b, uint64(t.UnixNano()/100+116444736000000000))
+	return b
+}
+
+// ntlmv2Response 返回NtChallengeResponse和LmChallengeResponse
+func ntlmv2Response(key []byte, c *challenge, ts []byte, client []byte) ([]byte, []byte) {
+	temp := make([]byte, 0, 32+len(c.targetInfo))
+	temp = append(temp, 1, 1, 0, 0, 0, 0, 0, 0)
+	temp = append(temp, ts...)
+	temp = append(temp, client...)
+	temp = append(temp, 0, 0, 0, 0)
+	temp = append(temp, c.targetInfo...)
+	temp = append(temp, 0, 0, 0, 0)
+
+	proof := hmacMD5(key, c.server, temp)
+	nt := append(proof, temp...)
+	lm := append(hmacMD5(key, c.server, client), client...)
+	return nt, lm
+}
+
+// authenticateMessage Type3消息,使用NTLMv2,不携带MIC和会话密钥
+func authenticateMessage(c *challenge, domain, user, password, workstation string) ([]byte, error) {
+	client := make([]byte, 8)
+	if _, err := rand.Read(client); err != nil {
+		return nil, err
+	}
+
+	ts, hasTime := c.timestamp()
+	if !hasTime {
+		ts = filetime(time.Now())
+	}
+
+	nt, lm := ntlmv2Response(ntowfv2(user, password, domain), c, ts, client)
+	// 服务器提供了时间戳时,LmChallengeResponse应为全0
+	if hasTime {
+		lm = make([]byte, 24)
+	}
+
+	flags := c.flags & defaultFlags
+	fields := [][]byte{lm, nt, toUnicode(domain), toUnicode(user), toUnicode(workstation), nil}
+	msg := make([]byte, 64)
+	copy(msg, signature)
+	binary.LittleEndian.PutUint32(msg[8:], 3)
+	for i, f := range fields {
+		pos := 12 + i*8
+		binary.LittleEndian.PutUint16(msg[pos:], uint16(len(f)))
+		binary.LittleEndian.PutUint16(msg[pos+2:], uint16(len(f)))
+		binary.LittleEndian.PutUint32(msg[pos+4:], uint32(len(msg)))
+		msg = append(msg, f...)
+	}
+	binary.LittleEndian.PutUint32(msg[60:], flags)
+	return msg, nil
+}
@@ -0,0 +1,152 @@
+// Package ntlm 为ghttp提供NTLM认证,用于企业代理和Windows集成认证的内网服务
+// Negotiate(SPNEGO)时使用NTLM令牌,不支持Kerberos
+//
+//	client := ghttp.NewClient(ghttp.WithTransport(ntlm.NewTransport("DOMAIN", "user", "password", nil)))
+package ntlm
+
+import (
+	"bytes"
+	"encoding/base64"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"strings"
+)
+
+// Transport 收到401或407且服务器支持NTLM或Negotiate时,在同一个连接上完成三次握手
+// 代理认证仅支持http请求,https通过CONNECT建立隧道,无法进行多次握手
+type Transport struct {
+	Domain      string            //
+	User        string            //
+	Password    string            //
+	Workstation string            // 可以为空
+	Next        http.RoundTripper // 为nil时使用http.DefaultTransport
+}
+
+// NewTransport 创建Transport,next为nil时使用http.DefaultTransport
+func NewTransport(domain, user, password string, next http.RoundTripper) *Transport {
+	return &Transport{Domain: domain, User: user, Password: password, Next: next}
+}
+
+func (t *Transport) next() http.RoundTripper {
+	if t.Next != nil {
+		return t.Next
+	}
+
+	return http.DefaultTransport
+}
+
+func (t *Transport) RoundTrip(req *http.Request) (*http.Response, error) {
+	// 握手过程中需要多次发送body
+	if req.Body != nil && req.GetBody == nil {
+		data, err := ioutil.ReadAll(req.Body)
+		req.Body.Close()
+		if err != nil {
+			return nil, err
+		}
+		req.GetBody = func() (io.ReadCloser, error) {
+			return ioutil.NopCloser(bytes.NewReader(data)), nil
+		}
+	}
+
+	rsp, err := t.send(req, "", "")
+	if err != nil {
+		return nil, err
+	}
+
+	var authenticate, authorization string
+	switch rsp.StatusCode {
+	case http.StatusUnauthorized:
+		authenticate, authorization = "Www-Authenticate", "Authorization"
+	case http.StatusProxyAuthRequired:
+		authenticate, authorization = "Proxy-Authenticate", "Proxy-Authorization"
+	default:
+		return rsp, nil
+	}
+
+	scheme := findScheme(rsp.Header.Values(authenticate))
+	if scheme == "" {
+		return rsp, nil
+	}
+
+	// 读完body才能复用连接,后续的握手必须在同一个连接上
+	drain(rsp)
+	rsp, err = t.send(req, authorization, scheme+" "+base64.StdEncoding.EncodeToString(negotiateMessage()))
+	if err != nil {
+		return nil, err
+	}
+
+	token := findToken(rsp.Header.Values(authenticate), scheme)
+	if token == nil {
+		return rsp, nil
+	}
+
+	c, err := parseChallenge(token)
+	if err != nil {
+		drain(rsp)
+		return nil, err
+	}
+
+	msg, err := authenticateMessage(c, t.Domain, t.User, t.Password, t.Workstation)
+	if err != nil {
+		drain(rsp)
+		return nil, err
+	}
+
+	drain(rsp)
+	return t.send(req, authorization, scheme+" "+base64.StdEncoding.EncodeToString(msg))
+}
+
+// send 复制请求并设置认证头,不修改原始请求
+func (t *Transport) send(req *http.Request, key, value string) (*http.Response, error) {
+	r := req.Clone(req.Context())
+	if req.GetBody != nil {
+		body, err := req.GetBody()
+		if err != nil {
+			return nil, err
+		}
+		r.Body = body
+	}
+	if key != "" {
+		r.Header.Set(key, value)
+	}
+
+	return t.next().RoundTrip(r)
+}
+
+func drain(rsp *http.Response) {
+	io.Copy(ioutil.Discard, rsp.Body)
+	rsp.Body.Close()
+}
+
+// findScheme 优先使用Negotiate
+func findScheme(values []string) string {
+	scheme := ""
+	for _, v := range values {
+		for _, s := range strings.Split(v, ",") {
+			s = strings.TrimSpace(s)
+			if strings.EqualFold(s, "Negotiate") {
+				return "Negotiate"
+			}
+			if strings.EqualFold(s, "NTLM") {
+				scheme = "NTLM"
+			}
+		}
+	}
+
+	return scheme
+}
+
+// findToken 返回scheme后的base64令牌
+func findToken(values []string, scheme string) []byte {
+	for _, v := range values {
+		if len(v) > len(scheme)+1 && strings.EqualFold(v[:len(scheme)], scheme) && v[len(scheme)] == ' ' {
+			data, err := base64.StdEncoding.DecodeString(strings.TrimSpace(v[len(scheme)+1:]))
+			if err == nil {
+				return data
+			}
+		}
+	}
+
+	return nil
+}
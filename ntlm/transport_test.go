@@ -0,0 +1,95 @@
+package ntlm
+
+import (
+	"bytes"
+	"encoding/base64"
+	"encoding/binary"
+	"encoding/hex"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/jeckbjy/ghttp"
+)
+
+func TestNTLMv2(t *testing.T) {
+	// MS-NLMP 4.2.4
+	if h := hex.EncodeToString(md4(toUnicode("Password"))); h != "a4f49c406510bdcab6824ee7c30fd852" {
+		t.Errorf("unexpected NTOWFv1: %s", h)
+	}
+
+	key := ntowfv2("User", "Password", "Domain")
+	if h := hex.EncodeToString(key); h != "0c868a403bfd7a93a3001ef22ef02e3f" {
+		t.Errorf("unexpected NTOWFv2: %s", h)
+	}
+
+	info, _ := hex.DecodeString("02000c0044006f006d00610069006e0001000c0053006500720076006500720000000000")
+	server, _ := hex.DecodeString("0123456789abcdef")
+	client, _ := hex.DecodeString("aaaaaaaaaaaaaaaa")
+	nt, lm := ntlmv2Response(key, &challenge{server: server, targetInfo: info}, make([]byte, 8), client)
+	if h := hex.EncodeToString(nt[:16]); h != "68cd0ab851e51c96aabc927bebef6a1c" {
+		t.Errorf("unexpected NTProofStr: %s", h)
+	}
+	if h := hex.EncodeToString(lm); h != "86c35097ac9cec102554764a57cccc19aaaaaaaaaaaaaaaa" {
+		t.Errorf("unexpected LMv2: %s", h)
+	}
+}
+
+// challengeMessage 构造测试用的Type2消息
+func challengeMessage(server []byte) []byte {
+	msg := make([]byte, 48)
+	copy(msg, signature)
+	binary.LittleEndian.PutUint32(msg[8:], 2)
+	binary.LittleEndian.PutUint32(msg[20:], defaultFlags)
+	copy(msg[24:], server)
+	binary.LittleEndian.PutUint32(msg[44:], 48)
+	return msg
+}
+
+func TestTransport(t *testing.T) {
+	server := []byte("12345678")
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		auth := r.Header.Get("Authorization")
+		switch {
+		case auth == "":
+			w.Header().Add("WWW-Authenticate", "NTLM")
+			w.WriteHeader(http.StatusUnauthorized)
+		case strings.HasPrefix(auth, "NTLM "):
+			msg, _ := base64.StdEncoding.DecodeString(auth[5:])
+			if binary.LittleEndian.Uint32(msg[8:]) == 1 {
+				w.Header().Set("WWW-Authenticate", "NTLM "+base64.StdEncoding.EncodeToString(challengeMessage(server)))
+				w.WriteHeader(http.StatusUnauthorized)
+				return
+			}
+
+			// 验证NtChallengeResponse
+			field := func(i int) []byte {
+				n := binary.LittleEndian.Uint16(msg[12+i*8:])
+				off := binary.LittleEndian.Uint32(msg[16+i*8:])
+				return msg[off : off+uint32(n)]
+			}
+			nt := field(1)
+			key := ntowfv2("user", "password", "DOMAIN")
+			if !bytes.Equal(hmacMD5(key, server, nt[16:]), nt[:16]) || !bytes.Equal(field(3), toUnicode("user")) {
+				w.WriteHeader(http.StatusForbidden)
+				return
+			}
+			body := &bytes.Buffer{}
+			body.ReadFrom(r.Body)
+			w.Write(body.Bytes())
+		default:
+			w.WriteHeader(http.StatusBadRequest)
+		}
+	}))
+	defer srv.Close()
+
+	c := ghttp.NewClient(ghttp.WithTransport(NewTransport("DOMAIN", "user", "password", nil)))
+	var text string
+	if _, err := c.Post(srv.URL, "hello", &text, ghttp.WithContentType(ghttp.TypeText)); err != nil {
+		t.Fatal(err)
+	}
+	if text != "hello" {
+		t.Errorf("unexpected body: %s", text)
+	}
+}
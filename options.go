@@ -2,6 +2,9 @@ package ghttp
 
 import (
 	"context"
+	"crypto/tls"
+	"io"
+	"net"
 	"net/http"
 	"net/url"
 	"time"
@@ -13,18 +16,48 @@ const (
 	TypeForm = "application/x-www-form-urlencoded"
 	TypeHTML = "text/html"
 	TypeText = "text/plain"
+
+	typeXMLText = "text/xml"
+
+	TypeProtobuf      = "application/x-protobuf"
+	typeProtobufAlias = "application/protobuf"
+
+	TypeYAML     = "application/yaml"
+	typeYAMLText = "text/yaml"
+
+	TypeCBOR = "application/cbor"
+
+	TypeNDJSON = "application/x-ndjson"
+
+	TypeMultipart   = "multipart/form-data"
+	TypeOctetStream = "application/octet-stream"
 )
 
 const (
 	UTF8 = "utf-8"
 )
 
+const (
+	TimeFormatUnix      = "unix"      // unix时间戳,单位秒
+	TimeFormatUnixMilli = "unixmilli" // unix时间戳,单位毫秒
+)
+
+// Version ghttp的版本,用于默认的User-Agent
+const Version = "1.0"
+
+// DefaultUserAgent 没有设置User-Agent时使用,替代标准库的Go-http-client,可以在init中修改
+var DefaultUserAgent = "ghttp/" + Version
+
 const (
 	defaultTimeout          = time.Second * 60
 	defaultDialTimeout      = time.Second * 60
 	defaultKeepAlive        = time.Second * 60
 	defaultHandshakeTimeout = time.Second * 60
+	defaultMaxIdleConns     = 100
+	defaultMaxIdlePerHost   = 10
+	defaultIdleConnTimeout  = time.Second * 90
 	defaultContentType      = TypeJSON
+	defaultTimeFormat       = time.RFC3339
 )
 
 var defaultBackoff = NewConstantBackoff(time.Second)
@@ -35,8 +68,9 @@ type Response = http.Response
 type EventType int
 
 const (
-	EventPrev = EventType(0)
-	EventPost = EventType(1)
+	EventPrev        = EventType(0)
+	EventPost        = EventType(1)
+	EventRetryBudget = EventType(2) // 需要重试但RetryBudget没有令牌,Rsp和Err同EventPost,只通知EventBus,不调用Hook
 )
 
 type Event struct {
@@ -45,12 +79,17 @@ type Event struct {
 	Rsp   *Response         //
 	Err   error             //
 	Num   int               // 执行次数
+	Start time.Time         // 本次执行开始时间
+	Route string            // 逻辑路由名,用于metrics和tracing的标签
+	ReqID string            // WithRequestID时的请求ID
 	Datas map[string]string // 扩展参数，由Options传过来
+	Cache string            // 缓存结果,如CacheHit,未使用缓存时为空
 }
 
 func (ev *Event) SetPrev(num int) {
 	ev.Type = EventPrev
 	ev.Num = num
+	ev.Start = time.Now()
 }
 
 func (ev *Event) SetPost(rsp *Response, err error) {
@@ -72,34 +111,173 @@ func (hooks Hooks) Run(ev *Event) error {
 	return nil
 }
 
+// AuthProvider 返回Authorization,可以是token或带scheme的完整值
+type AuthProvider func(ctx context.Context) (string, error)
+
+// DialFunc 建立连接的函数,同net.Dialer.DialContext
+type DialFunc func(ctx context.Context, network, addr string) (net.Conn, error)
+
+// ProxyFunc 返回请求使用的代理,同http.Transport.Proxy,返回nil时不使用代理
+type ProxyFunc func(req *Request) (*url.URL, error)
+
+// HostOptions host对应的参数,key为host,可以带端口,如api.example.com或api.example.com:8443
+type HostOptions map[string][]Option
+
 type Option func(o *Options)
 type Options struct {
 	Context          context.Context   //
 	BaseURL          string            //
-	Timeout          time.Duration     // 超时时间
+	Balancer         Balancer          // 在多个BaseURL间选择,设置后忽略BaseURL
+	HostOptions      HostOptions       // 按请求的host覆盖的参数,请求时解析
+	Timeout          time.Duration     // 每次执行的超时时间
+	OverallTimeout   time.Duration     // 整个请求的超时时间,包括所有重试和重试前的等待
 	DialTimeout      time.Duration     //
 	HandshakeTimeout time.Duration     //
 	KeepAlive        time.Duration     //
 	Retry            int               // 重试次数
+	RetryConditions  []RetryCondition  // 额外的重试条件,满足任一条件即重试
 	Backoff          Backoff           // 每次timeout后等待时间,nil不等待
-	ContentType      string            // 编码格式
+	MaxElapsedTime   time.Duration     // 从开始执行起超过此时间后不再重试,不会中断正在执行的请求
+	RetryBudget      *RetryBudget      // 限制重试的速率,通常在NewClient时设置
+	HintExtractors   []HintExtractor   // 从应答头提取等待时间,优先于Backoff
+	HedgeDelay       time.Duration     // 超过此时间没有应答时再发送一份请求
+	HedgeMax         int               // 最多同时发送的请求数,大于1时启用,仅用于幂等请求
+	Singleflight     []string          // 不为nil时合并相同的并发GET请求,值为参与比较的header
+	Breaker          *CircuitBreaker   // 熔断器
+	Limiter          *InflightLimiter  // 限制同时执行的请求数
+	QuietHours       *QuietHours       // 静默时间,只对NonUrgent的请求生效
+	NonUrgent        bool              // 非紧急的请求,静默时间内等待或拒绝
+	Cache            CacheStorage      // 不为nil时启用缓存,遵循Cache-Control和Expires
+	Conditional      CacheStorage      // 保存ETag和Last-Modified,自动发送条件请求,设置Cache时忽略
+	AuthProvider     AuthProvider      // 每次执行前获取Authorization,覆盖Header中的值
+	MmapBody         string            // 通过mmap读取的文件作为body,设置后忽略请求参数
+	BodyFile         string            // 以流的方式发送的文件,设置后忽略请求参数,不压缩
+	StatusResults    []StatusResult    // 按状态码选择解码的结果,优先于DoRequest的result
+	RawResponse      bool              // 不解压,不读取也不解码body,由调用者读取和关闭
+	PageExtractor    PageExtractor     // FetchAll解析每一页,默认应答为数组,通过Link头翻页
+	MaxPages         int               // FetchAll最多请求的页数,默认1000
+	MaxItems         int               // FetchAll最多获取的元素数,默认100000
+	UserAgent        string            // 为空时使用Header中的值或DefaultUserAgent
+	HostHeader       string            // 覆盖请求的Host,用于直接访问ip
+	RequestInfo      *RequestInfo      // 不为nil时记录实际发送的请求和每次执行的情况
+	Redact           []string          // Dump时隐藏值的header,query参数,表单和json字段,为nil时使用DefaultRedactKeys
+	IdempotencyKey   string            // 非空时设置Idempotency-Key,重试时保持不变
+	RequestIDHeader  string            // 非空时在此header中传递请求ID
+	AutoIdempotency  bool              // 非幂等的请求没有Idempotency-Key时自动生成UUID
+	ServerName       string            // TLS的SNI,为空时使用NewClient时的HostHeader,仅NewClient时有效
+	APIVersion       string            // 接口版本,非空时按VersionPolicy设置和校验
+	VersionPolicy    *VersionPolicy    // 为nil时使用X-API-Version
+	BreakerKey       string            // 熔断器的key,为空时使用Breaker.KeyFunc或host
+	ContentType      string            // 编码格式,为空时proto.Message使用protobuf,否则使用json
+	Accept           string            // 期望的应答格式,为空时使用ContentType,有result时设置Accept
+	StrictAccept     bool              // 应答的Content-Type不符合Accept时返回MediaTypeError
 	Charset          string            // 编码格式,utf-8,GBK
+	JSONMarshal      MarshalFunc       // 自定义json编码,如jsoniter
+	JSONUnmarshal    UnmarshalFunc     // 自定义json解码,设置后忽略JSONConfig
+	JSONConfig       *JSONConfig       // 标准库json解码参数
+	TimeFormat       string            // query和form中time.Time的格式,默认RFC3339,可以是TimeFormatUnix
 	Header           http.Header       // 消息头
 	Query            url.Values        // 查询参数
+	PathParams       map[string]string // url中{name}参数的值
+	RouteName        string            // 逻辑路由名,为空时使用path模板
+	QueryParams      []interface{}     // 以struct或map表示的查询参数,请求时编码,使用query tag
+	NestedStyle      NestedStyle       // 嵌套结构的key格式
+	ArrayStyle       ArrayStyle        // query和form编码时slice的格式,默认重复key
+	OmitZero         bool              // query和form编码时忽略false,0和空字符串等零值
+	BoolFormat       BoolFormat        // query和form编码时bool的格式
+	NilAsEmpty       bool              // query和form编码时nil指针编码为空值,默认忽略
 	Cookies          []*http.Cookie    //
 	Datas            map[string]string // 用户扩展字段
 	Hooks            Hooks             //
+	CSRF             *CSRF             // 编码表单时附加token字段
+	EventBuses       []*EventBus       // 请求级别的事件订阅,在Hooks之后通知
+	DeadLetter       DeadLetterFunc    // 重试次数耗尽后仍然失败时调用
+	Progress         ProgressFunc      // 上传和下载进度回调
+	SchemaWarning    SchemaWarningFunc // json应答与结构体字段不一致时调用,不影响解码结果
+	MaxExtractSize   int64             // 解压后最大字节数,0使用默认值
+	MaxResponseBytes int64             // 应答body最大字节数,按解压后计算,0不限制
+	PingInterval     time.Duration     // WebSocket发送ping的间隔,默认30秒,小于0时不发送
+	MaxMessageSize   int64             // WebSocket单条消息的最大字节数,0不限制
+	Compression      []string          // 接受的应答压缩格式,设置Accept-Encoding
+	RequestEncoding  string            // 请求body的压缩格式,如gzip
+	CompressionInfo  *CompressionInfo  // 不为nil时记录应答的压缩信息
+	Transport        http.RoundTripper // 自定义Transport,仅NewClient时有效
+	HTTPClient       *http.Client      // 自定义http.Client,仅NewClient时有效,不使用Timeout和连接池参数
+	H2C              bool              // http请求使用h2c,仅NewClient时有效
+	TLSKeyLogWriter  io.Writer         // 写入TLS密钥,用于Wireshark解密,仅NewClient时有效
+	TLSConfig        *tls.Config       // 自定义TLS参数,如CA和客户端证书,仅NewClient时有效
+	Proxy            ProxyFunc         // 代理,默认不使用代理,仅NewClient时有效
+	Dialer           *net.Dialer       // 自定义Dialer,仅NewClient时有效,忽略DialTimeout和KeepAlive
+	LocalAddr        net.IP            // 绑定本地地址,用于指定网卡,仅NewClient时有效
+	DialContext      DialFunc          // 自定义拨号函数,优先于Dialer,仅NewClient时有效
+	Resolver         *net.Resolver     // 自定义DNS解析,仅NewClient时有效
+	DNSCache         *DNSCache         // DNS缓存,仅NewClient时有效
+	SocketMark       int               // 连接的SO_MARK,仅linux,仅NewClient时有效
+	TrafficClass     int               // 连接的IP_TOS或IPV6_TCLASS,仅linux,仅NewClient时有效
+
+	// 连接池参数,仅NewClient时有效,默认最多100个空闲连接,每个host最多10个,空闲90秒后关闭
+	// 设置为0时使用http.Transport的默认行为
+	MaxIdleConns          int           // 所有host的最大空闲连接数
+	MaxIdleConnsPerHost   int           // 每个host的最大空闲连接数
+	MaxConnsPerHost       int           // 每个host的最大连接数,包括正在使用的
+	IdleConnTimeout       time.Duration // 空闲连接保持时间
+	ResponseHeaderTimeout time.Duration // 发送请求后等待应答头的时间
+	ExpectContinueTimeout time.Duration // Expect: 100-continue时等待的时间
+	DisableKeepAlives     bool          // 禁用长连接
+
+	DisableStaleRetry  bool // 幂等请求在复用的keep-alive连接上失败时不自动重试
+	RetryNonIdempotent bool // POST等非幂等的请求超时时也重试,默认不重试
+}
+
+// copyShared 复制Option会修改的map,slice去掉多余的容量,append时重新分配,避免并发修改调用者的数据
+func (o *Options) copyShared() {
+	o.Header = o.Header.Clone()
+	if o.Query != nil {
+		o.Query = url.Values(http.Header(o.Query).Clone())
+	}
+	o.Datas = copyStringMap(o.Datas)
+	o.PathParams = copyStringMap(o.PathParams)
+	if o.HostOptions != nil {
+		hosts := make(HostOptions, len(o.HostOptions))
+		for k, v := range o.HostOptions {
+			hosts[k] = v[:len(v):len(v)]
+		}
+		o.HostOptions = hosts
+	}
+
+	o.Cookies = o.Cookies[:len(o.Cookies):len(o.Cookies)]
+	o.Hooks = o.Hooks[:len(o.Hooks):len(o.Hooks)]
+	o.EventBuses = o.EventBuses[:len(o.EventBuses):len(o.EventBuses)]
+	o.RetryConditions = o.RetryConditions[:len(o.RetryConditions):len(o.RetryConditions)]
+	o.HintExtractors = o.HintExtractors[:len(o.HintExtractors):len(o.HintExtractors)]
+	o.StatusResults = o.StatusResults[:len(o.StatusResults):len(o.StatusResults)]
+	o.QueryParams = o.QueryParams[:len(o.QueryParams):len(o.QueryParams)]
+	o.Compression = o.Compression[:len(o.Compression):len(o.Compression)]
+}
+
+func copyStringMap(m map[string]string) map[string]string {
+	if m == nil {
+		return nil
+	}
+
+	r := make(map[string]string, len(m))
+	for k, v := range m {
+		r[k] = v
+	}
+	return r
 }
 
 func (o *Options) setNewDefault() {
 	o.DialTimeout = defaultDialTimeout
 	o.KeepAlive = defaultKeepAlive
 	o.HandshakeTimeout = defaultHandshakeTimeout
+	o.MaxIdleConns = defaultMaxIdleConns
+	o.MaxIdleConnsPerHost = defaultMaxIdlePerHost
+	o.IdleConnTimeout = defaultIdleConnTimeout
 }
 
 func (o *Options) build(opts ...Option) {
 	o.Timeout = defaultTimeout
-	o.ContentType = defaultContentType
 	o.Context = context.Background()
 	o.Backoff = defaultBackoff
 	for _, fn := range opts {
@@ -107,14 +285,43 @@ func (o *Options) build(opts ...Option) {
 	}
 }
 
-func (o *Options) toRawQuery(query url.Values) string {
+// NewOptions 创建Options并依次应用opts,用于扩展包读取参数
+func NewOptions(opts ...Option) *Options {
+	o := &Options{}
+	o.build(opts...)
+	return o
+}
+
+// getContentType 返回请求使用的编码格式,未设置时根据数据类型推断
+func (o *Options) getContentType(req interface{}, result interface{}) string {
+	if o.ContentType != "" {
+		return o.ContentType
+	}
+
+	if isProtoMessage(req) || isProtoMessage(result) {
+		return TypeProtobuf
+	}
+
+	return defaultContentType
+}
+
+func (o *Options) toRawQuery(query url.Values) (string, error) {
 	for k, v := range o.Query {
 		for _, x := range v {
 			query.Add(k, x)
 		}
 	}
 
-	return query.Encode()
+	if len(o.QueryParams) > 0 {
+		enc := o.newValueEncoder("query")
+		for _, params := range o.QueryParams {
+			if err := enc.Encode(query, params); err != nil {
+				return "", err
+			}
+		}
+	}
+
+	return query.Encode(), nil
 }
 
 func (o *Options) AddHeader(key string, value interface{}) {
@@ -122,21 +329,24 @@ func (o *Options) AddHeader(key string, value interface{}) {
 		o.Header = make(http.Header)
 	}
 
-	addValue(o.Header, key, value)
+	addHeaderValue(o.Header, key, value)
 }
 
 func (o *Options) AddHeaders(headers map[string]string) {
 	if o.Header == nil {
 		o.Header = make(http.Header)
 	}
-	addValueMap(o.Query, headers)
+
+	for k, v := range headers {
+		addHeaderValue(o.Header, k, v)
+	}
 }
 
 func (o *Options) AddQuery(key string, value interface{}) {
 	if o.Query == nil {
 		o.Query = make(url.Values)
 	}
-	addValue(o.Query, key, value)
+	addValue(o.Query, key, value, o.TimeFormat)
 }
 
 func (o *Options) AddQueries(queries map[string]string) {
@@ -187,30 +397,79 @@ func (o *Options) AddBasicAuth(username, password string) {
 	o.AddHeader("Authorization", "Basic "+basicAuth(username, password))
 }
 
-func (o *Options) AddBearAuth(auth string) Option {
-	return func(o *Options) {
-		o.AddHeader("Authorization", "Bearer "+auth)
-	}
+func (o *Options) AddBearAuth(auth string) {
+	o.AddHeader("Authorization", "Bearer "+auth)
 }
 
-func (o *Options) AddXJwtToken(token string) Option {
-	return func(o *Options) {
-		o.AddHeader("X-Jwt-Token", token)
+func (o *Options) AddXJwtToken(token string) {
+	o.AddHeader("X-Jwt-Token", token)
+}
+
+func (o *Options) AddXAuthToken(token string) {
+	o.AddHeader("X-Auth-Token", token)
+}
+
+// AddDate 设置Date,使用RFC 7231格式
+func (o *Options) AddDate(t time.Time) {
+	o.setHeader("Date", t)
+}
+
+// AddIfModifiedSince 设置If-Modified-Since
+func (o *Options) AddIfModifiedSince(t time.Time) {
+	o.setHeader("If-Modified-Since", t)
+}
+
+// AddIfUnmodifiedSince 设置If-Unmodified-Since,用于乐观锁更新
+func (o *Options) AddIfUnmodifiedSince(t time.Time) {
+	o.setHeader("If-Unmodified-Since", t)
+}
+
+// AddIfMatch 设置If-Match,etag未加引号时会自动添加
+func (o *Options) AddIfMatch(etags ...string) {
+	o.setHeader("If-Match", joinETags(etags))
+}
+
+// AddIfNoneMatch 设置If-None-Match,etag未加引号时会自动添加
+func (o *Options) AddIfNoneMatch(etags ...string) {
+	o.setHeader("If-None-Match", joinETags(etags))
+}
+
+// AddDepth 设置WebDAV的Depth,取值为DepthZero,DepthOne或DepthInfinity
+func (o *Options) AddDepth(depth string) {
+	o.setHeader("Depth", depth)
+}
+
+// AddDestination 设置WebDAV中COPY和MOVE的目标地址
+func (o *Options) AddDestination(dst string) {
+	o.setHeader("Destination", dst)
+}
+
+// AddOverwrite 设置WebDAV的Overwrite,false时目标存在则失败
+func (o *Options) AddOverwrite(overwrite bool) {
+	if overwrite {
+		o.setHeader("Overwrite", "T")
+	} else {
+		o.setHeader("Overwrite", "F")
 	}
 }
 
-func (o *Options) AddXAuthToken(token string) Option {
-	return func(o *Options) {
-		o.AddHeader("X-Auth-Token", token)
+func (o *Options) setHeader(key string, value interface{}) {
+	if o.Header == nil {
+		o.Header = make(http.Header)
 	}
+
+	o.Header.Del(key)
+	addHeaderValue(o.Header, key, value)
 }
 
 /////////////////////////////////////////////
 // Option func
 /////////////////////////////////////////////
+// WithOptions 使用opts的副本,之后的Option不会修改opts中的map和slice
 func WithOptions(opts *Options) Option {
 	return func(o *Options) {
 		*o = *opts
+		o.copyShared()
 	}
 }
 
@@ -220,18 +479,71 @@ func WithContext(c context.Context) Option {
 	}
 }
 
+// WithBaseURLs 在多个BaseURL间轮询,跳过不健康的BaseURL
+func WithBaseURLs(baseURLs []string) Option {
+	return WithBalancer(NewRoundRobinBalancer(baseURLs...))
+}
+
+// WithBalancer 使用自定义的策略选择BaseURL,如NewWeightedBalancer,NewLeastPendingBalancer
+func WithBalancer(b Balancer) Option {
+	return func(o *Options) {
+		o.Balancer = b
+	}
+}
+
 func WithBaseURL(baseURL string) Option {
 	return func(o *Options) {
 		o.BaseURL = baseURL
 	}
 }
 
+// WithHostOptions 请求的host为host时额外应用opts,在默认参数之后,请求参数之前,如为外部服务设置更长的超时和更少的重试
+// 多次调用时追加,使用Balancer的相对路径请求不生效
+func WithHostOptions(host string, opts ...Option) Option {
+	return func(o *Options) {
+		if o.HostOptions == nil {
+			o.HostOptions = make(HostOptions)
+		}
+		o.HostOptions[host] = append(o.HostOptions[host], opts...)
+	}
+}
+
+// lookup 返回rawURL的host对应的参数,先匹配带端口的host,再匹配不带端口的
+func (h HostOptions) lookup(rawURL string) []Option {
+	if len(h) == 0 {
+		return nil
+	}
+
+	u, err := url.Parse(rawURL)
+	if err != nil {
+		return nil
+	}
+
+	if opts, ok := h[u.Host]; ok {
+		return opts
+	}
+
+	return h[u.Hostname()]
+}
+
 func WithTimeout(t time.Duration) Option {
 	return func(o *Options) {
 		o.Timeout = t
 	}
 }
 
+// WithAttemptTimeout 每次执行的超时时间,同WithTimeout
+func WithAttemptTimeout(t time.Duration) Option {
+	return WithTimeout(t)
+}
+
+// WithOverallTimeout 整个请求的超时时间,包括所有重试和重试前的等待,超时后不再重试
+func WithOverallTimeout(t time.Duration) Option {
+	return func(o *Options) {
+		o.OverallTimeout = t
+	}
+}
+
 func WithDialTimeout(t time.Duration) Option {
 	return func(o *Options) {
 		o.DialTimeout = t
@@ -250,24 +562,379 @@ func WithKeepAlive(t time.Duration) Option {
 	}
 }
 
+// WithMaxIdleConns 设置所有host的最大空闲连接数,仅NewClient时有效
+func WithMaxIdleConns(n int) Option {
+	return func(o *Options) {
+		o.MaxIdleConns = n
+	}
+}
+
+// WithMaxIdleConnsPerHost 设置每个host的最大空闲连接数,仅NewClient时有效
+func WithMaxIdleConnsPerHost(n int) Option {
+	return func(o *Options) {
+		o.MaxIdleConnsPerHost = n
+	}
+}
+
+// WithMaxConnsPerHost 设置每个host的最大连接数,仅NewClient时有效
+func WithMaxConnsPerHost(n int) Option {
+	return func(o *Options) {
+		o.MaxConnsPerHost = n
+	}
+}
+
+// WithIdleConnTimeout 设置空闲连接保持时间,仅NewClient时有效
+func WithIdleConnTimeout(t time.Duration) Option {
+	return func(o *Options) {
+		o.IdleConnTimeout = t
+	}
+}
+
+// WithResponseHeaderTimeout 设置等待应答头的时间,仅NewClient时有效
+func WithResponseHeaderTimeout(t time.Duration) Option {
+	return func(o *Options) {
+		o.ResponseHeaderTimeout = t
+	}
+}
+
+// WithExpectContinueTimeout 设置Expect: 100-continue时等待的时间,仅NewClient时有效
+func WithExpectContinueTimeout(t time.Duration) Option {
+	return func(o *Options) {
+		o.ExpectContinueTimeout = t
+	}
+}
+
+// WithDisableKeepAlives 禁用长连接,每个请求使用新的连接,仅NewClient时有效
+func WithDisableKeepAlives(disable bool) Option {
+	return func(o *Options) {
+		o.DisableKeepAlives = disable
+	}
+}
+
+// WithHTTPClient 使用已有的http.Client,如共享连接池,仅NewClient时有效
+// 如果同时设置了Transport,则替换其Transport,不会修改传入的Client
+func WithHTTPClient(c *http.Client) Option {
+	return func(o *Options) {
+		o.HTTPClient = c
+	}
+}
+
+// WithDialer 使用自定义的Dialer,仅NewClient时有效
+func WithDialer(d *net.Dialer) Option {
+	return func(o *Options) {
+		o.Dialer = d
+	}
+}
+
+// WithLocalAddr 使用指定的本地ip发起连接,仅NewClient时有效
+func WithLocalAddr(ip net.IP) Option {
+	return func(o *Options) {
+		o.LocalAddr = ip
+	}
+}
+
+// WithDialContext 使用自定义的拨号函数,如流量整形或选择VPN,仅NewClient时有效
+func WithDialContext(dial DialFunc) Option {
+	return func(o *Options) {
+		o.DialContext = dial
+	}
+}
+
+// WithResolver 使用自定义的DNS解析,如指定DNS服务器,仅NewClient时有效
+func WithResolver(r *net.Resolver) Option {
+	return func(o *Options) {
+		o.Resolver = r
+	}
+}
+
+// WithDNSCache 使用进程内的DNS缓存,可以在多个Client间共享,仅NewClient时有效
+func WithDNSCache(cache *DNSCache) Option {
+	return func(o *Options) {
+		o.DNSCache = cache
+	}
+}
+
+// WithSocketMark 设置连接的SO_MARK,用于iptables和tc识别ghttp的流量,需要CAP_NET_ADMIN权限,仅linux
+// 仅NewClient且没有设置DialContext时有效
+func WithSocketMark(mark int) Option {
+	return func(o *Options) {
+		o.SocketMark = mark
+	}
+}
+
+// WithTrafficClass 设置连接的IP_TOS或IPV6_TCLASS,如DSCP标记,仅linux
+// 仅NewClient且没有设置DialContext时有效
+func WithTrafficClass(tclass int) Option {
+	return func(o *Options) {
+		o.TrafficClass = tclass
+	}
+}
+
+// WithTLSKeyLogWriter 以NSS key log格式写入TLS密钥,用于Wireshark解密抓包,仅调试时使用
+// 仅NewClient且没有设置Transport和HTTPClient时有效
+func WithTLSKeyLogWriter(w io.Writer) Option {
+	return func(o *Options) {
+		o.TLSKeyLogWriter = w
+	}
+}
+
+// WithTLSConfig 自定义TLS参数,如CA,客户端证书和最低版本,仅NewClient时有效
+// 会复制一份,ServerName和TLSKeyLogWriter依然生效
+func WithTLSConfig(cfg *tls.Config) Option {
+	return func(o *Options) {
+		o.TLSConfig = cfg
+	}
+}
+
+// WithProxy 设置代理,如http.ProxyFromEnvironment,仅NewClient时有效
+func WithProxy(proxy ProxyFunc) Option {
+	return func(o *Options) {
+		o.Proxy = proxy
+	}
+}
+
+// WithProxyURL 所有请求使用proxyURL作为代理,支持http,https和socks5,仅NewClient时有效
+// proxyURL格式错误时请求返回解析的错误
+func WithProxyURL(proxyURL string) Option {
+	u, err := url.Parse(proxyURL)
+	return WithProxy(func(req *Request) (*url.URL, error) {
+		return u, err
+	})
+}
+
+// WithDoH 通过DNS-over-HTTPS解析域名并缓存,如https://1.1.1.1/dns-query,仅NewClient时有效
+// DoH服务器使用域名时,需要通过NewDoHResolver设置Bootstrap,再使用WithDNSCache
+func WithDoH(serverURL string) Option {
+	return WithDNSCache(NewDNSCache(NewDoHResolver(serverURL), 0))
+}
+
+// WithH2C http请求使用不加密的HTTP/2(prior knowledge),如集群内的gRPC-gateway,https不受影响
+// 仅NewClient且没有设置Transport和HTTPClient时有效
+func WithH2C() Option {
+	return func(o *Options) {
+		o.H2C = true
+	}
+}
+
+// WithTransport 使用自定义的RoundTripper,仅NewClient时有效
+func WithTransport(t http.RoundTripper) Option {
+	return func(o *Options) {
+		o.Transport = t
+	}
+}
+
+// WithSharedTransport 使用SharedTransport,多个Client共享连接池,仅NewClient时有效
+// 用于按租户等创建大量Client的场景,忽略拨号,TLS和连接池参数
+func WithSharedTransport() Option {
+	return func(o *Options) {
+		o.Transport = SharedTransport()
+	}
+}
+
+// WithSingleflight 合并相同的并发GET和HEAD请求,只发送一次并共享应答,用于配置等容易被同时请求的接口
+// 相同指method,url和headers中的header都相同,headers为空时使用DefaultSingleflightHeaders
+// 第一个请求被取消时,等待的请求也会失败
+func WithSingleflight(headers ...string) Option {
+	if len(headers) == 0 {
+		headers = DefaultSingleflightHeaders
+	}
+
+	return func(o *Options) {
+		o.Singleflight = headers
+	}
+}
+
+// WithHedging 幂等请求超过delay没有应答时再发送一份,最多maxAttempts份,返回最先成功的应答并取消其他请求
+func WithHedging(delay time.Duration, maxAttempts int) Option {
+	return func(o *Options) {
+		o.HedgeDelay = delay
+		o.HedgeMax = maxAttempts
+	}
+}
+
+// WithMaxInflight 限制同时执行的请求数,超过时排队,需要在NewClient时设置才能在请求间共享
+func WithMaxInflight(n int) Option {
+	return WithInflightLimiter(NewInflightLimiter(n, false))
+}
+
+// WithMaxInflightPerHost 按host限制同时执行的请求数,超过时排队
+func WithMaxInflightPerHost(n int) Option {
+	return WithInflightLimiter(NewInflightLimiter(n, true))
+}
+
+// WithInflightLimiter 使用自定义的InflightLimiter,如设置FailFast
+func WithInflightLimiter(l *InflightLimiter) Option {
+	return func(o *Options) {
+		o.Limiter = l
+	}
+}
+
+// WithQuietHours 设置静默时间,通常在NewClient时设置
+func WithQuietHours(q *QuietHours) Option {
+	return func(o *Options) {
+		o.QuietHours = q
+	}
+}
+
+// WithNonUrgent 标记为非紧急的请求,静默时间内会等待或返回ErrQuietHours
+func WithNonUrgent() Option {
+	return func(o *Options) {
+		o.NonUrgent = true
+	}
+}
+
+// WithCache 启用缓存,遵循Cache-Control和Expires,过期后通过ETag和Last-Modified验证,通常在NewClient时设置
+// 可以在EventPost的hook中通过Event.Cache或通过WithRequestInfo获取是否命中,RawResponse时不保存新的应答
+func WithCache(storage CacheStorage) Option {
+	return func(o *Options) {
+		o.Cache = storage
+	}
+}
+
+// WithConditional 记录每个url的ETag和Last-Modified并自动发送条件请求,304时返回上次的结果,用于轮询
+// 比WithCache轻量,每次都会请求服务器,只是避免重复下载未变化的数据,storage为nil时使用不限数量的内存存储
+func WithConditional(storage CacheStorage) Option {
+	if storage == nil {
+		storage = NewMemoryStorage(0)
+	}
+	return func(o *Options) {
+		o.Conditional = storage
+	}
+}
+
+// WithCircuitBreaker 使用熔断器,打开时直接返回ErrCircuitOpen,通常在NewClient时设置
+func WithCircuitBreaker(b *CircuitBreaker) Option {
+	return func(o *Options) {
+		o.Breaker = b
+	}
+}
+
+// WithBreakerKey 指定熔断器的key,如按接口区分
+func WithBreakerKey(key string) Option {
+	return func(o *Options) {
+		o.BreakerKey = key
+	}
+}
+
 func WithRetry(r int) Option {
 	return func(o *Options) {
 		o.Retry = r
 	}
 }
 
+// WithRetryNonIdempotent 非幂等的请求如POST超时时是否重试,默认不重试,避免服务器重复处理
+// 带有Idempotency-Key的请求总是可以重试,WithRetryCondition添加的条件不受影响
+func WithRetryNonIdempotent(retry bool) Option {
+	return func(o *Options) {
+		o.RetryNonIdempotent = retry
+	}
+}
+
+// WithRetryCondition 添加重试条件,需配合WithRetry使用
+func WithRetryCondition(conds ...RetryCondition) Option {
+	return func(o *Options) {
+		o.RetryConditions = append(o.RetryConditions, conds...)
+	}
+}
+
+// WithRawResponse 返回原始的body,不解压,不读取也不解码,忽略result,MaxResponseBytes和Progress
+// 用于调用者直接io.Copy,调用者需要关闭body,非2xx时依然返回StatusErr,RetryConditions中body为nil
+func WithRawResponse() Option {
+	return func(o *Options) {
+		o.RawResponse = true
+	}
+}
+
+// WithResultFor 状态码在r范围内时解码到result,可以多次调用,按添加顺序匹配
+// 非2xx时依然返回StatusErr,如Status4xx可以获取校验错误的详情,解码失败时忽略
+func WithResultFor(r StatusRange, result interface{}) Option {
+	return func(o *Options) {
+		o.StatusResults = append(o.StatusResults, StatusResult{Range: r, Result: result})
+	}
+}
+
+// WithMaxElapsedTime 从开始执行起超过d后不再重试,与WithOverallTimeout不同,不会中断正在执行的请求
+func WithMaxElapsedTime(d time.Duration) Option {
+	return func(o *Options) {
+		o.MaxElapsedTime = d
+	}
+}
+
+// WithRetryBudget 所有请求共享重试的令牌,令牌耗尽时返回ErrRetryBudget,并通过EventBus通知EventRetryBudget
+func WithRetryBudget(b *RetryBudget) Option {
+	return func(o *Options) {
+		o.RetryBudget = b
+	}
+}
+
 func WithBackoff(b Backoff) Option {
 	return func(o *Options) {
 		o.Backoff = b
 	}
 }
 
+// WithHintExtractor 重试时优先使用应答头中的等待时间,如RetryAfterHint
+func WithHintExtractor(hints ...HintExtractor) Option {
+	return func(o *Options) {
+		o.HintExtractors = append(o.HintExtractors, hints...)
+	}
+}
+
 func WithContentType(ct string) Option {
 	return func(o *Options) {
 		o.ContentType = ct
 	}
 }
 
+// WithAccept 设置期望的应答格式,如application/json, application/xml;q=0.9
+func WithAccept(accept string) Option {
+	return func(o *Options) {
+		o.Accept = accept
+	}
+}
+
+// WithStrictAccept 应答的Content-Type不符合Accept时返回MediaTypeError,而不是按应答的格式解码
+func WithStrictAccept() Option {
+	return func(o *Options) {
+		o.StrictAccept = true
+	}
+}
+
+// WithTimeFormat 设置query和form中time.Time的格式,需在WithQuery之前调用
+func WithTimeFormat(layout string) Option {
+	return func(o *Options) {
+		o.TimeFormat = layout
+	}
+}
+
+// WithJSONMarshaler 替换json编码实现
+func WithJSONMarshaler(fn MarshalFunc) Option {
+	return func(o *Options) {
+		o.JSONMarshal = fn
+	}
+}
+
+// WithJSONUnmarshaler 替换json解码实现
+func WithJSONUnmarshaler(fn UnmarshalFunc) Option {
+	return func(o *Options) {
+		o.JSONUnmarshal = fn
+	}
+}
+
+// WithJSONConfig 设置标准库json解码参数,如严格校验未知字段
+func WithJSONConfig(cfg JSONConfig) Option {
+	return func(o *Options) {
+		o.JSONConfig = &cfg
+	}
+}
+
+// WithSchemaWarning json应答存在未知字段或缺少字段时调用fn,不会导致请求失败
+func WithSchemaWarning(fn SchemaWarningFunc) Option {
+	return func(o *Options) {
+		o.SchemaWarning = fn
+	}
+}
+
 func WithCharset(t string) Option {
 	return func(o *Options) {
 		o.Charset = t
@@ -298,6 +965,77 @@ func WithQueries(queries map[string]string) Option {
 	}
 }
 
+// WithPathParam 替换url中的{key},如/users/{id},此时路由名默认为/users/{id}
+func WithPathParam(key string, value interface{}) Option {
+	return func(o *Options) {
+		if o.PathParams == nil {
+			o.PathParams = make(map[string]string)
+		}
+		o.PathParams[key] = formatValue(value, o.TimeFormat)
+	}
+}
+
+// WithPathParams 替换url中的多个{key}
+func WithPathParams(params map[string]string) Option {
+	return func(o *Options) {
+		if o.PathParams == nil {
+			o.PathParams = make(map[string]string)
+		}
+		for k, v := range params {
+			o.PathParams[k] = v
+		}
+	}
+}
+
+// WithRouteName 设置逻辑路由名,如GetUser,避免hook中按原始url统计导致标签过多
+func WithRouteName(name string) Option {
+	return func(o *Options) {
+		o.RouteName = name
+	}
+}
+
+// WithQueryParams 将struct或map编码为查询参数,支持嵌套结构,slice编码为重复的key
+func WithQueryParams(params interface{}) Option {
+	return func(o *Options) {
+		o.QueryParams = append(o.QueryParams, params)
+	}
+}
+
+// WithNestedStyle 设置嵌套结构的key格式,a.b或a[b]
+func WithNestedStyle(style NestedStyle) Option {
+	return func(o *Options) {
+		o.NestedStyle = style
+	}
+}
+
+// WithArrayStyle 设置slice的编码格式,a=1&a=2,a=1,2,a[]=1&a[]=2或a[0]=1&a[1]=2
+func WithArrayStyle(style ArrayStyle) Option {
+	return func(o *Options) {
+		o.ArrayStyle = style
+	}
+}
+
+// WithOmitZero query和form编码时是否忽略零值
+func WithOmitZero(omit bool) Option {
+	return func(o *Options) {
+		o.OmitZero = omit
+	}
+}
+
+// WithBoolFormat query和form编码时bool的格式,true/1/on
+func WithBoolFormat(f BoolFormat) Option {
+	return func(o *Options) {
+		o.BoolFormat = f
+	}
+}
+
+// WithNilAsEmpty query和form编码时nil指针是否编码为空值
+func WithNilAsEmpty(empty bool) Option {
+	return func(o *Options) {
+		o.NilAsEmpty = empty
+	}
+}
+
 func WithCookie(cookie *http.Cookie) Option {
 	return func(o *Options) {
 		o.AddCookie(cookie)
@@ -328,6 +1066,14 @@ func WithBearAuth(auth string) Option {
 	}
 }
 
+// WithAuthProvider 每次执行前调用fn获取Authorization,用于会过期的token,如短期JWT和vault颁发的凭证
+// 返回值不含空格时认为是token,自动添加Bearer前缀,否则认为已经包含scheme,如Basic xxx
+func WithAuthProvider(fn AuthProvider) Option {
+	return func(o *Options) {
+		o.AuthProvider = fn
+	}
+}
+
 func WithXJwtToken(token string) Option {
 	return func(o *Options) {
 		o.AddXJwtToken(token)
@@ -339,3 +1085,300 @@ func WithXAuthToken(token string) Option {
 		o.AddXAuthToken(token)
 	}
 }
+
+func WithData(k, v string) Option {
+	return func(o *Options) {
+		o.AddData(k, v)
+	}
+}
+
+func WithDatas(datas map[string]string) Option {
+	return func(o *Options) {
+		o.AddDatas(datas)
+	}
+}
+
+// WithDeadLetter 重试次数耗尽后仍然失败时调用fn,可用于记录和人工重发
+func WithDeadLetter(fn DeadLetterFunc) Option {
+	return func(o *Options) {
+		o.DeadLetter = fn
+	}
+}
+
+func WithHook(hook Hook) Option {
+	return func(o *Options) {
+		o.AddHook(hook)
+	}
+}
+
+func WithHooks(hooks []Hook) Option {
+	return func(o *Options) {
+		o.AddHooks(hooks)
+	}
+}
+
+// WithEventBus 将此请求的事件发布到EventBus
+func WithEventBus(b *EventBus) Option {
+	return func(o *Options) {
+		o.EventBuses = append(o.EventBuses, b)
+	}
+}
+
+// WithSubscribe 仅订阅此请求的事件
+func WithSubscribe(typ EventType, handler EventHandler) Option {
+	return func(o *Options) {
+		b := NewEventBus()
+		b.Subscribe(typ, handler)
+		o.EventBuses = append(o.EventBuses, b)
+	}
+}
+
+// WithCSRF 自动提取和附加CSRF token
+func WithCSRF(c *CSRF) Option {
+	return func(o *Options) {
+		o.AddHook(c.Hook)
+		o.CSRF = c
+	}
+}
+
+// WithMmapBody 使用mmap映射文件作为body,用于64位平台上传大文件,减少拷贝
+// 不支持mmap时回退为读取整个文件,Content-Type默认为application/octet-stream
+func WithMmapBody(path string) Option {
+	return func(o *Options) {
+		o.MmapBody = path
+	}
+}
+
+// WithBodyFile 以流的方式发送文件作为body,Content-Length为文件大小,Content-Type默认按扩展名推断
+// 每次执行重新打开文件,不支持RequestEncoding压缩
+func WithBodyFile(path string) Option {
+	return func(o *Options) {
+		o.BodyFile = path
+	}
+}
+
+// WithPageExtractor 设置FetchAll如何从每一页中提取元素和下一页的url
+func WithPageExtractor(fn PageExtractor) Option {
+	return func(o *Options) {
+		o.PageExtractor = fn
+	}
+}
+
+// WithPageLimit 设置FetchAll最多请求的页数和获取的元素数,避免服务器异常时无限翻页,0使用默认值
+func WithPageLimit(maxPages, maxItems int) Option {
+	return func(o *Options) {
+		o.MaxPages = maxPages
+		o.MaxItems = maxItems
+	}
+}
+
+// WithStaleRetry 幂等请求在复用的keep-alive连接上出现EOF,broken pipe等错误时,是否在新连接上自动重试一次,默认开启
+// 此重试不计入Retry次数
+func WithStaleRetry(enable bool) Option {
+	return func(o *Options) {
+		o.DisableStaleRetry = !enable
+	}
+}
+
+// WithUserAgent 设置User-Agent,可以在NewClient时设置默认值,并在单个请求中覆盖
+func WithUserAgent(ua string) Option {
+	return func(o *Options) {
+		o.UserAgent = ua
+	}
+}
+
+// WithHostHeader 覆盖请求的Host头,用于通过ip访问指定的服务器,如灰度测试或split-horizon DNS
+// 在NewClient时设置且没有设置WithServerName时,同时作为TLS的SNI
+func WithHostHeader(host string) Option {
+	return func(o *Options) {
+		o.HostHeader = host
+	}
+}
+
+// WithServerName 设置TLS的SNI和证书校验使用的主机名,仅NewClient且没有设置Transport和HTTPClient时有效
+func WithServerName(sni string) Option {
+	return func(o *Options) {
+		o.ServerName = sni
+	}
+}
+
+// WithRequestInfo 执行结束后info中保存最后一次发送的请求和每次执行的耗时,出错时也有效
+func WithRequestInfo(info *RequestInfo) Option {
+	return func(o *Options) {
+		o.RequestInfo = info
+	}
+}
+
+// WithRedact 设置Dump时隐藏值的header,query参数,表单和json字段,不区分大小写,替换DefaultRedactKeys,为空时不隐藏
+func WithRedact(keys ...string) Option {
+	keys = append([]string{}, keys...)
+	return func(o *Options) {
+		o.Redact = keys
+	}
+}
+
+// WithIdempotencyKey 设置Idempotency-Key,同一个请求的重试使用相同的key,服务器据此避免重复处理
+func WithIdempotencyKey(key string) Option {
+	return func(o *Options) {
+		o.IdempotencyKey = key
+	}
+}
+
+// WithAutoIdempotencyKey 为POST,PATCH等非幂等的请求自动生成UUID作为Idempotency-Key,可以在NewClient时设置
+func WithAutoIdempotencyKey() Option {
+	return func(o *Options) {
+		o.AutoIdempotency = true
+	}
+}
+
+// WithRequestID 在header中传递请求ID,用于跨服务关联日志,header为空时使用X-Request-ID
+// ID依次取自请求中已有的header,ContextWithRequestID保存的值,都没有时生成UUID
+// 可以通过Event.ReqID,StatusErr和NetError获取
+func WithRequestID(header string) Option {
+	if header == "" {
+		header = defaultRequestIDHeader
+	}
+	return func(o *Options) {
+		o.RequestIDHeader = header
+	}
+}
+
+// WithAPIVersion 在请求中携带接口版本,服务器返回的版本不一致时返回VersionMismatchError
+// 默认使用X-API-Version,可以通过WithVersionPolicy修改
+func WithAPIVersion(v string) Option {
+	return func(o *Options) {
+		o.APIVersion = v
+	}
+}
+
+// WithVersionPolicy 设置版本使用的header,Accept参数以及是否严格校验,通常在NewClient时设置
+func WithVersionPolicy(p *VersionPolicy) Option {
+	return func(o *Options) {
+		o.VersionPolicy = p
+	}
+}
+
+// WithHMACSigner 发送前使用HMAC-SHA256签名,在之前注册的Hook之后执行
+func WithHMACSigner(s *HMACSigner) Option {
+	return func(o *Options) {
+		o.AddHook(s.Hook)
+	}
+}
+
+// WithCurlLogger 发送请求前将等价的curl命令写入w
+func WithCurlLogger(w io.Writer) Option {
+	return func(o *Options) {
+		o.AddHook(CurlHook(w))
+	}
+}
+
+// WithCompression 设置Accept-Encoding,应答会按Content-Encoding自动解压,支持gzip,deflate,br,zstd
+func WithCompression(encodings ...string) Option {
+	return func(o *Options) {
+		o.Compression = append(o.Compression, encodings...)
+	}
+}
+
+// WithCompressionInfo 记录应答是否压缩以及压缩前后的大小,body读取完毕后数据完整
+func WithCompressionInfo(info *CompressionInfo) Option {
+	return func(o *Options) {
+		o.CompressionInfo = info
+	}
+}
+
+// WithRequestCompression 压缩请求body,并设置Content-Encoding
+func WithRequestCompression(encoding string) Option {
+	return func(o *Options) {
+		o.RequestEncoding = encoding
+	}
+}
+
+// WithProgress 上传和下载进度回调,先报告请求body,再报告应答body
+func WithProgress(fn ProgressFunc) Option {
+	return func(o *Options) {
+		o.Progress = fn
+	}
+}
+
+// WithMaxExtractSize 设置DownloadAndExtract解压后最大字节数
+func WithMaxExtractSize(n int64) Option {
+	return func(o *Options) {
+		o.MaxExtractSize = n
+	}
+}
+
+// WithMaxResponseBytes 限制应答body最大字节数,超出时返回ErrResponseTooLarge并关闭连接
+func WithMaxResponseBytes(n int64) Option {
+	return func(o *Options) {
+		o.MaxResponseBytes = n
+	}
+}
+
+// WithPingInterval 设置WebSocket发送ping的间隔,小于0时不发送
+func WithPingInterval(d time.Duration) Option {
+	return func(o *Options) {
+		o.PingInterval = d
+	}
+}
+
+// WithMaxMessageSize 限制WebSocket单条消息的最大字节数,超出时返回ws.ErrMessageTooLarge
+func WithMaxMessageSize(n int64) Option {
+	return func(o *Options) {
+		o.MaxMessageSize = n
+	}
+}
+
+func WithDate(t time.Time) Option {
+	return func(o *Options) {
+		o.AddDate(t)
+	}
+}
+
+func WithIfModifiedSince(t time.Time) Option {
+	return func(o *Options) {
+		o.AddIfModifiedSince(t)
+	}
+}
+
+func WithIfUnmodifiedSince(t time.Time) Option {
+	return func(o *Options) {
+		o.AddIfUnmodifiedSince(t)
+	}
+}
+
+func WithIfMatch(etags ...string) Option {
+	return func(o *Options) {
+		o.AddIfMatch(etags...)
+	}
+}
+
+func WithIfNoneMatch(etags ...string) Option {
+	return func(o *Options) {
+		o.AddIfNoneMatch(etags...)
+	}
+}
+
+func WithDepth(depth string) Option {
+	return func(o *Options) {
+		o.AddDepth(depth)
+	}
+}
+
+func WithDestination(dst string) Option {
+	return func(o *Options) {
+		o.AddDestination(dst)
+	}
+}
+
+func WithOverwrite(overwrite bool) Option {
+	return func(o *Options) {
+		o.AddOverwrite(overwrite)
+	}
+}
+
+// WithHARRecorder 将请求和应答记录到HARRecorder
+func WithHARRecorder(r *HARRecorder) Option {
+	return func(o *Options) {
+		o.AddHook(r.Hook)
+	}
+}
@@ -0,0 +1,75 @@
+package ghttp
+
+import (
+	"context"
+	"net/http"
+	"sync"
+)
+
+// Spec 批量请求中的一个请求
+type Spec struct {
+	Method  string      // 为空时使用GET
+	URL     string      //
+	Body    interface{} // 请求body
+	Result  interface{} // 解码结果
+	Options []Option    //
+}
+
+// Result 批量请求中一个请求的结果,顺序与Spec一致
+type Result struct {
+	Response *Response
+	Err      error
+	redact   []string
+}
+
+// Parallel 最多limit个并发执行请求,limit<=0时全部并发,单个请求失败不影响其他请求
+// ctx取消时不再发起新的请求,未执行的请求返回ctx的错误,同时Parallel也返回此错误
+func (c *Client) Parallel(ctx context.Context, specs []Spec, limit int) ([]Result, error) {
+	if limit <= 0 || limit > len(specs) {
+		limit = len(specs)
+	}
+
+	results := make([]Result, len(specs))
+	indexes := make(chan int)
+	wg := sync.WaitGroup{}
+	for n := 0; n < limit; n++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for i := range indexes {
+				s := &specs[i]
+				method := s.Method
+				if method == "" {
+					method = http.MethodGet
+				}
+				var redact []string
+				opts := append(s.Options[:len(s.Options):len(s.Options)], WithContext(ctx), func(o *Options) { redact = o.Redact })
+				rsp, err := c.DoRequest(method, s.URL, s.Body, s.Result, opts...)
+				results[i] = Result{Response: rsp, Err: err, redact: redact}
+			}
+		}()
+	}
+
+	var err error
+	i := 0
+loop:
+	for ; i < len(specs); i++ {
+		select {
+		case indexes <- i:
+		case <-ctx.Done():
+			err = ctx.Err()
+			break loop
+		}
+	}
+	close(indexes)
+	for ; i < len(specs); i++ {
+		results[i].Err = err
+	}
+	wg.Wait()
+
+	if err == nil {
+		err = ctx.Err()
+	}
+
+	return results, err
+}
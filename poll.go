@@ -0,0 +1,62 @@
+package ghttp
+
+import (
+	"errors"
+	"net/http"
+	"time"
+)
+
+// ErrStopPoll PollFunc返回此错误时结束,Poll返回nil
+var ErrStopPoll = errors.New("stop poll")
+
+// PollFunc 每次成功的应答调用一次,返回后关闭body
+type PollFunc func(rsp *Response) error
+
+// Poll 长轮询,反复发送GET请求并调用fn,直到fn返回错误或WithContext的context结束
+// 失败时按Backoff等待后重新请求,成功后重置Backoff,4xx错误(408和429除外)无法通过重试恢复,直接返回
+func (c *Client) Poll(url string, fn PollFunc, opts ...Option) error {
+	o := c.buildOptions(opts...)
+	ctx := o.Context
+	if o.Backoff != nil {
+		o.Backoff.Reset()
+	}
+
+	for {
+		rsp, err := c.DoRequest(http.MethodGet, url, nil, nil, opts...)
+		if ctx.Err() != nil {
+			if err == nil {
+				rsp.Body.Close()
+			}
+			return ctx.Err()
+		}
+
+		if err == nil {
+			err = fn(rsp)
+			rsp.Body.Close()
+			if err == ErrStopPoll {
+				return nil
+			}
+			if err != nil {
+				return err
+			}
+			if o.Backoff != nil {
+				o.Backoff.Reset()
+			}
+			continue
+		}
+
+		if se, ok := AsStatusErr(err); ok && se.Code < 500 && !IsRetryable(err) {
+			return err
+		}
+
+		if o.Backoff != nil {
+			timer := time.NewTimer(o.Backoff.Next())
+			select {
+			case <-timer.C:
+			case <-ctx.Done():
+				timer.Stop()
+				return ctx.Err()
+			}
+		}
+	}
+}
@@ -0,0 +1,27 @@
+package ghttp
+
+import "io"
+
+// ProgressFunc 传输进度回调,total未知时为-1
+type ProgressFunc func(transferred, total int64)
+
+type progressReader struct {
+	io.ReadCloser
+	fn          ProgressFunc
+	total       int64
+	transferred int64
+}
+
+func newProgressReader(rc io.ReadCloser, total int64, fn ProgressFunc) io.ReadCloser {
+	return &progressReader{ReadCloser: rc, fn: fn, total: total}
+}
+
+func (r *progressReader) Read(p []byte) (int, error) {
+	n, err := r.ReadCloser.Read(p)
+	if n > 0 {
+		r.transferred += int64(n)
+		r.fn(r.transferred, r.total)
+	}
+
+	return n, err
+}
@@ -0,0 +1,73 @@
+package ghttp
+
+import (
+	"strings"
+
+	"google.golang.org/protobuf/proto"
+	"google.golang.org/protobuf/reflect/protoreflect"
+	"google.golang.org/protobuf/reflect/protoregistry"
+	"google.golang.org/protobuf/types/dynamicpb"
+	"google.golang.org/protobuf/types/known/anypb"
+)
+
+// DynamicProto 没有编译类型时解码protobuf,如网关和通用工具,根据Name从Files中查找消息定义
+type DynamicProto struct {
+	Name    protoreflect.FullName // 消息全名,为空时使用应答Content-Type中的messageType参数
+	Files   *protoregistry.Files  // 消息定义,nil时使用protoregistry.GlobalFiles
+	Message *dynamicpb.Message    // 解码结果,编码时使用
+}
+
+func (d *DynamicProto) unmarshal(data []byte) error {
+	files := d.files()
+	md, err := findMessage(files, d.Name)
+	if err != nil {
+		return err
+	}
+
+	d.Message = dynamicpb.NewMessage(md)
+	opts := proto.UnmarshalOptions{Resolver: dynamicpb.NewTypes(files)}
+	return opts.Unmarshal(data, d.Message)
+}
+
+func (d *DynamicProto) files() *protoregistry.Files {
+	if d.Files != nil {
+		return d.Files
+	}
+
+	return protoregistry.GlobalFiles
+}
+
+// UnpackAny 将Any解码为dynamicpb消息,files为nil时使用protoregistry.GlobalFiles
+func UnpackAny(a *anypb.Any, files *protoregistry.Files) (*dynamicpb.Message, error) {
+	d := &DynamicProto{Name: a.MessageName(), Files: files}
+	if err := d.unmarshal(a.Value); err != nil {
+		return nil, err
+	}
+
+	return d.Message, nil
+}
+
+func findMessage(files *protoregistry.Files, name protoreflect.FullName) (protoreflect.MessageDescriptor, error) {
+	desc, err := files.FindDescriptorByName(name)
+	if err != nil {
+		return nil, err
+	}
+
+	md, ok := desc.(protoreflect.MessageDescriptor)
+	if !ok {
+		return nil, ErrInvalidType
+	}
+
+	return md, nil
+}
+
+// protoMessageName 解析Content-Type中的消息名,如application/x-protobuf; messageType=foo.Bar
+func protoMessageName(content string) protoreflect.FullName {
+	for _, key := range []string{"messagetype", "proto"} {
+		if name := parseContentParam(content, key); name != "" {
+			return protoreflect.FullName(strings.TrimPrefix(name, "."))
+		}
+	}
+
+	return ""
+}
@@ -0,0 +1,101 @@
+package ghttp
+
+import (
+	"context"
+	"time"
+)
+
+// QuietWindow 每天的静默时间段,Start和End为距离0点的时间,Start大于End时表示跨越0点
+type QuietWindow struct {
+	Start    time.Duration  //
+	End      time.Duration  //
+	Weekdays []time.Weekday // 生效的日期,按Start所在的日期计算,为空时每天生效
+}
+
+// QuietHours 静默时间,如合作方接口的维护时间,只对WithNonUrgent标记的请求生效
+// Defer为true时等待静默时间结束再发送,否则返回ErrQuietHours
+// ghttp没有后台队列,等待由发起请求的goroutine完成,不会暂存请求后自动发送
+type QuietHours struct {
+	Windows  []QuietWindow  //
+	Location *time.Location // 时区,nil时使用time.Local
+	Defer    bool           // 等待结束还是直接返回错误
+	now      func() time.Time
+}
+
+// NewQuietHours 创建QuietHours
+func NewQuietHours(deferred bool, windows ...QuietWindow) *QuietHours {
+	return &QuietHours{Windows: windows, Defer: deferred}
+}
+
+// Remaining 返回t所在静默时间的剩余时间,不在静默时间内时返回false
+func (q *QuietHours) Remaining(t time.Time) (time.Duration, bool) {
+	loc := q.Location
+	if loc == nil {
+		loc = time.Local
+	}
+
+	t = t.In(loc)
+	midnight := time.Date(t.Year(), t.Month(), t.Day(), 0, 0, 0, 0, loc)
+	offset := t.Sub(midnight)
+	const day = time.Hour * 24
+
+	for _, w := range q.Windows {
+		switch {
+		case w.Start <= w.End:
+			if offset >= w.Start && offset < w.End && w.match(t.Weekday()) {
+				return w.End - offset, true
+			}
+		case offset >= w.Start:
+			// 跨越0点,当天的前半段
+			if w.match(t.Weekday()) {
+				return day - offset + w.End, true
+			}
+		case offset < w.End:
+			// 跨越0点,次日的后半段,按前一天的日期判断
+			if w.match(t.AddDate(0, 0, -1).Weekday()) {
+				return w.End - offset, true
+			}
+		}
+	}
+
+	return 0, false
+}
+
+func (w *QuietWindow) match(day time.Weekday) bool {
+	if len(w.Weekdays) == 0 {
+		return true
+	}
+
+	for _, d := range w.Weekdays {
+		if d == day {
+			return true
+		}
+	}
+
+	return false
+}
+
+// wait 不在静默时间时直接返回,否则等待结束或返回ErrQuietHours
+func (q *QuietHours) wait(ctx context.Context) error {
+	for {
+		now := time.Now()
+		if q.now != nil {
+			now = q.now()
+		}
+
+		d, ok := q.Remaining(now)
+		if !ok {
+			return nil
+		}
+
+		if !q.Defer {
+			return ErrQuietHours
+		}
+
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-time.After(d):
+		}
+	}
+}
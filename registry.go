@@ -0,0 +1,46 @@
+package ghttp
+
+import (
+	"strconv"
+	"sync"
+)
+
+var registry struct {
+	mutex   sync.RWMutex
+	clients map[string]*Client
+}
+
+// Register 以opts创建Client并以name注册,已存在时替换,通常在启动时集中配置多个上游服务
+func Register(name string, opts ...Option) *Client {
+	c := NewClient(opts...)
+	RegisterClient(name, c)
+	return c
+}
+
+// RegisterClient 以name注册已经创建的Client,已存在时替换
+func RegisterClient(name string, c *Client) {
+	registry.mutex.Lock()
+	if registry.clients == nil {
+		registry.clients = make(map[string]*Client)
+	}
+	registry.clients[name] = c
+	registry.mutex.Unlock()
+}
+
+// Lookup 返回name对应的Client
+func Lookup(name string) (*Client, bool) {
+	registry.mutex.RLock()
+	c, ok := registry.clients[name]
+	registry.mutex.RUnlock()
+	return c, ok
+}
+
+// Use 返回name对应的Client,没有注册时panic,与regexp.MustCompile类似,用于启动时已经注册的名字
+// 名字可能不存在时使用Lookup
+func Use(name string) *Client {
+	if c, ok := Lookup(name); ok {
+		return c
+	}
+
+	panic("ghttp: client " + strconv.Quote(name) + " not registered")
+}
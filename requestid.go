@@ -0,0 +1,38 @@
+package ghttp
+
+import (
+	"context"
+)
+
+const defaultRequestIDHeader = "X-Request-ID"
+
+type requestIDKey struct{}
+
+// ContextWithRequestID 在context中保存请求ID,通常在服务端收到请求时调用,之后发出的请求会传递此ID
+func ContextWithRequestID(ctx context.Context, id string) context.Context {
+	return context.WithValue(ctx, requestIDKey{}, id)
+}
+
+// RequestIDFromContext 获取context中的请求ID
+func RequestIDFromContext(ctx context.Context) (string, bool) {
+	id, ok := ctx.Value(requestIDKey{}).(string)
+	return id, ok && id != ""
+}
+
+// setRequestID 依次使用请求中已有的值,context中的值,都没有时生成UUID
+func (o *Options) setRequestID(req *Request) string {
+	if o.RequestIDHeader == "" {
+		return ""
+	}
+
+	id := req.Header.Get(o.RequestIDHeader)
+	if id == "" {
+		id, _ = RequestIDFromContext(req.Context())
+	}
+	if id == "" {
+		id = newUUID()
+	}
+
+	req.Header.Set(o.RequestIDHeader, id)
+	return id
+}
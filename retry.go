@@ -0,0 +1,111 @@
+package ghttp
+
+import (
+	"bytes"
+	"errors"
+	"io"
+	"net"
+	"strings"
+	"syscall"
+	"time"
+)
+
+// RetryCondition 判断是否需要重试,rsp为nil时err非nil,body仅在请求成功时有效
+type RetryCondition func(rsp *Response, body []byte, err error) bool
+
+// RetryOnHeader 应答头key的值等于value时重试,value为空时只要存在即重试
+func RetryOnHeader(key, value string) RetryCondition {
+	return func(rsp *Response, body []byte, err error) bool {
+		if rsp == nil {
+			return false
+		}
+
+		values, ok := rsp.Header[canonicalKey(key)]
+		if !ok {
+			return false
+		}
+
+		if value == "" {
+			return true
+		}
+
+		for _, v := range values {
+			if strings.EqualFold(v, value) {
+				return true
+			}
+		}
+
+		return false
+	}
+}
+
+// RetryOnBodyContains 应答body包含substr时重试
+func RetryOnBodyContains(substr string) RetryCondition {
+	return func(rsp *Response, body []byte, err error) bool {
+		return rsp != nil && bytes.Contains(body, []byte(substr))
+	}
+}
+
+// RetryOnStatus 应答状态码为codes之一时重试
+func RetryOnStatus(codes ...int) RetryCondition {
+	return func(rsp *Response, body []byte, err error) bool {
+		if rsp == nil {
+			return false
+		}
+
+		for _, code := range codes {
+			if rsp.StatusCode == code {
+				return true
+			}
+		}
+
+		return false
+	}
+}
+
+// shouldRetry 幂等的请求超时时总是重试,其他情况由RetryConditions决定
+func (o *Options) shouldRetry(req *Request, rsp *Response, body []byte, err error) bool {
+	if err != nil && isTimeoutErr(err) && o.canRetryTimeout(req) {
+		return true
+	}
+
+	for _, cond := range o.RetryConditions {
+		if cond(rsp, body, err) {
+			return true
+		}
+	}
+
+	return false
+}
+
+// canRetryTimeout 非幂等的请求超时时服务器可能已经处理,只有带Idempotency-Key或者RetryNonIdempotent时才重试
+func (o *Options) canRetryTimeout(req *Request) bool {
+	return o.RetryNonIdempotent || isIdempotent(req.Method) || req.Header.Get(idempotencyHeader) != ""
+}
+
+// isConnErr 判断是否是建立连接失败,此时请求没有发送到服务器
+func isConnErr(err error) bool {
+	var opErr *net.OpError
+	return errors.As(err, &opErr) && opErr.Op == "dial"
+}
+
+// retryWait 计算重试前的等待时间,优先使用应答头中的提示
+func (o *Options) retryWait(rsp *Response) time.Duration {
+	for _, hint := range o.HintExtractors {
+		if d, ok := hint(rsp); ok {
+			return d
+		}
+	}
+
+	if o.Backoff == nil {
+		return 0
+	}
+
+	return o.Backoff.Next()
+}
+
+// isStaleConnErr 服务器已关闭keep-alive连接时的典型错误
+func isStaleConnErr(err error) bool {
+	return errors.Is(err, io.EOF) || errors.Is(err, io.ErrUnexpectedEOF) ||
+		errors.Is(err, syscall.EPIPE) || errors.Is(err, syscall.ECONNRESET)
+}
@@ -0,0 +1,75 @@
+package ghttp
+
+import (
+	"context"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func TestRetryLoop(t *testing.T) {
+	var calls, conns int32
+	srv := httptest.NewUnstartedServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/slow":
+			if atomic.AddInt32(&calls, 1) == 1 {
+				time.Sleep(100 * time.Millisecond)
+			}
+		case "/error":
+			w.WriteHeader(http.StatusInternalServerError)
+		}
+		w.Write([]byte("ok"))
+	}))
+	srv.Config.ConnState = func(conn net.Conn, state http.ConnState) {
+		if state == http.StateNew {
+			atomic.AddInt32(&conns, 1)
+		}
+	}
+	srv.Start()
+	defer srv.Close()
+
+	// 超时后等待Backoff再重试,等待结束后不会卡住
+	c := NewClient()
+	opts := []Option{WithTimeout(30 * time.Millisecond), WithRetry(1), WithBackoff(NewConstantBackoff(10 * time.Millisecond))}
+	done := make(chan error, 1)
+	go func() {
+		_, err := c.Get(srv.URL+"/slow", nil, opts...)
+		done <- err
+	}()
+	select {
+	case err := <-done:
+		if err != nil || atomic.LoadInt32(&calls) != 2 {
+			t.Errorf("unexpected result: %v, %d calls", err, calls)
+		}
+	case <-time.After(2 * time.Second):
+		t.Fatal("retry loop did not finish")
+	}
+
+	// 关闭body时释放每次执行的context
+	rsp, err := c.Get(srv.URL, nil, opts...)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if rsp.Request.Context().Err() != nil {
+		t.Errorf("context released before body closed")
+	}
+	rsp.Body.Close()
+	if rsp.Request.Context().Err() != context.Canceled {
+		t.Errorf("context not released after body closed")
+	}
+
+	// 状态码错误时关闭body,连接可以复用
+	atomic.StoreInt32(&conns, 0)
+	c = NewClient()
+	for i := 0; i < 3; i++ {
+		if _, err := c.Get(srv.URL+"/error", nil); err == nil {
+			t.Error("expect status error")
+		}
+	}
+	if n := atomic.LoadInt32(&conns); n != 1 {
+		t.Errorf("expect connection reuse, got %d connections", n)
+	}
+}
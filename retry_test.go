@@ -0,0 +1,621 @@
+package ghttp
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"net/http/httptrace"
+	"net/url"
+	"strconv"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func TestRetryCondition(t *testing.T) {
+	count := 0
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		count++
+		w.Header().Set("Content-Type", TypeJSON)
+		if count < 3 {
+			w.Header().Set("X-Should-Retry", "true")
+			w.Write([]byte(`{"error":"throttled"}`))
+			return
+		}
+		w.Write([]byte(`{"ok":true}`))
+	}))
+	defer srv.Close()
+
+	c := NewClient(WithBaseURL(srv.URL), WithRetry(3), WithBackoff(NewConstantBackoff(0)))
+
+	var result map[string]interface{}
+	if _, err := c.Get("/", &result, WithRetryCondition(RetryOnHeader("X-Should-Retry", "true"))); err != nil {
+		t.Fatal(err)
+	}
+	if count != 3 || result["ok"] != true {
+		t.Errorf("unexpected count=%+v, result=%+v", count, result)
+	}
+
+	count = 0
+	if _, err := c.Get("/", &result, WithRetry(1), WithRetryCondition(RetryOnBodyContains("throttled"))); err != nil {
+		t.Fatal(err)
+	}
+	if count != 2 || result["error"] != "throttled" {
+		t.Errorf("unexpected count=%+v, result=%+v", count, result)
+	}
+}
+
+func TestDeadLetter(t *testing.T) {
+	count := 0
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		count++
+		w.Header().Set("Content-Type", TypeJSON)
+		if r.Header.Get("X-Redeliver") == "" {
+			w.Header().Set("X-Should-Retry", "true")
+		}
+		w.Write([]byte(`{}`))
+	}))
+	defer srv.Close()
+
+	var letter *DeadLetter
+	c := NewClient(WithRetry(2), WithBackoff(NewConstantBackoff(0)), WithRetryCondition(RetryOnHeader("X-Should-Retry", "")))
+	if _, err := c.Post(srv.URL, map[string]string{"a": "b"}, nil, WithDeadLetter(func(dl *DeadLetter) { letter = dl })); err != nil {
+		t.Fatal(err)
+	}
+	if letter == nil || letter.Attempts != 3 || string(letter.Body) != `{"a":"b"}` || count != 3 {
+		t.Fatalf("unexpected dead letter: %+v, count=%+v", letter, count)
+	}
+
+	letter2 := letter
+	letter = nil
+	if _, err := c.Redeliver(letter2, nil, WithHeader("X-Redeliver", "1"), WithDeadLetter(func(dl *DeadLetter) { letter = dl })); err != nil {
+		t.Fatal(err)
+	}
+	if letter != nil || count != 4 {
+		t.Errorf("redeliver fail, count=%+v", count)
+	}
+}
+
+func TestHedging(t *testing.T) {
+	var calls int32
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		n := atomic.AddInt32(&calls, 1)
+		if n == 1 {
+			// 第一个请求很慢,会被取消
+			select {
+			case <-r.Context().Done():
+				return
+			case <-time.After(time.Second):
+			}
+		}
+		w.Header().Set("Content-Type", TypeText)
+		fmt.Fprintf(w, "%d", n)
+	}))
+	defer srv.Close()
+
+	start := time.Now()
+	var text string
+	if _, err := Get(srv.URL, &text, WithHedging(time.Millisecond*20, 2)); err != nil {
+		t.Fatal(err)
+	}
+	if text != "2" || time.Since(start) > time.Millisecond*500 {
+		t.Errorf("unexpected result: %v, %v", text, time.Since(start))
+	}
+}
+
+func TestCircuitBreaker(t *testing.T) {
+	calls := 0
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		calls++
+		if r.URL.Path == "/fail" {
+			w.WriteHeader(http.StatusInternalServerError)
+			return
+		}
+		w.Write([]byte("ok"))
+	}))
+	defer srv.Close()
+
+	var changes []string
+	b := NewCircuitBreaker(2, time.Millisecond*50)
+	b.OnStateChange = func(key string, from, to BreakerState) {
+		changes = append(changes, from.String()+"->"+to.String())
+	}
+	c := NewClient(WithCircuitBreaker(b), WithRetry(0))
+
+	for i := 0; i < 2; i++ {
+		c.Get(srv.URL+"/fail", nil)
+	}
+	if _, err := c.Get(srv.URL+"/ok", nil); err != ErrCircuitOpen || calls != 2 {
+		t.Fatalf("expect ErrCircuitOpen, got %v, calls=%v", err, calls)
+	}
+
+	// 冷却后试探成功,关闭
+	time.Sleep(time.Millisecond * 60)
+	if _, err := c.Get(srv.URL+"/ok", nil); err != nil {
+		t.Fatal(err)
+	}
+
+	key := srv.Listener.Addr().String()
+	if b.State(key) != BreakerClosed || strings.Join(changes, ",") != "closed->open,open->half-open,half-open->closed" {
+		t.Errorf("unexpected state: %v, %v", b.State(key), changes)
+	}
+}
+
+func TestMaxInflight(t *testing.T) {
+	var inflight, peak int32
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		n := atomic.AddInt32(&inflight, 1)
+		for {
+			p := atomic.LoadInt32(&peak)
+			if n <= p || atomic.CompareAndSwapInt32(&peak, p, n) {
+				break
+			}
+		}
+		time.Sleep(time.Millisecond * 20)
+		atomic.AddInt32(&inflight, -1)
+		w.Write([]byte("ok"))
+	}))
+	defer srv.Close()
+
+	c := NewClient(WithMaxInflight(2))
+	var wg sync.WaitGroup
+	for i := 0; i < 6; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			var data []byte
+			if _, err := c.Get(srv.URL, &data); err != nil {
+				t.Error(err)
+			}
+		}()
+	}
+	wg.Wait()
+	if peak > 2 {
+		t.Errorf("unexpected peak: %v", peak)
+	}
+
+	l := &InflightLimiter{Max: 1, FailFast: true}
+	release, _ := l.acquire(context.Background(), "")
+	if _, err := Get(srv.URL, nil, WithInflightLimiter(l)); err != ErrTooManyRequests {
+		t.Errorf("expect ErrTooManyRequests, got %v", err)
+	}
+	release()
+}
+
+func TestQuietHours(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte("ok"))
+	}))
+	defer srv.Close()
+
+	// 22:00-02:00,仅周五开始
+	q := NewQuietHours(false, QuietWindow{Start: time.Hour * 22, End: time.Hour * 2, Weekdays: []time.Weekday{time.Friday}})
+	q.Location = time.UTC
+	friday := time.Date(2024, 3, 1, 23, 0, 0, 0, time.UTC)
+	if d, ok := q.Remaining(friday); !ok || d != time.Hour*3 {
+		t.Errorf("unexpected remaining: %v, %v", d, ok)
+	}
+	if d, ok := q.Remaining(friday.Add(time.Hour * 2)); !ok || d != time.Hour {
+		t.Errorf("unexpected remaining: %v, %v", d, ok)
+	}
+	if _, ok := q.Remaining(friday.AddDate(0, 0, 1)); ok {
+		t.Error("saturday should not be quiet")
+	}
+
+	q.now = func() time.Time { return friday }
+	c := NewClient(WithQuietHours(q))
+	if _, err := c.Get(srv.URL, nil, WithNonUrgent()); err != ErrQuietHours {
+		t.Errorf("expect ErrQuietHours, got %v", err)
+	}
+	if _, err := c.Get(srv.URL, nil); err != nil {
+		t.Errorf("urgent request should pass: %v", err)
+	}
+}
+
+func TestSingleflight(t *testing.T) {
+	var calls int32
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		atomic.AddInt32(&calls, 1)
+		time.Sleep(time.Millisecond * 50)
+		w.Header().Set("Content-Type", TypeJSON)
+		w.Write([]byte(`{"version":1}`))
+	}))
+	defer srv.Close()
+
+	c := NewClient(WithSingleflight())
+	var wg sync.WaitGroup
+	for i := 0; i < 5; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			var result struct {
+				Version int `json:"version"`
+			}
+			if _, err := c.Get(srv.URL, &result); err != nil || result.Version != 1 {
+				t.Errorf("unexpected result: %+v, %v", result, err)
+			}
+		}()
+	}
+	wg.Wait()
+
+	if calls != 1 {
+		t.Errorf("expect 1 call, got %v", calls)
+	}
+}
+
+func TestStaleRetry(t *testing.T) {
+	var calls int32
+	transport := RoundTripperFunc(func(req *Request) (*Response, error) {
+		n := atomic.AddInt32(&calls, 1)
+		if trace := httptrace.ContextClientTrace(req.Context()); trace != nil && trace.GotConn != nil {
+			trace.GotConn(httptrace.GotConnInfo{Reused: n%2 == 1})
+		}
+		if n%2 == 1 {
+			return nil, io.ErrUnexpectedEOF
+		}
+		return &Response{StatusCode: http.StatusOK, Body: io.NopCloser(strings.NewReader("ok")), Header: http.Header{}}, nil
+	})
+
+	idle := &idleTransport{RoundTripper: transport}
+	c := NewClient(WithTransport(idle))
+	if _, err := c.Put("http://example.com", "data", nil); err != nil || calls != 2 {
+		t.Errorf("expect retry on stale connection, calls=%d, err=%v", calls, err)
+	}
+	// 连接池是共享的,不能因为一个请求失败关闭所有空闲连接
+	if n := atomic.LoadInt32(&idle.closed); n != 0 {
+		t.Errorf("unexpected CloseIdleConnections, %v", n)
+	}
+
+	atomic.StoreInt32(&calls, 0)
+	if _, err := c.Post("http://example.com", "data", nil); err == nil || calls != 1 {
+		t.Errorf("unexpected retry for POST, calls=%d, err=%v", calls, err)
+	}
+
+	atomic.StoreInt32(&calls, 0)
+	if _, err := c.Get("http://example.com", nil, WithStaleRetry(false)); err == nil || calls != 1 {
+		t.Errorf("unexpected retry when disabled, calls=%d, err=%v", calls, err)
+	}
+}
+
+func TestStaleRetryFreshConn(t *testing.T) {
+	var mux sync.Mutex
+	seen := map[string]bool{}
+	stale := false
+	var barrier sync.WaitGroup
+	barrier.Add(2)
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		io.Copy(io.Discard, r.Body)
+		mux.Lock()
+		kill := stale && seen[r.RemoteAddr]
+		seen[r.RemoteAddr] = true
+		first := !stale
+		mux.Unlock()
+
+		if kill {
+			// 模拟服务器已关闭的keep-alive连接
+			conn, _, _ := w.(http.Hijacker).Hijack()
+			conn.Close()
+			return
+		}
+		if first {
+			// 两个请求同时进行,建立两个连接
+			barrier.Done()
+			barrier.Wait()
+		}
+		w.Write([]byte("ok"))
+	}))
+	defer srv.Close()
+
+	c := NewClient()
+	var wg sync.WaitGroup
+	for i := 0; i < 2; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			var text string
+			if _, err := c.Put(srv.URL, "data", &text); err != nil {
+				t.Error(err)
+			}
+		}()
+	}
+	wg.Wait()
+
+	// 池中的两个连接都已失效,重试必须使用新连接
+	mux.Lock()
+	stale = true
+	pooled := len(seen)
+	mux.Unlock()
+	if pooled != 2 {
+		t.Fatalf("expect 2 pooled connections, got %d", pooled)
+	}
+
+	var text string
+	if _, err := c.Put(srv.URL, "data", &text); err != nil || text != "ok" {
+		t.Errorf("expect retry on fresh connection, got %q, %v", text, err)
+	}
+}
+
+type idleTransport struct {
+	http.RoundTripper
+	closed int32
+}
+
+func (t *idleTransport) CloseIdleConnections() {
+	atomic.AddInt32(&t.closed, 1)
+}
+
+func TestRequestInfo(t *testing.T) {
+	var calls int32
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if atomic.AddInt32(&calls, 1) < 3 {
+			w.WriteHeader(http.StatusBadGateway)
+		}
+	}))
+	defer srv.Close()
+
+	var info RequestInfo
+	retry := WithRetryCondition(func(rsp *Response, body []byte, err error) bool {
+		return rsp != nil && rsp.StatusCode == http.StatusBadGateway
+	})
+	hook := WithHook(func(ev *Event) error {
+		if ev.Type == EventPrev {
+			ev.Req.Header.Set("X-Attempt", fmt.Sprint(ev.Num))
+		}
+		return nil
+	})
+	if _, err := NewClient().Get(srv.URL, nil, WithRetry(3), WithBackoff(nil), retry, hook, WithRequestInfo(&info)); err != nil {
+		t.Fatal(err)
+	}
+
+	if len(info.Attempts) != 3 || info.Attempts[0].Status != http.StatusBadGateway || info.Attempts[2].Status != http.StatusOK {
+		t.Errorf("unexpected attempts: %+v", info.Attempts)
+	}
+	if info.Request.Header.Get("X-Attempt") != "2" {
+		t.Errorf("unexpected final request: %v", info.Request.Header)
+	}
+}
+
+func TestErrorTaxonomy(t *testing.T) {
+	slow := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		time.Sleep(100 * time.Millisecond)
+	}))
+	defer slow.Close()
+	tlsSrv := httptest.NewTLSServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+	defer tlsSrv.Close()
+	closed := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+	closed.Close()
+
+	canceled, cancel := context.WithCancel(context.Background())
+	cancel()
+
+	cases := []struct {
+		url       string
+		opts      []Option
+		kind      error
+		retryable bool
+	}{
+		{slow.URL, []Option{WithTimeout(10 * time.Millisecond)}, ErrTimeout, true},
+		{closed.URL, nil, ErrConnectionRefused, true},
+		{tlsSrv.URL, nil, ErrTLS, false},
+		{slow.URL, []Option{WithContext(canceled)}, ErrCanceled, false},
+	}
+	c := NewClient(WithStaleRetry(false))
+	for _, cs := range cases {
+		_, err := c.Get(cs.url, nil, cs.opts...)
+		if !errors.Is(err, cs.kind) || IsRetryable(err) != cs.retryable {
+			t.Errorf("%v: unexpected error %v, retryable=%v", cs.kind, err, IsRetryable(err))
+		}
+		var urlErr *url.Error
+		if !errors.As(err, &urlErr) {
+			t.Errorf("%v: original error lost: %T", cs.kind, err)
+		}
+	}
+
+	// 是否可重试取决于DNS服务器的应答
+	if _, err := c.Get("http://ghttp.invalid", nil); !errors.Is(err, ErrDNS) {
+		t.Errorf("expect dns error, got %v", err)
+	}
+
+	if !IsRetryable(&StatusErr{Code: http.StatusServiceUnavailable}) || IsRetryable(&StatusErr{Code: http.StatusNotFound}) {
+		t.Errorf("unexpected retryable status")
+	}
+	if IsRetryable(ErrCircuitOpen) || IsRetryable(fmt.Errorf("wrap: %w", ErrNoAvailableHost)) {
+		t.Errorf("circuit open and no available host should not be retryable")
+	}
+}
+
+func TestIdempotencyKey(t *testing.T) {
+	var mux sync.Mutex
+	var keys []string
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		mux.Lock()
+		keys = append(keys, r.Header.Get("Idempotency-Key"))
+		n := len(keys)
+		mux.Unlock()
+		if n%2 == 1 {
+			w.WriteHeader(http.StatusServiceUnavailable)
+		}
+	}))
+	defer srv.Close()
+
+	retry := WithRetryCondition(func(rsp *Response, body []byte, err error) bool {
+		return rsp != nil && rsp.StatusCode == http.StatusServiceUnavailable
+	})
+	c := NewClient(WithAutoIdempotencyKey(), WithRetry(1), WithBackoff(nil), retry)
+	for i := 0; i < 2; i++ {
+		if _, err := c.Post(srv.URL, nil, nil); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if _, err := c.Get(srv.URL, nil); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := c.Put(srv.URL, nil, nil, WithIdempotencyKey("fixed")); err != nil {
+		t.Fatal(err)
+	}
+
+	if len(keys) != 8 || len(keys[0]) != 36 || keys[0] != keys[1] || keys[2] != keys[3] || keys[0] == keys[2] {
+		t.Errorf("unexpected post keys: %v", keys)
+	}
+	if keys[4] != "" || keys[6] != "fixed" || keys[7] != "fixed" {
+		t.Errorf("unexpected keys: %v", keys)
+	}
+}
+
+func TestOverallTimeout(t *testing.T) {
+	var calls int32
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		atomic.AddInt32(&calls, 1)
+		select {
+		case <-r.Context().Done():
+		case <-time.After(time.Second):
+		}
+	}))
+	defer srv.Close()
+
+	start := time.Now()
+	_, err := NewClient().Get(srv.URL, nil, WithRetry(100), WithRetryCondition(func(rsp *Response, body []byte, err error) bool { return err != nil }),
+		WithBackoff(NewConstantBackoff(20*time.Millisecond)), WithAttemptTimeout(30*time.Millisecond), WithOverallTimeout(200*time.Millisecond))
+	elapsed := time.Since(start)
+	if !errors.Is(err, ErrTimeout) || elapsed > time.Second {
+		t.Errorf("expect overall timeout, got %v after %v", err, elapsed)
+	}
+	if n := atomic.LoadInt32(&calls); n < 2 || n > 5 {
+		t.Errorf("unexpected attempts: %d", n)
+	}
+}
+
+func TestRetryBudget(t *testing.T) {
+	var calls int32
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		atomic.AddInt32(&calls, 1)
+		w.WriteHeader(http.StatusServiceUnavailable)
+	}))
+	defer srv.Close()
+
+	var events, hooked int32
+	hook := func(ev *Event) error {
+		if ev.Type == EventRetryBudget {
+			atomic.AddInt32(&hooked, 1)
+		}
+		return nil
+	}
+	c := NewClient(WithRetryBudget(NewRetryBudget(0, 3)), WithRetry(2), WithRetryCondition(RetryOnStatus(http.StatusServiceUnavailable)),
+		WithBackoff(nil), WithHook(hook))
+	c.Subscribe(EventRetryBudget, func(ev *Event) {
+		atomic.AddInt32(&events, 1)
+	})
+
+	// 第一个请求重试2次,第二个请求只剩1个令牌
+	if _, err := c.Get(srv.URL, nil); !IsStatusErr(err) || errors.Is(err, ErrRetryBudget) {
+		t.Errorf("unexpected error: %v", err)
+	}
+	_, err := c.Get(srv.URL, nil)
+	if !errors.Is(err, ErrRetryBudget) || !IsStatusErr(err) {
+		t.Errorf("expect budget error, got %v", err)
+	}
+	if calls != 5 || events != 1 || hooked != 0 {
+		t.Errorf("unexpected calls=%d, events=%d, hooked=%d", calls, events, hooked)
+	}
+
+	calls = 0
+	_, err = NewClient().Get(srv.URL, nil, WithRetry(100), WithRetryCondition(RetryOnStatus(http.StatusServiceUnavailable)),
+		WithBackoff(NewConstantBackoff(20*time.Millisecond)), WithMaxElapsedTime(90*time.Millisecond))
+	if !IsStatusErr(err) || calls < 3 || calls > 5 {
+		t.Errorf("unexpected max elapsed result: calls=%d, %v", calls, err)
+	}
+}
+
+func TestRetryNonIdempotent(t *testing.T) {
+	var calls int32
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		atomic.AddInt32(&calls, 1)
+		select {
+		case <-r.Context().Done():
+		case <-time.After(time.Second):
+		}
+	}))
+	defer srv.Close()
+
+	c := NewClient(WithRetry(2), WithBackoff(nil), WithTimeout(20*time.Millisecond))
+	tests := []struct {
+		method string
+		opts   []Option
+		calls  int32
+	}{
+		{http.MethodPost, nil, 1},
+		{http.MethodPost, []Option{WithRetryNonIdempotent(true)}, 3},
+		{http.MethodPost, []Option{WithIdempotencyKey("key")}, 3},
+		{http.MethodPut, nil, 3},
+		{http.MethodGet, nil, 3},
+	}
+	for _, tt := range tests {
+		atomic.StoreInt32(&calls, 0)
+		if _, err := c.DoRequest(tt.method, srv.URL, nil, nil, tt.opts...); !errors.Is(err, ErrTimeout) {
+			t.Errorf("%s: expect timeout, got %v", tt.method, err)
+		}
+		if n := atomic.LoadInt32(&calls); n != tt.calls {
+			t.Errorf("%s: expect %d calls, got %d", tt.method, tt.calls, n)
+		}
+	}
+}
+
+func TestRetryHints(t *testing.T) {
+	now := time.Now()
+	future := now.Add(time.Hour).UTC().Format(http.TimeFormat)
+	past := now.Add(-time.Hour).UTC().Format(http.TimeFormat)
+	reset := strconv.FormatInt(now.Add(time.Hour).Unix(), 10)
+	expired := strconv.FormatInt(now.Add(-time.Hour).Unix(), 10)
+
+	cases := []struct {
+		name   string
+		hint   HintExtractor
+		header string
+		value  string
+		want   time.Duration
+		ok     bool
+	}{
+		{"retry-after seconds", RetryAfterHint, "Retry-After", "120", 120 * time.Second, true},
+		{"retry-after fraction", RetryAfterHint, "Retry-After", " 1.5 ", 1500 * time.Millisecond, true},
+		{"retry-after date", RetryAfterHint, "Retry-After", future, time.Hour, true},
+		{"retry-after past date", RetryAfterHint, "Retry-After", past, 0, true},
+		{"retry-after empty", RetryAfterHint, "Retry-After", "", 0, false},
+		{"retry-after negative", RetryAfterHint, "Retry-After", "-1", 0, false},
+		{"retry-after garbage", RetryAfterHint, "Retry-After", "soon", 0, false},
+		{"retry-after inf", RetryAfterHint, "Retry-After", "Inf", 0, false},
+		{"retry-after nan", RetryAfterHint, "Retry-After", "NaN", 0, false},
+		{"retry-after overflow", RetryAfterHint, "Retry-After", "1e20", 0, false},
+		{"reset seconds", RateLimitResetHint, "X-RateLimit-Reset", "30", 30 * time.Second, true},
+		{"reset epoch", RateLimitResetHint, "X-RateLimit-Reset", reset, time.Hour, true},
+		{"reset expired epoch", RateLimitResetHint, "X-RateLimit-Reset", expired, 0, true},
+		{"reset negative", RateLimitResetHint, "X-RateLimit-Reset", "-5", 0, false},
+		{"reset fraction", RateLimitResetHint, "X-RateLimit-Reset", "1.5", 0, false},
+		{"reset garbage", RateLimitResetHint, "X-RateLimit-Reset", "abc", 0, false},
+		{"header seconds", HeaderHint("X-Wait"), "X-Wait", "2", 2 * time.Second, true},
+		{"header duration", HeaderHint("X-Wait"), "X-Wait", "250ms", 250 * time.Millisecond, true},
+		{"header date", HeaderHint("X-Wait"), "X-Wait", future, time.Hour, true},
+		{"header negative duration", HeaderHint("X-Wait"), "X-Wait", "-1s", 0, false},
+		{"header other name", HeaderHint("X-Wait"), "Retry-After", "2", 0, false},
+	}
+
+	for _, tc := range cases {
+		rsp := &Response{Header: http.Header{}}
+		if tc.value != "" {
+			rsp.Header.Set(tc.header, tc.value)
+		}
+		d, ok := tc.hint(rsp)
+		// 时间相关的结果允许几秒误差,http-date只精确到秒
+		if ok != tc.ok || d < tc.want-2*time.Second || d > tc.want {
+			t.Errorf("%v: got %v, %v, want %v, %v", tc.name, d, ok, tc.want, tc.ok)
+		}
+	}
+
+	if _, ok := RetryAfterHint(nil); ok {
+		t.Error("nil response should have no hint")
+	}
+}
@@ -0,0 +1,33 @@
+package ghttp
+
+import (
+	"net/url"
+	"strings"
+)
+
+// expandPath 替换url中的{name}参数,参数值会被转义
+func expandPath(rawurl string, params map[string]string) string {
+	for k, v := range params {
+		rawurl = strings.ReplaceAll(rawurl, "{"+k+"}", url.PathEscape(v))
+	}
+
+	return rawurl
+}
+
+// routePath 返回url模板中的path部分,如/users/{id},用作路由名
+func routePath(rawurl string) string {
+	if idx := strings.Index(rawurl, "://"); idx != -1 {
+		rawurl = rawurl[idx+3:]
+		if idx := strings.IndexByte(rawurl, '/'); idx != -1 {
+			rawurl = rawurl[idx:]
+		} else {
+			rawurl = "/"
+		}
+	}
+
+	if idx := strings.IndexAny(rawurl, "?#"); idx != -1 {
+		rawurl = rawurl[:idx]
+	}
+
+	return rawurl
+}
@@ -0,0 +1,130 @@
+package ghttp
+
+import (
+	"encoding/json"
+	"reflect"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// SchemaWarning json应答与结构体定义不一致,用于提前发现上游接口变化
+type SchemaWarning struct {
+	URL     string   //
+	Type    string   // 解码的结果类型
+	Unknown []string // json中存在但结构体中没有的字段
+	Missing []string // 结构体中存在但json中没有的字段,忽略omitempty字段
+}
+
+// SchemaWarningFunc 处理SchemaWarning,如记录日志或上报监控
+type SchemaWarningFunc func(w *SchemaWarning)
+
+// checkSchema 对比json数据和结构体定义,不一致时返回SchemaWarning
+func checkSchema(data []byte, result interface{}) *SchemaWarning {
+	var raw interface{}
+	if err := json.Unmarshal(data, &raw); err != nil {
+		return nil
+	}
+
+	t := reflect.TypeOf(result)
+	w := &SchemaWarning{Type: t.String()}
+	compareSchema(w, "", raw, t)
+	if len(w.Unknown) == 0 && len(w.Missing) == 0 {
+		return nil
+	}
+
+	sort.Strings(w.Unknown)
+	sort.Strings(w.Missing)
+	return w
+}
+
+func compareSchema(w *SchemaWarning, path string, raw interface{}, t reflect.Type) {
+	for t.Kind() == reflect.Ptr {
+		t = t.Elem()
+	}
+
+	switch v := raw.(type) {
+	case map[string]interface{}:
+		if t.Kind() != reflect.Struct {
+			return
+		}
+
+		fields := jsonFields(t)
+		seen := make(map[string]bool, len(v))
+		for key, value := range v {
+			f, ok := fields[strings.ToLower(key)]
+			if !ok {
+				w.Unknown = append(w.Unknown, joinPath(path, key))
+				continue
+			}
+			seen[strings.ToLower(key)] = true
+			compareSchema(w, joinPath(path, key), value, f.typ)
+		}
+
+		for key, f := range fields {
+			if !seen[key] && !f.omitEmpty {
+				w.Missing = append(w.Missing, joinPath(path, f.name))
+			}
+		}
+	case []interface{}:
+		if t.Kind() != reflect.Slice && t.Kind() != reflect.Array {
+			return
+		}
+
+		for i, value := range v {
+			compareSchema(w, path+"["+strconv.Itoa(i)+"]", value, t.Elem())
+		}
+	}
+}
+
+type jsonField struct {
+	name      string
+	typ       reflect.Type
+	omitEmpty bool
+}
+
+// jsonFields 按encoding/json的规则获取字段,key为小写名字
+func jsonFields(t reflect.Type) map[string]jsonField {
+	fields := make(map[string]jsonField)
+	for i := 0; i < t.NumField(); i++ {
+		sf := t.Field(i)
+		name, omitEmpty := parseTag(sf.Tag.Get("json"))
+		if name == "-" {
+			continue
+		}
+
+		ft := sf.Type
+		for ft.Kind() == reflect.Ptr {
+			ft = ft.Elem()
+		}
+
+		if sf.Anonymous && name == "" && ft.Kind() == reflect.Struct {
+			for k, f := range jsonFields(ft) {
+				if _, ok := fields[k]; !ok {
+					fields[k] = f
+				}
+			}
+			continue
+		}
+
+		if sf.PkgPath != "" {
+			continue
+		}
+
+		if name == "" {
+			name = sf.Name
+		}
+
+		fields[strings.ToLower(name)] = jsonField{name: name, typ: sf.Type, omitEmpty: omitEmpty}
+	}
+
+	return fields
+}
+
+func joinPath(path, key string) string {
+	if path == "" {
+		return key
+	}
+
+	return path + "." + key
+}
@@ -0,0 +1,152 @@
+package ghttp
+
+import (
+	"bytes"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+const defaultSignatureHeader = "X-Signature"
+
+// HMACSigner 使用HMAC-SHA256对请求签名,签名内容为:
+// method\npath?排序后的query\n小写header:值(按名字排序,每行一个)\nhex(sha256(body))
+// 可以通过Hook注册到请求,也可以通过Middleware包装RoundTripper,后者在所有header修改之后签名
+type HMACSigner struct {
+	Secret    []byte   // 共享密钥
+	Header    string   // 签名写入的header,默认X-Signature
+	Prefix    string   // 签名值的前缀,如sha256=
+	Headers   []string // 参与签名的header
+	Timestamp string   // 非空时在此header中写入unix时间戳并参与签名,用于服务器防重放
+}
+
+// NewHMACSigner 创建HMACSigner,header为空时使用X-Signature
+func NewHMACSigner(secret []byte, header string, headers ...string) *HMACSigner {
+	if header == "" {
+		header = defaultSignatureHeader
+	}
+	return &HMACSigner{Secret: secret, Header: header, Headers: headers}
+}
+
+// Hook 在EventPrev时签名,应在其他修改请求的Hook之后注册
+func (s *HMACSigner) Hook(ev *Event) error {
+	if ev.Type != EventPrev {
+		return nil
+	}
+
+	return s.Sign(ev.Req)
+}
+
+// Middleware 发送前签名,可用于RegisterMiddleware
+func (s *HMACSigner) Middleware(next http.RoundTripper) http.RoundTripper {
+	return RoundTripperFunc(func(req *Request) (*Response, error) {
+		if err := s.Sign(req); err != nil {
+			return nil, err
+		}
+		return next.RoundTrip(req)
+	})
+}
+
+// Sign 计算签名并写入Header
+func (s *HMACSigner) Sign(req *Request) error {
+	if s.Timestamp != "" {
+		req.Header.Set(s.Timestamp, strconv.FormatInt(time.Now().Unix(), 10))
+	}
+
+	canonical, err := s.canonical(req)
+	if err != nil {
+		return err
+	}
+
+	mac := hmac.New(sha256.New, s.Secret)
+	mac.Write(canonical)
+	header := s.Header
+	if header == "" {
+		header = defaultSignatureHeader
+	}
+	req.Header.Set(header, s.Prefix+hex.EncodeToString(mac.Sum(nil)))
+	return nil
+}
+
+func (s *HMACSigner) canonical(req *Request) ([]byte, error) {
+	buf := &bytes.Buffer{}
+	buf.WriteString(req.Method)
+	buf.WriteByte('\n')
+	buf.WriteString(req.URL.EscapedPath())
+	if req.URL.RawQuery != "" {
+		// Encode按key排序
+		buf.WriteByte('?')
+		buf.WriteString(req.URL.Query().Encode())
+	}
+	buf.WriteByte('\n')
+
+	headers := make([]string, 0, len(s.Headers)+1)
+	for _, h := range s.Headers {
+		headers = append(headers, strings.ToLower(h))
+	}
+	if s.Timestamp != "" {
+		headers = append(headers, strings.ToLower(s.Timestamp))
+	}
+	sort.Strings(headers)
+	for _, h := range headers {
+		buf.WriteString(h)
+		buf.WriteByte(':')
+		if strings.EqualFold(h, "host") {
+			buf.WriteString(requestHost(req))
+		} else {
+			buf.WriteString(strings.TrimSpace(strings.Join(req.Header.Values(h), ",")))
+		}
+		buf.WriteByte('\n')
+	}
+
+	body, err := peekBody(req)
+	if err != nil {
+		return nil, err
+	}
+	sum := sha256.Sum256(body)
+	buf.WriteString(hex.EncodeToString(sum[:]))
+	return buf.Bytes(), nil
+}
+
+func requestHost(req *Request) string {
+	if req.Host != "" {
+		return req.Host
+	}
+
+	return req.URL.Host
+}
+
+// peekBody 读取body且不影响之后的发送
+func peekBody(req *Request) ([]byte, error) {
+	if req.GetBody != nil {
+		rc, err := req.GetBody()
+		if err != nil {
+			return nil, err
+		}
+		defer rc.Close()
+		return ioutil.ReadAll(rc)
+	}
+
+	if req.Body == nil || req.Body == http.NoBody {
+		return nil, nil
+	}
+
+	data, err := ioutil.ReadAll(req.Body)
+	req.Body.Close()
+	if err != nil {
+		return nil, err
+	}
+
+	req.Body = ioutil.NopCloser(bytes.NewReader(data))
+	req.GetBody = func() (io.ReadCloser, error) {
+		return ioutil.NopCloser(bytes.NewReader(data)), nil
+	}
+	return data, nil
+}
@@ -0,0 +1,101 @@
+package ghttp
+
+import (
+	"bytes"
+	"io/ioutil"
+	"net/http"
+	"strings"
+	"sync"
+)
+
+// DefaultSingleflightHeaders 合并请求时参与比较的header,避免不同用户共享应答
+var DefaultSingleflightHeaders = []string{"Accept", "Accept-Encoding", "Accept-Language", "Authorization", "Cookie"}
+
+// flightGroup 合并相同的并发请求,只有第一个请求真正发送,应答body被缓存后共享
+type flightGroup struct {
+	mutex sync.Mutex
+	calls map[string]*flightCall
+}
+
+type flightCall struct {
+	wg   sync.WaitGroup
+	rsp  *Response
+	data []byte
+	err  error
+}
+
+func (g *flightGroup) do(key string, fn func() (*Response, []byte, error)) (*Response, error) {
+	g.mutex.Lock()
+	if g.calls == nil {
+		g.calls = make(map[string]*flightCall)
+	}
+	if call, ok := g.calls[key]; ok {
+		g.mutex.Unlock()
+		call.wg.Wait()
+		return call.clone()
+	}
+
+	call := &flightCall{}
+	call.wg.Add(1)
+	g.calls[key] = call
+	g.mutex.Unlock()
+
+	call.rsp, call.data, call.err = fn()
+	call.wg.Done()
+
+	g.mutex.Lock()
+	delete(g.calls, key)
+	g.mutex.Unlock()
+
+	return call.clone()
+}
+
+// clone 每个调用者得到独立的Response和body
+func (c *flightCall) clone() (*Response, error) {
+	if c.err != nil {
+		return nil, c.err
+	}
+
+	rsp := *c.rsp
+	rsp.Header = c.rsp.Header.Clone()
+	rsp.Body = ioutil.NopCloser(bytes.NewReader(c.data))
+	return &rsp, nil
+}
+
+// singleflightKey method+url+相关的header
+func singleflightKey(req *Request, headers []string) string {
+	b := &strings.Builder{}
+	b.WriteString(req.Method)
+	b.WriteString(" ")
+	b.WriteString(req.URL.String())
+	for _, h := range headers {
+		b.WriteString("\n")
+		b.WriteString(h)
+		b.WriteString(":")
+		b.WriteString(strings.Join(req.Header.Values(h), ","))
+	}
+
+	return b.String()
+}
+
+// doShared 合并相同的GET和HEAD请求
+func (c *Client) doShared(req *Request, headers []string) (*Response, error) {
+	if req.Method != http.MethodGet && req.Method != http.MethodHead {
+		return c.do(req)
+	}
+
+	return c.flight.do(singleflightKey(req, headers), func() (*Response, []byte, error) {
+		rsp, err := c.do(req)
+		if err != nil {
+			return nil, nil, err
+		}
+
+		data, err := readAll(rsp.Body, rsp.ContentLength)
+		rsp.Body.Close()
+		if err != nil {
+			return nil, nil, err
+		}
+
+		return rsp, data, nil
+	})
+}
@@ -0,0 +1,25 @@
+package ghttp
+
+import (
+	"syscall"
+)
+
+// socketControl 在连接建立前设置socket选项,next为Dialer原有的Control
+func socketControl(mark, tclass int, next func(network, address string, c syscall.RawConn) error) func(network, address string, c syscall.RawConn) error {
+	return func(network, address string, c syscall.RawConn) error {
+		if next != nil {
+			if err := next(network, address, c); err != nil {
+				return err
+			}
+		}
+
+		var serr error
+		if err := c.Control(func(fd uintptr) {
+			serr = setSocketOptions(network, fd, mark, tclass)
+		}); err != nil {
+			return err
+		}
+
+		return serr
+	}
+}
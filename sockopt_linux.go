@@ -0,0 +1,26 @@
+//go:build linux
+
+package ghttp
+
+import (
+	"strings"
+	"syscall"
+)
+
+// setSocketOptions 设置SO_MARK和IP_TOS/IPV6_TCLASS,为0时不设置,SO_MARK需要CAP_NET_ADMIN权限
+func setSocketOptions(network string, fd uintptr, mark, tclass int) error {
+	if mark != 0 {
+		if err := syscall.SetsockoptInt(int(fd), syscall.SOL_SOCKET, syscall.SO_MARK, mark); err != nil {
+			return err
+		}
+	}
+
+	if tclass != 0 {
+		if strings.HasSuffix(network, "6") {
+			return syscall.SetsockoptInt(int(fd), syscall.IPPROTO_IPV6, syscall.IPV6_TCLASS, tclass)
+		}
+		return syscall.SetsockoptInt(int(fd), syscall.IPPROTO_IP, syscall.IP_TOS, tclass)
+	}
+
+	return nil
+}
@@ -0,0 +1,33 @@
+package ghttp
+
+import (
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"syscall"
+	"testing"
+)
+
+func TestSocketOptions(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+	defer srv.Close()
+
+	var tos int
+	d := &net.Dialer{Control: socketControl(0, 0x20, nil)}
+	conn, err := d.Dial("tcp4", srv.Listener.Addr().String())
+	if err != nil {
+		t.Fatal(err)
+	}
+	raw, _ := conn.(*net.TCPConn).SyscallConn()
+	raw.Control(func(fd uintptr) {
+		tos, err = syscall.GetsockoptInt(int(fd), syscall.IPPROTO_IP, syscall.IP_TOS)
+	})
+	conn.Close()
+	if err != nil || tos != 0x20 {
+		t.Errorf("unexpected tos: %d, %v", tos, err)
+	}
+
+	if _, err := NewClient(WithTrafficClass(0x20)).Get(srv.URL, nil); err != nil {
+		t.Error(err)
+	}
+}
@@ -0,0 +1,12 @@
+//go:build !linux
+
+package ghttp
+
+// setSocketOptions 仅支持linux
+func setSocketOptions(network string, fd uintptr, mark, tclass int) error {
+	if mark != 0 || tclass != 0 {
+		return ErrNotSupport
+	}
+
+	return nil
+}
@@ -0,0 +1,77 @@
+package ghttp
+
+import (
+	"net/http"
+	"net/http/httptrace"
+	"sync"
+	"sync/atomic"
+)
+
+// doStale 幂等请求在复用的连接上失败时,在新建的连接上重试一次,不计入Retry次数
+// 服务器关闭空闲连接的同时客户端发送请求是keep-alive的常见竞争
+// 不关闭空闲连接,连接池由所有请求共享,池中其他失效的连接由Transport之后发现
+// net/http只自动重试GET等可重放的请求,PUT和DELETE等没有Idempotency-Key时由此重试
+func (c *Client) doStale(req *Request) (*Response, error) {
+	if !isIdempotent(req.Method) {
+		return c.do(req)
+	}
+
+	var reused atomic.Bool
+	trace := &httptrace.ClientTrace{
+		GotConn: func(info httptrace.GotConnInfo) {
+			reused.Store(info.Reused)
+		},
+	}
+
+	rsp, err := c.do(req.WithContext(httptrace.WithClientTrace(req.Context(), trace)))
+	if err == nil || !reused.Load() || !isStaleConnErr(err) || req.Context().Err() != nil {
+		return rsp, err
+	}
+
+	retry := req.Clone(req.Context())
+	if req.GetBody != nil {
+		body, gerr := req.GetBody()
+		if gerr != nil {
+			return nil, err
+		}
+		retry.Body = body
+	} else if req.Body != nil && req.Body != http.NoBody {
+		// body已经被消耗,无法重发
+		return nil, err
+	}
+
+	// 连接池中可能还有同时失效的连接,使用不复用连接的Client保证新建连接
+	if fresh := c.fresh.get(c.client); fresh != nil {
+		return c.doClient(fresh, retry)
+	}
+
+	// 自定义的RoundTripper无法控制连接,由其自行选择
+	return c.do(retry)
+}
+
+// freshClient 延迟创建不复用连接的http.Client,与原Client共享拨号和TLS参数
+type freshClient struct {
+	once   sync.Once
+	client *http.Client
+}
+
+func (f *freshClient) get(client *http.Client) *http.Client {
+	f.once.Do(func() {
+		transport := client.Transport
+		if transport == nil {
+			transport = http.DefaultTransport
+		}
+		ht, ok := transport.(*http.Transport)
+		if !ok {
+			return
+		}
+
+		t := ht.Clone()
+		t.DisableKeepAlives = true
+		cp := *client
+		cp.Transport = t
+		f.client = &cp
+	})
+
+	return f.client
+}
@@ -0,0 +1,66 @@
+package ghttp
+
+// StatusRange 状态码范围,包括Min和Max
+type StatusRange struct {
+	Min int
+	Max int
+}
+
+var (
+	Status2xx = StatusRange{Min: 200, Max: 299}
+	Status4xx = StatusRange{Min: 400, Max: 499}
+	Status5xx = StatusRange{Min: 500, Max: 599}
+)
+
+// StatusExact 只包含code的范围
+func StatusExact(code int) StatusRange {
+	return StatusRange{Min: code, Max: code}
+}
+
+// Contains 判断code是否在范围内
+func (r StatusRange) Contains(code int) bool {
+	return code >= r.Min && code <= r.Max
+}
+
+// StatusResult 状态码在Range内时解码到Result
+type StatusResult struct {
+	Range  StatusRange
+	Result interface{}
+}
+
+// resultFor 按添加顺序返回第一个匹配的Result
+func (o *Options) resultFor(code int) interface{} {
+	for _, sr := range o.StatusResults {
+		if sr.Range.Contains(code) {
+			return sr.Result
+		}
+	}
+
+	return nil
+}
+
+// decodeStatusResult 非2xx的应答按WithResultFor解码,如校验错误的详情,解码失败时忽略
+// body读取后会重置,StatusErr中依然可以获取
+func (o *Options) decodeStatusResult(rsp *Response, contentType string, result interface{}) {
+	data, err := readBody(rsp)
+	if err != nil {
+		return
+	}
+
+	rspType := contentType
+	charset := o.Charset
+	if val := rsp.Header.Get("Content-Type"); len(val) != 0 {
+		rspType = parseContentType(val)
+		if cs := parseCharset(val); cs != "" {
+			charset = cs
+		}
+	}
+
+	if _, raw := result.(*[]byte); !raw && isTextType(rspType) {
+		if data, err = decodeCharset(data, charset); err != nil {
+			return
+		}
+	}
+
+	o.decode(rspType, data, result)
+}
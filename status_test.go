@@ -0,0 +1,33 @@
+package ghttp
+
+import (
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"strings"
+	"testing"
+)
+
+func TestStatus2xx(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		code, _ := strconv.Atoi(strings.TrimPrefix(r.URL.Path, "/"))
+		w.WriteHeader(code)
+	}))
+	defer srv.Close()
+
+	c := NewClient()
+	for _, code := range []int{http.StatusOK, http.StatusCreated, http.StatusAccepted, http.StatusNoContent, http.StatusPartialContent} {
+		if _, err := c.Get(srv.URL+"/"+strconv.Itoa(code), nil); err != nil {
+			t.Errorf("%d should succeed, got %v", code, err)
+		}
+	}
+
+	for _, code := range []int{http.StatusNotModified, http.StatusNotFound, http.StatusInternalServerError} {
+		_, err := c.Get(srv.URL+"/"+strconv.Itoa(code), nil)
+		var se *StatusErr
+		if !errors.As(err, &se) || se.Code != code {
+			t.Errorf("%d should return StatusErr, got %v", code, err)
+		}
+	}
+}
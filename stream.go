@@ -0,0 +1,83 @@
+package ghttp
+
+import (
+	"bufio"
+	"encoding/json"
+	"errors"
+	"net/http"
+)
+
+// ErrStopStream StreamFunc返回此错误时提前结束,Stream返回nil
+var ErrStopStream = errors.New("stop stream")
+
+// StreamFunc 每个元素调用一次,需要通过dec.Decode读取且只读取一个元素
+type StreamFunc func(dec *json.Decoder) error
+
+// GetStream 以流的方式逐个解码NDJSON或JSON数组,不会缓存整个body
+func (c *Client) GetStream(url string, fn StreamFunc, opts ...Option) error {
+	return c.Stream(http.MethodGet, url, nil, fn, opts...)
+}
+
+// Stream 发送请求,并以流的方式逐个解码应答中的NDJSON或JSON数组
+func (c *Client) Stream(method string, url string, reqBody interface{}, fn StreamFunc, opts ...Option) error {
+	all := make([]Option, 0, len(opts)+1)
+	all = append(all, WithHeader("Accept", TypeNDJSON+", "+TypeJSON))
+	all = append(all, opts...)
+
+	rsp, err := c.DoRequest(method, url, reqBody, nil, all...)
+	if err != nil {
+		return err
+	}
+	defer rsp.Body.Close()
+
+	br := bufio.NewReader(rsp.Body)
+	dec := json.NewDecoder(br)
+	isArray, err := peekArray(br)
+	if err != nil {
+		return err
+	}
+
+	if isArray {
+		// 跳过[
+		if _, err := dec.Token(); err != nil {
+			return err
+		}
+	}
+
+	for dec.More() {
+		if err := fn(dec); err != nil {
+			if err == ErrStopStream {
+				return nil
+			}
+			return err
+		}
+	}
+
+	if isArray {
+		if _, err := dec.Token(); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// peekArray 判断第一个非空白字符是否是[
+func peekArray(br *bufio.Reader) (bool, error) {
+	for i := 1; ; i++ {
+		data, err := br.Peek(i)
+		if err != nil {
+			// 空body
+			return false, nil
+		}
+
+		switch data[i-1] {
+		case ' ', '\t', '\r', '\n':
+			continue
+		case '[':
+			return true, nil
+		default:
+			return false, nil
+		}
+	}
+}
@@ -0,0 +1,217 @@
+package ghttp
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func TestStream(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/array" {
+			w.Header().Set("Content-Type", TypeJSON)
+			w.Write([]byte(` [{"id":1},{"id":2},{"id":3}]`))
+			return
+		}
+		w.Header().Set("Content-Type", TypeNDJSON)
+		w.Write([]byte("{\"id\":1}\n{\"id\":2}\n{\"id\":3}\n"))
+	}))
+	defer srv.Close()
+
+	for _, path := range []string{"/array", "/ndjson"} {
+		var ids []int
+		err := GetStream(srv.URL+path, func(dec *json.Decoder) error {
+			var item struct {
+				ID int `json:"id"`
+			}
+			if err := dec.Decode(&item); err != nil {
+				return err
+			}
+			ids = append(ids, item.ID)
+			if item.ID == 2 && path == "/ndjson" {
+				return ErrStopStream
+			}
+			return nil
+		})
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		expect := 3
+		if path == "/ndjson" {
+			expect = 2
+		}
+		if len(ids) != expect {
+			t.Errorf("%s: unexpected ids %+v", path, ids)
+		}
+	}
+}
+
+func TestIter(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", TypeJSON)
+		switch r.URL.Query().Get("page") {
+		case "":
+			w.Write([]byte(`[1,2,3]`))
+		case "1":
+			w.Write([]byte(`{"items":[1,2],"next":"2"}`))
+		default:
+			w.Write([]byte(`{"items":[3]}`))
+		}
+	}))
+	defer srv.Close()
+
+	var items []int
+	for item, err := range Items[int](nil, srv.URL) {
+		if err != nil {
+			t.Fatal(err)
+		}
+		items = append(items, item)
+		if item == 2 {
+			break
+		}
+	}
+	if !reflect.DeepEqual(items, []int{1, 2}) {
+		t.Errorf("unexpected items: %v", items)
+	}
+
+	type page struct {
+		Items []int  `json:"items"`
+		Next  string `json:"next"`
+	}
+	next := func(rsp *Response, p page) string {
+		if p.Next == "" {
+			return ""
+		}
+		return srv.URL + "?page=" + p.Next
+	}
+
+	// 同一个迭代器可以多次range,每次从第一页开始
+	pages := Pages[page](nil, srv.URL+"?page=1", next)
+	for i := 0; i < 2; i++ {
+		items = nil
+		for p, err := range pages {
+			if err != nil {
+				t.Fatal(err)
+			}
+			items = append(items, p.Items...)
+		}
+		if !reflect.DeepEqual(items, []int{1, 2, 3}) {
+			t.Errorf("range %d: unexpected items: %v", i, items)
+		}
+	}
+}
+
+func TestFetchAll(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", TypeJSON)
+		switch r.URL.Path {
+		case "/link":
+			if r.URL.Query().Get("page") == "" {
+				w.Header().Set("Link", `</link?page=2>; rel="next", </link>; rel="first"`)
+				w.Write([]byte(`[1,2]`))
+				return
+			}
+			w.Write([]byte(`[3]`))
+		case "/object":
+			switch r.URL.Query().Get("page") {
+			case "":
+				w.Write([]byte(`{"data":[1],"next":"?page=2"}`))
+			case "2":
+				w.Write([]byte(`{"data":[2,3],"next":"?page=3"}`))
+			default:
+				w.Write([]byte(`{"data":[4],"next":null}`))
+			}
+		}
+	}))
+	defer srv.Close()
+
+	var items []int
+	if err := NewClient().FetchAll(srv.URL+"/link", &items); err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(items, []int{1, 2, 3}) {
+		t.Errorf("unexpected items: %v", items)
+	}
+
+	items = nil
+	if err := NewClient().FetchAll(srv.URL+"/object", &items, WithPageExtractor(JSONPageExtractor("data", "next"))); err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(items, []int{1, 2, 3, 4}) {
+		t.Errorf("unexpected items: %v", items)
+	}
+
+	items = nil
+	err := NewClient().FetchAll(srv.URL+"/object", &items, WithPageExtractor(JSONPageExtractor("data", "next")), WithPageLimit(0, 2))
+	if err != ErrPageLimit || !reflect.DeepEqual(items, []int{1, 2}) {
+		t.Errorf("unexpected limit result: %v, %v", items, err)
+	}
+}
+
+func TestParseLinkHeader(t *testing.T) {
+	req, _ := http.NewRequest(http.MethodGet, "https://api.example.com/repos?page=2", nil)
+	rsp := &Response{Request: req, Header: http.Header{}}
+	rsp.Header.Add("Link", `<https://api.example.com/repos?page=3>; rel="next", <?page=1>; rel="prev first"`)
+	rsp.Header.Add("Link", `</repos?page=9>; title="a, b; c"; rel=last, invalid, <https://x.com/next>; rel="next"`)
+
+	expect := map[string]string{
+		"next":  "https://api.example.com/repos?page=3",
+		"prev":  "https://api.example.com/repos?page=1",
+		"first": "https://api.example.com/repos?page=1",
+		"last":  "https://api.example.com/repos?page=9",
+	}
+	if links := ParseLinkHeader(rsp); !reflect.DeepEqual(links, expect) {
+		t.Errorf("unexpected links: %v", links)
+	}
+}
+
+func TestPoll(t *testing.T) {
+	var calls int32
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		n := atomic.AddInt32(&calls, 1)
+		switch {
+		case r.URL.Path == "/missing":
+			w.WriteHeader(http.StatusNotFound)
+		case n == 2:
+			w.WriteHeader(http.StatusServiceUnavailable)
+		default:
+			fmt.Fprintf(w, "%d", n)
+		}
+	}))
+	defer srv.Close()
+
+	var got []string
+	backoff := NewConstantBackoff(time.Millisecond)
+	err := NewClient().Poll(srv.URL, func(rsp *Response) error {
+		data, _ := io.ReadAll(rsp.Body)
+		got = append(got, string(data))
+		if len(got) == 3 {
+			return ErrStopPoll
+		}
+		return nil
+	}, WithBackoff(backoff))
+	if err != nil || !reflect.DeepEqual(got, []string{"1", "3", "4"}) {
+		t.Errorf("unexpected poll result: %v, %v", got, err)
+	}
+
+	if err := NewClient().Poll(srv.URL+"/missing", func(rsp *Response) error { return nil }, WithBackoff(backoff)); !IsStatusErr(err) {
+		t.Errorf("expect status error, got %v", err)
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	err = NewClient().Poll(srv.URL, func(rsp *Response) error {
+		cancel()
+		return nil
+	}, WithContext(ctx))
+	if err != context.Canceled {
+		t.Errorf("expect canceled, got %v", err)
+	}
+}
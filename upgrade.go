@@ -0,0 +1,94 @@
+package ghttp
+
+import (
+	"context"
+	"io"
+	"net/http"
+	"strings"
+)
+
+// Upgrade 发送GET请求切换协议,服务器返回101时返回可读写的连接,用于WebSocket等协议
+// 使用BaseURL,Header,Query,Cookie,UserAgent和AuthProvider等参数以及NewClient时的TLS和拨号参数
+// url可以是ws或wss,Timeout只限制握手,不重试,也不经过hook和中间件
+func (c *Client) Upgrade(url string, protocol string, opts ...Option) (*Response, io.ReadWriteCloser, error) {
+	o := c.buildOptions(opts...)
+
+	isAbs := false
+	for _, scheme := range []string{"http://", "https://", "ws://", "wss://"} {
+		isAbs = isAbs || strings.HasPrefix(url, scheme)
+	}
+	if o.BaseURL != "" && !isAbs {
+		url = joinURL(o.BaseURL, url)
+	}
+	if len(o.PathParams) > 0 {
+		url = expandPath(url, o.PathParams)
+	}
+
+	ctx, cancel := o.Context, context.CancelFunc(func() {})
+	if o.Timeout > 0 {
+		ctx, cancel = context.WithTimeout(ctx, o.Timeout)
+	}
+	defer cancel()
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, url, nil)
+	if err != nil {
+		return nil, nil, err
+	}
+	switch req.URL.Scheme {
+	case "ws":
+		req.URL.Scheme = "http"
+	case "wss":
+		req.URL.Scheme = "https"
+	}
+
+	if len(o.Header) > 0 {
+		req.Header = o.Header.Clone()
+	}
+	req.Header.Set("Connection", "Upgrade")
+	req.Header.Set("Upgrade", protocol)
+	if o.HostHeader != "" {
+		req.Host = o.HostHeader
+	}
+	o.setUserAgent(req)
+	o.setRequestID(req)
+
+	if o.AuthProvider != nil {
+		auth, err := o.AuthProvider(ctx)
+		if err != nil {
+			return nil, nil, err
+		}
+		req.Header.Set("Authorization", authorization(auth))
+	}
+
+	if len(o.Query) > 0 || len(o.QueryParams) > 0 {
+		rawQuery, err := o.toRawQuery(req.URL.Query())
+		if err != nil {
+			return nil, nil, err
+		}
+		req.URL.RawQuery = rawQuery
+	}
+
+	addCookies(req, o.Cookies)
+
+	// http.Client设置了Timeout时,101的body不可写,因此直接使用Transport
+	transport := c.client.Transport
+	if transport == nil {
+		transport = http.DefaultTransport
+	}
+	rsp, err := transport.RoundTrip(req)
+	if err != nil {
+		return nil, nil, classifyErr(err)
+	}
+
+	if rsp.StatusCode != http.StatusSwitchingProtocols {
+		return nil, nil, newStatusErr(rsp, 1)
+	}
+
+	conn, ok := rsp.Body.(io.ReadWriteCloser)
+	if !ok {
+		rsp.Body.Close()
+		return nil, nil, ErrNotSupport
+	}
+
+	return rsp, conn, nil
+}
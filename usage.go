@@ -0,0 +1,205 @@
+package ghttp
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"math/rand"
+	"sort"
+	"sync"
+	"time"
+)
+
+// usageSamples 每个接口最多保留的耗时样本数,超过后使用蓄水池采样
+const usageSamples = 10000
+
+// UsageCollector 统计调用了哪些接口,以及频率,错误率和耗时分布,用于依赖梳理和容量规划
+// 接口优先使用WithRouteName设置的Route,否则使用host+path,path中含有id时建议设置Route
+type UsageCollector struct {
+	mux   sync.Mutex
+	start time.Time
+	stats map[string]*usageStat
+}
+
+type usageStat struct {
+	method   string
+	endpoint string
+	count    int
+	errors   int
+	status   map[int]int
+	total    time.Duration
+	min      time.Duration
+	max      time.Duration
+	samples  []time.Duration
+}
+
+// NewUsageCollector 创建UsageCollector,通过AddHook或RegisterGlobalHook注册Hook
+func NewUsageCollector() *UsageCollector {
+	return &UsageCollector{start: time.Now(), stats: make(map[string]*usageStat)}
+}
+
+// Hook 记录每次执行,重试会计为多次
+func (u *UsageCollector) Hook(ev *Event) error {
+	if ev.Type != EventPost || ev.Req == nil {
+		return nil
+	}
+
+	endpoint := ev.Route
+	if endpoint == "" {
+		endpoint = ev.Req.URL.Host + ev.Req.URL.Path
+	}
+	cost := time.Since(ev.Start)
+
+	u.mux.Lock()
+	defer u.mux.Unlock()
+	key := ev.Req.Method + " " + endpoint
+	s, ok := u.stats[key]
+	if !ok {
+		s = &usageStat{method: ev.Req.Method, endpoint: endpoint, status: make(map[int]int), min: cost}
+		u.stats[key] = s
+	}
+
+	s.count++
+	if ev.Err != nil {
+		s.errors++
+	} else if ev.Rsp != nil {
+		s.status[ev.Rsp.StatusCode]++
+		if ev.Rsp.StatusCode >= 400 {
+			s.errors++
+		}
+	}
+
+	s.total += cost
+	if cost < s.min {
+		s.min = cost
+	}
+	if cost > s.max {
+		s.max = cost
+	}
+	if len(s.samples) < usageSamples {
+		s.samples = append(s.samples, cost)
+	} else if i := rand.Intn(s.count); i < usageSamples {
+		s.samples[i] = cost
+	}
+
+	return nil
+}
+
+// Reset 清空统计并重新计时
+func (u *UsageCollector) Reset() {
+	u.mux.Lock()
+	u.start = time.Now()
+	u.stats = make(map[string]*usageStat)
+	u.mux.Unlock()
+}
+
+// UsageReport 统计报告,可通过WriteJSON或WriteMarkdown输出
+type UsageReport struct {
+	Start     time.Time        `json:"start"`
+	End       time.Time        `json:"end"`
+	Endpoints []*EndpointUsage `json:"endpoints"` // 按调用次数从多到少排序
+}
+
+// EndpointUsage 单个接口的统计,耗时单位为毫秒
+type EndpointUsage struct {
+	Method    string      `json:"method"`
+	Endpoint  string      `json:"endpoint"`
+	Count     int         `json:"count"`      // 执行次数
+	Rate      float64     `json:"rate"`       // 每秒执行次数
+	Errors    int         `json:"errors"`     // 网络错误和4xx,5xx的次数
+	ErrorRate float64     `json:"error_rate"` //
+	Status    map[int]int `json:"status"`     // 每个状态码的次数
+	Min       float64     `json:"min_ms"`     //
+	Avg       float64     `json:"avg_ms"`     //
+	P50       float64     `json:"p50_ms"`     //
+	P90       float64     `json:"p90_ms"`     //
+	P99       float64     `json:"p99_ms"`     //
+	Max       float64     `json:"max_ms"`     //
+}
+
+// Report 生成当前的统计报告
+func (u *UsageCollector) Report() *UsageReport {
+	u.mux.Lock()
+	defer u.mux.Unlock()
+	r := &UsageReport{Start: u.start, End: time.Now()}
+	seconds := r.End.Sub(r.Start).Seconds()
+	for _, s := range u.stats {
+		samples := append([]time.Duration(nil), s.samples...)
+		sort.Slice(samples, func(i, j int) bool { return samples[i] < samples[j] })
+		e := &EndpointUsage{
+			Method:    s.method,
+			Endpoint:  s.endpoint,
+			Count:     s.count,
+			Errors:    s.errors,
+			ErrorRate: float64(s.errors) / float64(s.count),
+			Status:    make(map[int]int, len(s.status)),
+			Min:       toMillis(s.min),
+			Avg:       toMillis(s.total / time.Duration(s.count)),
+			P50:       toMillis(percentile(samples, 0.5)),
+			P90:       toMillis(percentile(samples, 0.9)),
+			P99:       toMillis(percentile(samples, 0.99)),
+			Max:       toMillis(s.max),
+		}
+		if seconds > 0 {
+			e.Rate = float64(s.count) / seconds
+		}
+		for code, n := range s.status {
+			e.Status[code] = n
+		}
+		r.Endpoints = append(r.Endpoints, e)
+	}
+
+	sort.Slice(r.Endpoints, func(i, j int) bool {
+		a, b := r.Endpoints[i], r.Endpoints[j]
+		if a.Count != b.Count {
+			return a.Count > b.Count
+		}
+		return a.Method+" "+a.Endpoint < b.Method+" "+b.Endpoint
+	})
+
+	return r
+}
+
+// percentile samples需要已排序
+func percentile(samples []time.Duration, p float64) time.Duration {
+	if len(samples) == 0 {
+		return 0
+	}
+
+	return samples[int(float64(len(samples)-1)*p)]
+}
+
+// WriteJSON 以json格式写入w
+func (r *UsageReport) WriteJSON(w io.Writer) error {
+	data, err := json.MarshalIndent(r, "", "  ")
+	if err != nil {
+		return err
+	}
+
+	_, err = w.Write(data)
+	return err
+}
+
+// WriteMarkdown 以markdown表格写入w
+func (r *UsageReport) WriteMarkdown(w io.Writer) error {
+	if _, err := fmt.Fprintf(w, "# HTTP Usage\n\n%s - %s (%s)\n\n", r.Start.Format(time.RFC3339), r.End.Format(time.RFC3339), r.End.Sub(r.Start).Round(time.Second)); err != nil {
+		return err
+	}
+
+	if _, err := fmt.Fprintln(w, "| Method | Endpoint | Count | Rate/s | Errors | Error% | Avg ms | P50 ms | P90 ms | P99 ms | Max ms |"); err != nil {
+		return err
+	}
+	if _, err := fmt.Fprintln(w, "|---|---|---:|---:|---:|---:|---:|---:|---:|---:|---:|"); err != nil {
+		return err
+	}
+
+	for _, e := range r.Endpoints {
+		_, err := fmt.Fprintf(w, "| %s | %s | %d | %.2f | %d | %.1f | %.1f | %.1f | %.1f | %.1f | %.1f |\n",
+			e.Method, e.Endpoint, e.Count, e.Rate, e.Errors, e.ErrorRate*100, e.Avg, e.P50, e.P90, e.P99, e.Max)
+		if err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
@@ -1,14 +1,23 @@
 package ghttp
 
 import (
+	"bytes"
+	"context"
+	"encoding"
 	"encoding/base64"
-	"encoding/json"
-	"encoding/xml"
 	"fmt"
+	"io"
+	"io/ioutil"
+	"net"
 	"net/http"
+	"net/textproto"
 	"net/url"
 	"reflect"
+	"strconv"
 	"strings"
+	"time"
+
+	"google.golang.org/protobuf/proto"
 )
 
 // See 2 (end of page 4) https://www.ietf.org/rfc/rfc2617.txt
@@ -28,14 +37,109 @@ func addCookies(req *http.Request, cookies []*http.Cookie) {
 }
 
 // url.Values, http.Header
-func addValue(dict map[string][]string, key string, value interface{}) {
+func addValue(dict map[string][]string, key string, value interface{}, timeFormat string) {
 	switch v := value.(type) {
-	case string:
-		dict[key] = append(dict[key], v)
 	case []string:
 		dict[key] = append(dict[key], v...)
 	default:
-		dict[key] = append(dict[key], fmt.Sprintf("%+v", v))
+		dict[key] = append(dict[key], formatValue(v, timeFormat))
+	}
+}
+
+// formatValue 将值格式化为字符串,支持time.Time,encoding.TextMarshaler和fmt.Stringer
+func formatValue(value interface{}, timeFormat string) string {
+	switch v := value.(type) {
+	case nil:
+		return ""
+	case string:
+		return v
+	case time.Time:
+		return formatTime(v, timeFormat)
+	case *time.Time:
+		if v == nil {
+			return ""
+		}
+		return formatTime(*v, timeFormat)
+	case encoding.TextMarshaler:
+		if data, err := v.MarshalText(); err == nil {
+			return string(data)
+		}
+	case fmt.Stringer:
+		return v.String()
+	}
+
+	rv := reflect.ValueOf(value)
+	switch rv.Kind() {
+	case reflect.Bool:
+		return strconv.FormatBool(rv.Bool())
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		return strconv.FormatInt(rv.Int(), 10)
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64, reflect.Uintptr:
+		return strconv.FormatUint(rv.Uint(), 10)
+	case reflect.Float32:
+		return strconv.FormatFloat(rv.Float(), 'f', -1, 32)
+	case reflect.Float64:
+		return strconv.FormatFloat(rv.Float(), 'f', -1, 64)
+	}
+
+	return fmt.Sprintf("%+v", value)
+}
+
+// addHeaderValue 添加header,key会被规范化
+// time.Time使用RFC 7231格式(如Date,If-Modified-Since),time.Duration使用秒数,slice添加多个值
+func addHeaderValue(header http.Header, key string, value interface{}) {
+	key = canonicalKey(key)
+	switch v := value.(type) {
+	case time.Time:
+		header[key] = append(header[key], v.UTC().Format(http.TimeFormat))
+		return
+	case *time.Time:
+		if v != nil {
+			header[key] = append(header[key], v.UTC().Format(http.TimeFormat))
+		}
+		return
+	case time.Duration:
+		header[key] = append(header[key], strconv.FormatInt(int64(v/time.Second), 10))
+		return
+	case []byte:
+		header[key] = append(header[key], string(v))
+		return
+	}
+
+	rv := reflect.ValueOf(value)
+	if rv.Kind() == reflect.Slice || rv.Kind() == reflect.Array {
+		for i := 0; i < rv.Len(); i++ {
+			addHeaderValue(header, key, rv.Index(i).Interface())
+		}
+		return
+	}
+
+	header[key] = append(header[key], formatValue(value, ""))
+}
+
+// joinETags 合并etag,未加引号时自动添加,*和弱校验W/"..."保持不变
+func joinETags(etags []string) string {
+	result := make([]string, 0, len(etags))
+	for _, tag := range etags {
+		if tag != "*" && !strings.HasPrefix(tag, `"`) && !strings.HasPrefix(tag, `W/"`) {
+			tag = strconv.Quote(tag)
+		}
+		result = append(result, tag)
+	}
+
+	return strings.Join(result, ", ")
+}
+
+func formatTime(t time.Time, timeFormat string) string {
+	switch timeFormat {
+	case "":
+		return t.Format(defaultTimeFormat)
+	case TimeFormatUnix:
+		return strconv.FormatInt(t.Unix(), 10)
+	case TimeFormatUnixMilli:
+		return strconv.FormatInt(t.UnixNano()/int64(time.Millisecond), 10)
+	default:
+		return t.Format(timeFormat)
 	}
 }
 
@@ -45,39 +149,56 @@ func addValueMap(result map[string][]string, kv map[string]string) {
 	}
 }
 
-func encode(contentType string, data interface{}) ([]byte, error) {
+func (o *Options) encode(contentType string, data interface{}) ([]byte, error) {
 	if data == nil {
 		return nil, nil
 	}
 
-	switch d := data.(type) {
-	case string:
-		return []byte(d), nil
-	case []byte:
+	// []byte认为已经是最终编码,不再转码
+	if d, ok := data.([]byte); ok {
 		return d, nil
 	}
 
+	if m, ok := data.(Marshaler); ok {
+		return m.MarshalBody()
+	}
+
+	body, err := o.marshal(contentType, data)
+	if err != nil {
+		return nil, err
+	}
+
+	if isTextType(contentType) {
+		return encodeCharset(body, o.Charset)
+	}
+
+	return body, nil
+}
+
+func (o *Options) marshal(contentType string, data interface{}) ([]byte, error) {
+	if d, ok := data.(string); ok {
+		return []byte(d), nil
+	}
+
 	switch contentType {
-	case TypeJSON:
-		return json.Marshal(data)
-	case TypeXML:
-		return xml.Marshal(data)
-	case TypeForm:
-		uv, err := toUrlValue(data)
-		if err != nil {
-			return nil, err
-		}
-		r := uv.Encode()
-		return []byte(r), nil
 	case TypeHTML, TypeText:
 		// must be string or []byte
 		return nil, ErrInvalidType
-	default:
-		return nil, ErrNotSupport
 	}
+
+	if c, ok := o.getCodec(contentType); ok {
+		return c.Marshal(data)
+	}
+
+	if m, ok := data.(encoding.BinaryMarshaler); ok {
+		return m.MarshalBinary()
+	}
+
+	return nil, ErrNotSupport
 }
 
-func toUrlValue(data interface{}) (url.Values, error) {
+// toUrlValue 编码form,struct按enc的tag编码,slice编码为重复的key,嵌套结构按NestedStyle展开
+func toUrlValue(data interface{}, enc *valueEncoder) (url.Values, error) {
 	switch m := data.(type) {
 	case url.Values:
 		return m, nil
@@ -87,34 +208,36 @@ func toUrlValue(data interface{}) (url.Values, error) {
 			r.Add(k, v)
 		}
 		return r, nil
-	case map[string]interface{}:
+	default:
 		r := url.Values{}
-		for k, v := range m {
-			kind := reflect.TypeOf(v).Kind()
-			if kind <= reflect.Float64 {
-				r.Add(k, fmt.Sprintf("%+v", v))
-			} else if kind == reflect.Slice {
-				vv := reflect.ValueOf(v)
-				for i := 0; i < vv.Len(); i++ {
-					f := vv.Field(i)
-					r.Add(k, fmt.Sprintf("%+v", f.Interface()))
-				}
-			} else {
-				return nil, ErrNotSupport
+		if err := enc.Encode(r, data); err != nil {
+			if err == ErrInvalidType {
+				err = ErrNotSupport
 			}
+			return nil, err
 		}
 		return r, nil
-	default:
-		return nil, ErrNotSupport
 	}
 }
 
-func decode(contentType string, data []byte, result interface{}) error {
+func (o *Options) decode(contentType string, data []byte, result interface{}) error {
 	if result == nil {
 		return nil
 	}
 
+	if u, ok := result.(Unmarshaler); ok {
+		return u.UnmarshalBody(data)
+	}
+
 	if len(data) == 0 {
+		// protobuf中空消息编码后长度为0
+		if m, ok := result.(proto.Message); ok {
+			proto.Reset(m)
+			return nil
+		}
+		if d, ok := result.(*DynamicProto); ok {
+			return d.unmarshal(nil)
+		}
 		return ErrNoData
 	}
 
@@ -134,21 +257,15 @@ func decode(contentType string, data []byte, result interface{}) error {
 		return nil
 	}
 
-	switch contentType {
-	case TypeJSON:
-		return json.Unmarshal(data, result)
-	case TypeXML:
-		return xml.Unmarshal(data, result)
-	case TypeForm:
-		values, err := url.ParseQuery(string(data))
-		if err != nil {
-			return err
-		}
+	if c, ok := o.getCodec(contentType); ok {
+		return c.Unmarshal(data, result)
+	}
 
-		return parseUrlValue(values, result)
-	default:
-		return ErrNotSupport
+	if u, ok := result.(encoding.BinaryUnmarshaler); ok {
+		return u.UnmarshalBinary(data)
 	}
+
+	return ErrNotSupport
 }
 
 func parseUrlValue(values url.Values, result interface{}) error {
@@ -187,10 +304,188 @@ func parseUrlValue(values url.Values, result interface{}) error {
 }
 
 func parseContentType(content string) string {
-	idx := strings.LastIndexByte(content, ';')
+	idx := strings.IndexByte(content, ';')
 	if idx == -1 {
-		return content
+		return strings.TrimSpace(content)
 	} else {
-		return content[0:idx]
+		return strings.TrimSpace(content[0:idx])
+	}
+}
+
+// cancelBody 在Close时释放请求的context
+type cancelBody struct {
+	io.ReadCloser
+	cancel context.CancelFunc
+}
+
+func (b *cancelBody) Close() error {
+	err := b.ReadCloser.Close()
+	b.cancel()
+	return err
+}
+
+// maxBytesKey context中保存MaxResponseBytes
+type maxBytesKey struct{}
+
+// limitResponse 按请求context中的MaxResponseBytes限制传输的body,压缩时按压缩后计算
+// 解压后的大小在run中再次限制
+func limitResponse(rsp *Response, err error) (*Response, error) {
+	if err != nil || rsp.Request == nil {
+		return rsp, err
+	}
+
+	n, _ := rsp.Request.Context().Value(maxBytesKey{}).(int64)
+	if n <= 0 {
+		return rsp, nil
+	}
+
+	if rsp.ContentLength > n {
+		// 未读取完就关闭,连接不会被复用
+		rsp.Body.Close()
+		return nil, ErrResponseTooLarge
+	}
+
+	rsp.Body = &limitBody{ReadCloser: rsp.Body, remain: n}
+	return rsp, nil
+}
+
+// limitBody 限制body的最大长度,超出时返回ErrResponseTooLarge
+type limitBody struct {
+	io.ReadCloser
+	remain int64
+}
+
+func (b *limitBody) Read(p []byte) (int, error) {
+	// 多读一个字节用于判断是否超出
+	if int64(len(p)) > b.remain+1 {
+		p = p[:b.remain+1]
+	}
+
+	n, err := b.ReadCloser.Read(p)
+	if int64(n) > b.remain {
+		n = int(b.remain)
+		b.remain = 0
+		return n, ErrResponseTooLarge
+	}
+
+	b.remain -= int64(n)
+	return n, err
+}
+
+// readBody 读取并关闭body,然后替换为可重复读取的数据
+func readBody(rsp *http.Response) ([]byte, error) {
+	data, err := readAll(rsp.Body, rsp.ContentLength)
+	rsp.Body.Close()
+	if err != nil {
+		return nil, err
+	}
+
+	rsp.Body = ioutil.NopCloser(bytes.NewReader(data))
+	return data, nil
+}
+
+// maxPreallocSize 按Content-Length预分配的上限,防止服务器声明过大的长度
+const maxPreallocSize = 32 << 20
+
+// readAll 已知长度时一次分配,避免ReadAll多次扩容和复制
+func readAll(r io.Reader, size int64) ([]byte, error) {
+	if size <= 0 || size > maxPreallocSize {
+		return ioutil.ReadAll(r)
+	}
+
+	data := make([]byte, size)
+	n, err := io.ReadFull(r, data)
+	switch {
+	case err == io.EOF && n == 0:
+		return data[:0], nil
+	case err != nil:
+		return nil, err
+	}
+
+	// 实际长度超过Content-Length
+	rest, err := ioutil.ReadAll(r)
+	if err != nil {
+		return nil, err
+	}
+	if len(rest) > 0 {
+		data = append(data, rest...)
+	}
+
+	return data, nil
+}
+
+// fastDecode 结果为*[]byte或无需转码的*string时,直接使用body,不经过decode
+func fastDecode(rsp *http.Response, result interface{}, needCharset bool) (bool, error) {
+	switch v := result.(type) {
+	case *[]byte:
+		data, err := readBody(rsp)
+		if err != nil {
+			return true, err
+		}
+		*v = data
+		return true, nil
+	case *string:
+		if needCharset {
+			return false, nil
+		}
+
+		data, err := readBody(rsp)
+		if err != nil {
+			return true, err
+		}
+		*v = string(data)
+		return true, nil
+	default:
+		return false, nil
+	}
+}
+
+// joinURL 拼接BaseURL和相对路径
+func joinURL(base, path string) string {
+	if path == "" {
+		return base
+	}
+
+	return strings.TrimRight(base, "/") + "/" + strings.TrimLeft(path, "/")
+}
+
+func canonicalKey(key string) string {
+	return textproto.CanonicalMIMEHeaderKey(key)
+}
+
+// isTextValue 判断是否可以直接格式化为字符串
+func isTextValue(v interface{}) bool {
+	switch v.(type) {
+	case string, time.Time, *time.Time, encoding.TextMarshaler, fmt.Stringer:
+		return true
+	default:
+		return false
+	}
+}
+
+// authorization 不含空格时认为是Bearer token
+func authorization(auth string) string {
+	if strings.IndexByte(auth, ' ') == -1 {
+		return "Bearer " + auth
+	}
+
+	return auth
+}
+
+// hostname 去掉host中的端口
+func hostname(host string) string {
+	if h, _, err := net.SplitHostPort(host); err == nil {
+		return h
+	}
+
+	return host
+}
+
+// setUserAgent 优先使用UserAgent,其次是Header中的值,最后是DefaultUserAgent
+func (o *Options) setUserAgent(req *Request) {
+	if o.UserAgent != "" {
+		req.Header.Set("User-Agent", o.UserAgent)
+	} else if req.Header.Get("User-Agent") == "" {
+		req.Header.Set("User-Agent", DefaultUserAgent)
 	}
 }
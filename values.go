@@ -0,0 +1,264 @@
+package ghttp
+
+import (
+	"net/url"
+	"reflect"
+	"strconv"
+	"strings"
+)
+
+// NestedStyle 嵌套结构的key格式
+type NestedStyle int
+
+const (
+	NestedDot     NestedStyle = iota // a.b=1
+	NestedBracket                    // a[b]=1
+)
+
+// ArrayStyle slice的编码格式,元素为struct或map时总是使用下标
+type ArrayStyle int
+
+const (
+	ArrayRepeat  ArrayStyle = iota // a=1&a=2
+	ArrayComma                     // a=1,2
+	ArrayBracket                   // a[]=1&a[]=2
+	ArrayIndex                     // a[0]=1&a[1]=2
+)
+
+// BoolFormat bool值的格式
+type BoolFormat int
+
+const (
+	BoolTrueFalse BoolFormat = iota // true/false
+	BoolOneZero                     // 1/0
+	BoolOnOff                       // on/off
+)
+
+// valueEncoder 将struct和map编码为url.Values,支持嵌套结构,slice按ArrayStyle编码
+type valueEncoder struct {
+	tag        string
+	nested     NestedStyle
+	arrayStyle ArrayStyle
+	timeFormat string
+	omitZero   bool
+	boolFormat BoolFormat
+	nilAsEmpty bool
+}
+
+func (o *Options) newValueEncoder(tag string) *valueEncoder {
+	return &valueEncoder{
+		tag:        tag,
+		nested:     o.NestedStyle,
+		arrayStyle: o.ArrayStyle,
+		timeFormat: o.TimeFormat,
+		omitZero:   o.OmitZero,
+		boolFormat: o.BoolFormat,
+		nilAsEmpty: o.NilAsEmpty,
+	}
+}
+
+func (e *valueEncoder) Encode(values url.Values, data interface{}) error {
+	v := indirect(reflect.ValueOf(data))
+	if !v.IsValid() {
+		return nil
+	}
+
+	switch v.Kind() {
+	case reflect.Struct, reflect.Map:
+		return e.encode(values, "", v)
+	default:
+		return ErrInvalidType
+	}
+}
+
+func (e *valueEncoder) encode(values url.Values, key string, v reflect.Value) error {
+	v = indirect(v)
+	if !v.IsValid() {
+		e.addNil(values, key)
+		return nil
+	}
+
+	if v.CanInterface() && isTextValue(v.Interface()) {
+		e.add(values, key, v)
+		return nil
+	}
+
+	switch v.Kind() {
+	case reflect.Struct:
+		return e.encodeStruct(values, key, v)
+	case reflect.Map:
+		iter := v.MapRange()
+		for iter.Next() {
+			name := formatValue(iter.Key().Interface(), e.timeFormat)
+			if err := e.encode(values, e.join(key, name), iter.Value()); err != nil {
+				return err
+			}
+		}
+		return nil
+	case reflect.Slice, reflect.Array:
+		if v.Kind() == reflect.Slice && v.Type().Elem().Kind() == reflect.Uint8 {
+			values.Add(key, string(v.Bytes()))
+			return nil
+		}
+
+		return e.encodeSlice(values, key, v)
+	case reflect.Func, reflect.Chan, reflect.UnsafePointer, reflect.Complex64, reflect.Complex128:
+		return ErrNotSupport
+	default:
+		e.add(values, key, v)
+		return nil
+	}
+}
+
+func (e *valueEncoder) encodeSlice(values url.Values, key string, v reflect.Value) error {
+	var joined []string // ArrayComma时合并的值
+	for i := 0; i < v.Len(); i++ {
+		elem := indirect(v.Index(i))
+		var err error
+		switch {
+		case isComposite(elem) || e.arrayStyle == ArrayIndex:
+			// 结构体数组需要下标区分
+			err = e.encode(values, key+"["+strconv.Itoa(i)+"]", elem)
+		case e.arrayStyle == ArrayBracket:
+			err = e.encode(values, key+"[]", elem)
+		case e.arrayStyle == ArrayComma:
+			tmp := url.Values{}
+			err = e.encode(tmp, key, elem)
+			joined = append(joined, tmp[key]...)
+		default:
+			err = e.encode(values, key, elem)
+		}
+		if err != nil {
+			return err
+		}
+	}
+
+	if len(joined) > 0 {
+		values.Add(key, strings.Join(joined, ","))
+	}
+
+	return nil
+}
+
+// add 添加标量值,根据设置忽略零值和格式化bool
+func (e *valueEncoder) add(values url.Values, key string, v reflect.Value) {
+	if e.omitZero && v.IsZero() {
+		return
+	}
+
+	if v.Kind() == reflect.Bool {
+		values.Add(key, e.formatBool(v.Bool()))
+		return
+	}
+
+	values.Add(key, formatValue(v.Interface(), e.timeFormat))
+}
+
+// addNil nil指针默认忽略,nilAsEmpty时编码为空值
+func (e *valueEncoder) addNil(values url.Values, key string) {
+	if e.nilAsEmpty && key != "" {
+		values.Add(key, "")
+	}
+}
+
+func (e *valueEncoder) formatBool(b bool) string {
+	switch e.boolFormat {
+	case BoolOneZero:
+		if b {
+			return "1"
+		}
+		return "0"
+	case BoolOnOff:
+		if b {
+			return "on"
+		}
+		return "off"
+	default:
+		return strconv.FormatBool(b)
+	}
+}
+
+func (e *valueEncoder) encodeStruct(values url.Values, key string, v reflect.Value) error {
+	t := v.Type()
+	for i := 0; i < t.NumField(); i++ {
+		field := t.Field(i)
+		if field.PkgPath != "" && !field.Anonymous {
+			continue
+		}
+
+		name, omitEmpty := parseTag(field.Tag.Get(e.tag))
+		if name == "-" {
+			continue
+		}
+
+		fv := v.Field(i)
+		if omitEmpty && fv.IsZero() {
+			continue
+		}
+
+		// 匿名嵌入且没有指定名字时展开到当前层级
+		if field.Anonymous && name == "" && indirect(fv).Kind() == reflect.Struct {
+			if err := e.encode(values, key, fv); err != nil {
+				return err
+			}
+			continue
+		}
+
+		if field.PkgPath != "" {
+			continue
+		}
+
+		if name == "" {
+			name = field.Name
+		}
+
+		if err := e.encode(values, e.join(key, name), fv); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+func (e *valueEncoder) join(prefix, name string) string {
+	if prefix == "" {
+		return name
+	}
+
+	if e.nested == NestedBracket {
+		return prefix + "[" + name + "]"
+	}
+
+	return prefix + "." + name
+}
+
+func parseTag(tag string) (string, bool) {
+	parts := strings.Split(tag, ",")
+	omitEmpty := false
+	for _, p := range parts[1:] {
+		if p == "omitempty" {
+			omitEmpty = true
+		}
+	}
+
+	return parts[0], omitEmpty
+}
+
+func indirect(v reflect.Value) reflect.Value {
+	for v.IsValid() && (v.Kind() == reflect.Ptr || v.Kind() == reflect.Interface) {
+		if v.IsNil() {
+			return reflect.Value{}
+		}
+		v = v.Elem()
+	}
+
+	return v
+}
+
+func isComposite(v reflect.Value) bool {
+	if !v.IsValid() || (v.CanInterface() && isTextValue(v.Interface())) {
+		return false
+	}
+
+	return v.Kind() == reflect.Struct || v.Kind() == reflect.Map
+}
@@ -0,0 +1,209 @@
+package ghttp
+
+import (
+	"net/http"
+	"net/url"
+	"reflect"
+	"testing"
+	"time"
+)
+
+type testAddress struct {
+	City string `query:"city"`
+	Zip  string `query:"zip,omitempty"`
+}
+
+type testQuery struct {
+	Name    string        `query:"name"`
+	Tags    []string      `query:"tag"`
+	Address testAddress   `query:"addr"`
+	History []testAddress `query:"history"`
+	Ignore  string        `query:"-"`
+}
+
+func TestQueryParams(t *testing.T) {
+	q := testQuery{
+		Name:    "jeck",
+		Tags:    []string{"a", "b"},
+		Address: testAddress{City: "sh"},
+		History: []testAddress{{City: "bj", Zip: "100000"}},
+		Ignore:  "x",
+	}
+
+	tests := []struct {
+		style  NestedStyle
+		expect string
+	}{
+		{NestedDot, "addr.city=sh&history%5B0%5D.city=bj&history%5B0%5D.zip=100000&name=jeck&tag=a&tag=b"},
+		{NestedBracket, "addr%5Bcity%5D=sh&history%5B0%5D%5Bcity%5D=bj&history%5B0%5D%5Bzip%5D=100000&name=jeck&tag=a&tag=b"},
+	}
+
+	for _, tt := range tests {
+		o := &Options{}
+		o.build(WithQueryParams(q), WithNestedStyle(tt.style))
+		raw, err := o.toRawQuery(url.Values{})
+		if err != nil {
+			t.Fatal(err)
+		}
+		if raw != tt.expect {
+			t.Errorf("style %+v: got %+v, want %+v", tt.style, raw, tt.expect)
+		}
+	}
+}
+
+func TestValueSwitches(t *testing.T) {
+	type params struct {
+		Enable bool    `query:"enable"`
+		Count  int     `query:"count"`
+		Name   *string `query:"name"`
+	}
+
+	tests := []struct {
+		opts   []Option
+		expect string
+	}{
+		{nil, "count=0&enable=false"},
+		{[]Option{WithOmitZero(true)}, ""},
+		{[]Option{WithBoolFormat(BoolOneZero), WithNilAsEmpty(true)}, "count=0&enable=0&name="},
+		{[]Option{WithBoolFormat(BoolOnOff), WithOmitZero(true)}, ""},
+	}
+
+	for i, tt := range tests {
+		o := &Options{}
+		o.build(append(tt.opts, WithQueryParams(params{}))...)
+		raw, err := o.toRawQuery(url.Values{})
+		if err != nil {
+			t.Fatal(err)
+		}
+		if raw != tt.expect {
+			t.Errorf("case %+v: got %+v, want %+v", i, raw, tt.expect)
+		}
+	}
+
+	o := &Options{}
+	o.build(WithBoolFormat(BoolOnOff))
+	data, err := o.encode(TypeForm, map[string]interface{}{"a": true, "b": nil})
+	if err != nil || string(data) != "a=on" {
+		t.Errorf("unexpected form: %s, %+v", data, err)
+	}
+}
+
+func TestHeaderValue(t *testing.T) {
+	o := &Options{}
+	tm := time.Date(2020, 1, 2, 3, 4, 5, 0, time.FixedZone("CST", 8*3600))
+	o.build(
+		WithHeader("x-count", 10),
+		WithHeader("x-enable", true),
+		WithHeader("x-ratio", 0.5),
+		WithHeader("if-modified-since", tm),
+		WithHeader("x-ids", []int{1, 2}),
+		WithHeader("x-max-age", time.Minute),
+	)
+
+	expect := http.Header{
+		"X-Count":           {"10"},
+		"X-Enable":          {"true"},
+		"X-Ratio":           {"0.5"},
+		"If-Modified-Since": {"Wed, 01 Jan 2020 19:04:05 GMT"},
+		"X-Ids":             {"1", "2"},
+		"X-Max-Age":         {"60"},
+	}
+	if !reflect.DeepEqual(o.Header, expect) {
+		t.Errorf("unexpected header: %+v", o.Header)
+	}
+}
+
+func TestConditionalHeader(t *testing.T) {
+	o := &Options{}
+	tm := time.Date(2020, 1, 2, 3, 4, 5, 0, time.UTC)
+	o.build(WithIfUnmodifiedSince(tm), WithIfMatch("abc", `W/"def"`), WithDate(tm), WithDate(tm))
+
+	if v := o.Header.Get("If-Unmodified-Since"); v != "Thu, 02 Jan 2020 03:04:05 GMT" {
+		t.Errorf("unexpected If-Unmodified-Since: %+v", v)
+	}
+	if v := o.Header.Get("If-Match"); v != `"abc", W/"def"` {
+		t.Errorf("unexpected If-Match: %+v", v)
+	}
+	if v := o.Header["Date"]; len(v) != 1 {
+		t.Errorf("unexpected Date: %+v", v)
+	}
+}
+
+func TestFormStruct(t *testing.T) {
+	type address struct {
+		City string `form:"city"`
+		Zip  string `form:"zip,omitempty"`
+	}
+	type base struct {
+		ID int `form:"id"`
+	}
+	type user struct {
+		base
+		Name    string    `form:"name"`
+		Tags    []string  `form:"tags"`
+		Created time.Time `form:"created"`
+		Address address   `form:"address"`
+		Secret  string    `form:"-"`
+	}
+
+	tm := time.Date(2020, 1, 2, 3, 4, 5, 0, time.UTC)
+	u := &user{base: base{ID: 1}, Name: "bob", Tags: []string{"a", "b"}, Created: tm, Address: address{City: "sh"}, Secret: "x"}
+
+	o := &Options{}
+	o.build(WithTimeFormat(TimeFormatUnix))
+	data, err := o.encode(TypeForm, u)
+	if err != nil {
+		t.Fatal(err)
+	}
+	expect := "address.city=sh&created=1577934245&id=1&name=bob&tags=a&tags=b"
+	if string(data) != expect {
+		t.Errorf("got %s, want %s", data, expect)
+	}
+
+	data, err = o.encode(TypeForm, map[string]interface{}{"ids": []int{1, 2}, "name": "bob"})
+	if err != nil || string(data) != "ids=1&ids=2&name=bob" {
+		t.Errorf("unexpected form: %s, %+v", data, err)
+	}
+
+	if _, err := o.encode(TypeForm, []int{1}); err != ErrNotSupport {
+		t.Errorf("expect ErrNotSupport, got %v", err)
+	}
+}
+
+func TestArrayStyle(t *testing.T) {
+	type item struct {
+		ID int `query:"id"`
+	}
+	type params struct {
+		IDs   []int  `query:"ids"`
+		Items []item `query:"items"`
+		Empty []int  `query:"empty"`
+	}
+
+	tests := []struct {
+		style  ArrayStyle
+		expect string
+	}{
+		{ArrayRepeat, "ids=1&ids=2&items[0].id=3"},
+		{ArrayComma, "ids=1,2&items[0].id=3"},
+		{ArrayBracket, "ids[]=1&ids[]=2&items[0].id=3"},
+		{ArrayIndex, "ids[0]=1&ids[1]=2&items[0].id=3"},
+	}
+
+	for _, tt := range tests {
+		o := NewOptions(WithArrayStyle(tt.style), WithQueryParams(params{IDs: []int{1, 2}, Items: []item{{ID: 3}}}))
+		raw, err := o.toRawQuery(url.Values{})
+		if err != nil {
+			t.Fatal(err)
+		}
+		if raw, _ = url.QueryUnescape(raw); raw != tt.expect {
+			t.Errorf("style %v: got %v, want %v", tt.style, raw, tt.expect)
+		}
+	}
+
+	o := NewOptions(WithArrayStyle(ArrayComma))
+	data, err := o.encode(TypeForm, map[string]interface{}{"tags": []string{"a", "b"}})
+	if err != nil || string(data) != "tags=a%2Cb" {
+		t.Errorf("unexpected form: %s, %+v", data, err)
+	}
+}
@@ -0,0 +1,83 @@
+package ghttp
+
+import (
+	"fmt"
+	"strings"
+)
+
+const defaultVersionHeader = "X-API-Version"
+
+// VersionPolicy 接口版本的协商方式,配合WithAPIVersion使用
+type VersionPolicy struct {
+	RequestHeader  string // 请求中携带版本的header,默认X-API-Version,为-时不设置
+	AcceptParam    string // 非空时在Accept中添加此参数,如version得到application/json; version=2
+	ResponseHeader string // 服务器返回版本的header,默认同RequestHeader,为-时不校验
+	Strict         bool   // 应答中没有版本时也认为不一致
+}
+
+// VersionMismatchError 服务器返回的版本与请求的版本不一致
+type VersionMismatchError struct {
+	URL      string
+	Expected string
+	Actual   string // 为空表示服务器没有返回版本
+}
+
+func (e *VersionMismatchError) Error() string {
+	return fmt.Sprintf("api version mismatch: %s expect %q, got %q", e.URL, e.Expected, e.Actual)
+}
+
+// IsVersionMismatch 判断是否是版本不一致的错误
+func IsVersionMismatch(err error) bool {
+	_, ok := err.(*VersionMismatchError)
+	return ok
+}
+
+func (o *Options) versionPolicy() *VersionPolicy {
+	if o.VersionPolicy != nil {
+		return o.VersionPolicy
+	}
+
+	return &VersionPolicy{}
+}
+
+// setVersion 在请求中设置版本
+func (o *Options) setVersion(req *Request, contentType string) {
+	p := o.versionPolicy()
+	header := p.RequestHeader
+	if header == "" {
+		header = defaultVersionHeader
+	}
+	if header != "-" {
+		req.Header.Set(header, o.APIVersion)
+	}
+
+	if p.AcceptParam != "" {
+		accept := req.Header.Get("Accept")
+		if accept == "" {
+			accept = contentType
+		}
+		req.Header.Set("Accept", accept+"; "+p.AcceptParam+"="+o.APIVersion)
+	}
+}
+
+// checkVersion 校验服务器返回的版本
+func (o *Options) checkVersion(rsp *Response) error {
+	p := o.versionPolicy()
+	header := p.ResponseHeader
+	if header == "" {
+		header = p.RequestHeader
+	}
+	if header == "" {
+		header = defaultVersionHeader
+	}
+	if header == "-" {
+		return nil
+	}
+
+	actual := strings.TrimSpace(rsp.Header.Get(header))
+	if actual == o.APIVersion || (actual == "" && !p.Strict) {
+		return nil
+	}
+
+	return &VersionMismatchError{URL: rsp.Request.URL.String(), Expected: o.APIVersion, Actual: actual}
+}
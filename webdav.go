@@ -0,0 +1,144 @@
+package ghttp
+
+import (
+	"encoding/xml"
+	"strconv"
+	"strings"
+)
+
+// WebDAV(RFC 4918)的扩展方法,其他扩展方法同样可以直接传给DoRequest
+const (
+	MethodPropfind  = "PROPFIND"
+	MethodProppatch = "PROPPATCH"
+	MethodMkcol     = "MKCOL"
+	MethodCopy      = "COPY"
+	MethodMove      = "MOVE"
+	MethodLock      = "LOCK"
+	MethodUnlock    = "UNLOCK"
+	MethodReport    = "REPORT"
+)
+
+// Depth头的取值
+const (
+	DepthZero     = "0"
+	DepthOne      = "1"
+	DepthInfinity = "infinity"
+)
+
+// Multistatus 207应答
+type Multistatus struct {
+	XMLName     xml.Name      `xml:"DAV: multistatus"`
+	Responses   []DAVResponse `xml:"DAV: response"`
+	Description string        `xml:"DAV: responsedescription,omitempty"`
+	SyncToken   string        `xml:"DAV: sync-token,omitempty"`
+}
+
+// DAVResponse 一个资源的结果,Status只在没有Propstat时存在
+type DAVResponse struct {
+	Href        []string   `xml:"DAV: href"`
+	Status      string     `xml:"DAV: status,omitempty"`
+	Propstats   []Propstat `xml:"DAV: propstat"`
+	Description string     `xml:"DAV: responsedescription,omitempty"`
+}
+
+// Propstat 状态相同的一组属性
+type Propstat struct {
+	Prop   DAVProp `xml:"DAV: prop"`
+	Status string  `xml:"DAV: status"`
+}
+
+// DAVProp prop中的属性列表
+type DAVProp struct {
+	Props []DAVProperty `xml:",any"`
+}
+
+// DAVProperty 一个属性,Value为文本内容,Inner为原始xml,如resourcetype中的collection
+type DAVProperty struct {
+	XMLName xml.Name
+	Value   string `xml:",chardata"`
+	Inner   string `xml:",innerxml"`
+}
+
+// StatusCode 解析Status中的状态码,如HTTP/1.1 404 Not Found
+func (r *DAVResponse) StatusCode() int {
+	return parseDAVStatus(r.Status)
+}
+
+// Prop 返回2xx状态中本地名为name的属性,不区分命名空间
+func (r *DAVResponse) Prop(name string) (*DAVProperty, bool) {
+	for i := range r.Propstats {
+		ps := &r.Propstats[i]
+		if code := parseDAVStatus(ps.Status); code < 200 || code >= 300 {
+			continue
+		}
+		for j := range ps.Prop.Props {
+			if ps.Prop.Props[j].XMLName.Local == name {
+				return &ps.Prop.Props[j], true
+			}
+		}
+	}
+
+	return nil, false
+}
+
+// IsCollection 判断resourcetype是否包含collection,即是否是目录
+func (r *DAVResponse) IsCollection() bool {
+	p, ok := r.Prop("resourcetype")
+	return ok && strings.Contains(p.Inner, "collection")
+}
+
+func parseDAVStatus(status string) int {
+	fields := strings.Fields(status)
+	if len(fields) < 2 {
+		return 0
+	}
+
+	code, _ := strconv.Atoi(fields[1])
+	return code
+}
+
+// Propfind 查询属性,props为DAV:命名空间下的属性名,为空时查询所有属性
+func (c *Client) Propfind(url string, depth string, props []string, opts ...Option) (*Multistatus, error) {
+	b := strings.Builder{}
+	b.WriteString(`<?xml version="1.0" encoding="utf-8"?><D:propfind xmlns:D="DAV:">`)
+	if len(props) == 0 {
+		b.WriteString("<D:allprop/>")
+	} else {
+		b.WriteString("<D:prop>")
+		for _, p := range props {
+			b.WriteString("<D:")
+			xml.EscapeText(&b, []byte(p))
+			b.WriteString("/>")
+		}
+		b.WriteString("</D:prop>")
+	}
+	b.WriteString("</D:propfind>")
+
+	all := make([]Option, 0, len(opts)+2)
+	all = append(all, WithContentType(TypeXML), WithDepth(depth))
+	all = append(all, opts...)
+
+	ms := &Multistatus{}
+	if _, err := c.DoRequest(MethodPropfind, url, b.String(), ms, all...); err != nil {
+		return nil, err
+	}
+
+	return ms, nil
+}
+
+// Mkcol 创建目录
+func (c *Client) Mkcol(url string, opts ...Option) (*Response, error) {
+	return c.DoRequest(MethodMkcol, url, nil, nil, opts...)
+}
+
+// Copy 复制资源,dst为完整地址或绝对路径,overwrite为false时目标存在则失败
+func (c *Client) Copy(src string, dst string, overwrite bool, opts ...Option) (*Response, error) {
+	all := append([]Option{WithDestination(dst), WithOverwrite(overwrite)}, opts...)
+	return c.DoRequest(MethodCopy, src, nil, nil, all...)
+}
+
+// Move 移动资源,dst为完整地址或绝对路径,overwrite为false时目标存在则失败
+func (c *Client) Move(src string, dst string, overwrite bool, opts ...Option) (*Response, error) {
+	all := append([]Option{WithDestination(dst), WithOverwrite(overwrite)}, opts...)
+	return c.DoRequest(MethodMove, src, nil, nil, all...)
+}
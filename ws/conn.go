@@ -0,0 +1,398 @@
+// Package ws 基于ghttp的WebSocket客户端,与ghttp使用相同的Option
+// Header,Cookie,AuthProvider等参数在握手时生效,断开后按Retry和Backoff自动重连
+// 使用Client的Transport建立连接,需要TLS和拨号参数时通过NewClient创建Client后调用DialClient
+//
+//	conn, err := ws.Dial("wss://example.com/ws", ghttp.WithBearAuth(token), ghttp.WithRetry(5))
+package ws
+
+import (
+	"bufio"
+	"context"
+	"crypto/rand"
+	"crypto/sha1"
+	"encoding/base64"
+	"encoding/binary"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/jeckbjy/ghttp"
+)
+
+// 消息类型,同RFC 6455的opcode
+const (
+	TextMessage   = 1
+	BinaryMessage = 2
+	CloseMessage  = 8
+	PingMessage   = 9
+	PongMessage   = 10
+)
+
+// 关闭码
+const (
+	CloseNormalClosure = 1000
+	CloseGoingAway     = 1001
+	CloseNoStatus      = 1005
+)
+
+const (
+	defaultPingInterval = 30 * time.Second
+	acceptGUID          = "258EAFA5-E914-47DA-95CA-C5AB0DC85B11"
+)
+
+var (
+	ErrClosed          = errors.New("ws: connection closed")
+	ErrBadHandshake    = errors.New("ws: bad handshake")
+	ErrProtocol        = errors.New("ws: protocol error")
+	ErrMessageTooLarge = errors.New("ws: message too large")
+)
+
+// CloseError 服务器发送的关闭帧
+type CloseError struct {
+	Code int
+	Text string
+}
+
+func (e *CloseError) Error() string {
+	return fmt.Sprintf("ws: close %d %s", e.Code, e.Text)
+}
+
+// Conn WebSocket连接,同一时间只能有一个goroutine读取,写入可以并发
+// 读取时发现连接断开会自动重连,重连期间写入返回错误,断开时未送达的消息会丢失
+type Conn struct {
+	client   *ghttp.Client
+	url      string
+	opts     []ghttp.Option
+	ctx      context.Context
+	retry    int
+	backoff  ghttp.Backoff
+	interval time.Duration // 发送ping的间隔,超过两个间隔没有收到数据时断开
+	limit    int64         // 消息的最大字节数
+
+	mutex  sync.Mutex
+	cur    *conn
+	closed bool
+}
+
+// conn 一次握手建立的连接
+type conn struct {
+	rsp      *ghttp.Response
+	rwc      io.ReadWriteCloser
+	br       *bufio.Reader
+	wmutex   sync.Mutex
+	lastRead atomic.Int64
+	done     chan struct{}
+	once     sync.Once
+}
+
+func (cc *conn) write(op int, payload []byte) error {
+	cc.wmutex.Lock()
+	defer cc.wmutex.Unlock()
+	return writeFrame(cc.rwc, op, payload, true)
+}
+
+func (cc *conn) close() {
+	cc.once.Do(func() {
+		close(cc.done)
+		cc.rwc.Close()
+	})
+}
+
+// pingLoop 定时发送ping,超过两个间隔没有收到任何数据时关闭连接,由读取方重连
+func (cc *conn) pingLoop(interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-cc.done:
+			return
+		case now := <-ticker.C:
+			if now.Sub(time.Unix(0, cc.lastRead.Load())) > 2*interval {
+				cc.close()
+				return
+			}
+			if err := cc.write(PingMessage, nil); err != nil {
+				cc.close()
+				return
+			}
+		}
+	}
+}
+
+// Dial 使用ghttp.Default建立WebSocket连接,url可以是ws,wss,http或https
+func Dial(url string, opts ...ghttp.Option) (*Conn, error) {
+	return DialClient(ghttp.Default, url, opts...)
+}
+
+// DialClient 使用client的Transport建立WebSocket连接,多个连接共享client的拨号和TLS参数
+// Timeout限制握手时间,Retry为断开后的重连次数,每次重连前按Backoff等待
+// PingInterval为ping的间隔,默认30秒,小于0时不发送,MaxMessageSize限制单条消息的大小
+func DialClient(client *ghttp.Client, url string, opts ...ghttp.Option) (*Conn, error) {
+	o := client.Options(opts...)
+	c := &Conn{
+		client:   client,
+		url:      url,
+		opts:     opts,
+		ctx:      o.Context,
+		retry:    o.Retry,
+		backoff:  o.Backoff,
+		interval: o.PingInterval,
+		limit:    o.MaxMessageSize,
+	}
+	if c.interval == 0 {
+		c.interval = defaultPingInterval
+	}
+
+	cc, err := c.dial()
+	if err != nil {
+		return nil, err
+	}
+	c.cur = cc
+	return c, nil
+}
+
+func (c *Conn) dial() (*conn, error) {
+	var nonce [16]byte
+	if _, err := rand.Read(nonce[:]); err != nil {
+		return nil, err
+	}
+	key := base64.StdEncoding.EncodeToString(nonce[:])
+
+	opts := append(c.opts[:len(c.opts):len(c.opts)],
+		ghttp.WithHeader("Sec-WebSocket-Key", key),
+		ghttp.WithHeader("Sec-WebSocket-Version", "13"))
+	rsp, rwc, err := c.client.Upgrade(c.url, "websocket", opts...)
+	if err != nil {
+		return nil, err
+	}
+
+	if !headerContains(rsp.Header, "Upgrade", "websocket") || rsp.Header.Get("Sec-WebSocket-Accept") != acceptKey(key) {
+		rwc.Close()
+		return nil, ErrBadHandshake
+	}
+
+	cc := &conn{rsp: rsp, rwc: rwc, br: bufio.NewReader(rwc), done: make(chan struct{})}
+	cc.lastRead.Store(time.Now().UnixNano())
+	if c.interval > 0 {
+		go cc.pingLoop(c.interval)
+	}
+
+	return cc, nil
+}
+
+// reconnect 按Backoff等待后重连,最多retry次
+func (c *Conn) reconnect(old *conn) error {
+	if c.backoff != nil {
+		c.backoff.Reset()
+	}
+
+	var err error
+	for i := 0; i < c.retry; i++ {
+		if c.backoff != nil {
+			timer := time.NewTimer(c.backoff.Next())
+			select {
+			case <-timer.C:
+			case <-c.ctx.Done():
+				timer.Stop()
+				return c.ctx.Err()
+			}
+		}
+
+		var cc *conn
+		if cc, err = c.dial(); err != nil {
+			continue
+		}
+
+		c.mutex.Lock()
+		if c.closed {
+			c.mutex.Unlock()
+			cc.close()
+			return ErrClosed
+		}
+		if c.cur == old {
+			c.cur = cc
+		}
+		c.mutex.Unlock()
+		return nil
+	}
+
+	return err
+}
+
+func (c *Conn) current() (*conn, error) {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+	if c.closed {
+		return nil, ErrClosed
+	}
+
+	return c.cur, nil
+}
+
+// Response 返回当前连接的握手应答,可以读取协商的子协议等
+func (c *Conn) Response() *ghttp.Response {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+	return c.cur.rsp
+}
+
+// ReadMessage 读取一条完整的消息,自动回复ping
+// 连接断开或服务器以非1000的关闭码关闭时自动重连,重连失败时返回错误
+func (c *Conn) ReadMessage() (int, []byte, error) {
+	for {
+		cc, err := c.current()
+		if err != nil {
+			return 0, nil, err
+		}
+
+		op, data, err := c.read(cc)
+		if err == nil {
+			return op, data, nil
+		}
+
+		cc.close()
+		var ce *CloseError
+		if c.isClosed() {
+			return 0, nil, ErrClosed
+		}
+		if errors.As(err, &ce) && ce.Code == CloseNormalClosure || errors.Is(err, ErrMessageTooLarge) || c.retry <= 0 {
+			return 0, nil, err
+		}
+		if rerr := c.reconnect(cc); rerr != nil {
+			return 0, nil, rerr
+		}
+	}
+}
+
+// read 从一个连接中读取消息,处理分片和控制帧
+func (c *Conn) read(cc *conn) (int, []byte, error) {
+	op := 0
+	var data []byte
+	for {
+		f, err := readFrame(cc.br, c.limit)
+		if err != nil {
+			return 0, nil, err
+		}
+		cc.lastRead.Store(time.Now().UnixNano())
+
+		switch f.op {
+		case PingMessage:
+			if err := cc.write(PongMessage, f.payload); err != nil {
+				return 0, nil, err
+			}
+			continue
+		case PongMessage:
+			continue
+		case CloseMessage:
+			ce := &CloseError{Code: CloseNoStatus}
+			if len(f.payload) >= 2 {
+				ce.Code = int(binary.BigEndian.Uint16(f.payload))
+				ce.Text = string(f.payload[2:])
+			}
+			cc.write(CloseMessage, f.payload[:min(len(f.payload), 2)])
+			return 0, nil, ce
+		case TextMessage, BinaryMessage:
+			if op != 0 {
+				return 0, nil, ErrProtocol
+			}
+			op = f.op
+		case opContinuation:
+			if op == 0 {
+				return 0, nil, ErrProtocol
+			}
+		default:
+			return 0, nil, ErrProtocol
+		}
+
+		data = append(data, f.payload...)
+		if c.limit > 0 && int64(len(data)) > c.limit {
+			return 0, nil, ErrMessageTooLarge
+		}
+		if f.fin {
+			return op, data, nil
+		}
+	}
+}
+
+// WriteMessage 发送一条消息,可以并发调用
+func (c *Conn) WriteMessage(op int, data []byte) error {
+	cc, err := c.current()
+	if err != nil {
+		return err
+	}
+
+	return cc.write(op, data)
+}
+
+// WriteText 发送文本消息
+func (c *Conn) WriteText(text string) error {
+	return c.WriteMessage(TextMessage, []byte(text))
+}
+
+// WriteJSON 以文本消息发送v的json编码
+func (c *Conn) WriteJSON(v interface{}) error {
+	data, err := json.Marshal(v)
+	if err != nil {
+		return err
+	}
+
+	return c.WriteMessage(TextMessage, data)
+}
+
+// ReadJSON 读取一条消息并以json解码到v
+func (c *Conn) ReadJSON(v interface{}) error {
+	_, data, err := c.ReadMessage()
+	if err != nil {
+		return err
+	}
+
+	return json.Unmarshal(data, v)
+}
+
+func (c *Conn) isClosed() bool {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+	return c.closed
+}
+
+// Close 发送关闭帧并关闭连接,不再重连
+func (c *Conn) Close() error {
+	c.mutex.Lock()
+	if c.closed {
+		c.mutex.Unlock()
+		return nil
+	}
+	c.closed = true
+	cc := c.cur
+	c.mutex.Unlock()
+
+	var code [2]byte
+	binary.BigEndian.PutUint16(code[:], CloseNormalClosure)
+	err := cc.write(CloseMessage, code[:])
+	cc.close()
+	return err
+}
+
+func acceptKey(key string) string {
+	h := sha1.Sum([]byte(key + acceptGUID))
+	return base64.StdEncoding.EncodeToString(h[:])
+}
+
+// headerContains 判断以逗号分隔的header中是否包含token,不区分大小写
+func headerContains(header http.Header, key string, token string) bool {
+	for _, v := range header.Values(key) {
+		for _, s := range strings.Split(v, ",") {
+			if strings.EqualFold(strings.TrimSpace(s), token) {
+				return true
+			}
+		}
+	}
+
+	return false
+}
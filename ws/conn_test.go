@@ -0,0 +1,140 @@
+package ws
+
+import (
+	"bufio"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/jeckbjy/ghttp"
+)
+
+// serve 完成握手后交给fn处理,fn返回时关闭连接
+func serve(t *testing.T, fn func(n int, br *bufio.Reader, w *bufio.Writer)) *httptest.Server {
+	var count int32
+	return httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("Authorization") != "Bearer token" || !strings.EqualFold(r.Header.Get("Upgrade"), "websocket") {
+			w.WriteHeader(http.StatusUnauthorized)
+			return
+		}
+
+		w.Header().Set("Upgrade", "websocket")
+		w.Header().Set("Connection", "Upgrade")
+		w.Header().Set("Sec-WebSocket-Accept", acceptKey(r.Header.Get("Sec-WebSocket-Key")))
+		w.WriteHeader(http.StatusSwitchingProtocols)
+
+		conn, rw, err := http.NewResponseController(w).Hijack()
+		if err != nil {
+			t.Error(err)
+			return
+		}
+		defer conn.Close()
+		fn(int(atomic.AddInt32(&count, 1)), rw.Reader, rw.Writer)
+	}))
+}
+
+func TestConn(t *testing.T) {
+	srv := serve(t, func(n int, br *bufio.Reader, w *bufio.Writer) {
+		// 第一个连接发送ping和一条消息后断开,用于测试重连
+		if n == 1 {
+			writeFrame(w, PingMessage, []byte("p"), false)
+			w.Flush()
+			if f, err := readFrame(br, 0); err != nil || f.op != PongMessage || string(f.payload) != "p" {
+				t.Errorf("expect pong, got %+v, %v", f, err)
+			}
+			writeFrame(w, TextMessage, []byte("first"), false)
+			w.Flush()
+			return
+		}
+
+		// 之后的连接原样返回收到的消息
+		for {
+			f, err := readFrame(br, 0)
+			if err != nil || f.op == CloseMessage {
+				return
+			}
+			writeFrame(w, f.op, f.payload, false)
+			w.Flush()
+		}
+	})
+	defer srv.Close()
+
+	conn, err := Dial(srv.URL, ghttp.WithBearAuth("token"), ghttp.WithRetry(3), ghttp.WithBackoff(ghttp.NewConstantBackoff(10*time.Millisecond)))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer conn.Close()
+
+	if op, data, err := conn.ReadMessage(); err != nil || op != TextMessage || string(data) != "first" {
+		t.Fatalf("unexpected message: %v, %q, %v", op, data, err)
+	}
+
+	// 读取时发现断开并重连,之后再发送
+	done := make(chan struct{})
+	var got struct{ Name string }
+	go func() {
+		defer close(done)
+		if err := conn.ReadJSON(&got); err != nil {
+			t.Error(err)
+		}
+	}()
+	for wait := true; wait; {
+		conn.WriteJSON(map[string]string{"name": "echo"})
+		select {
+		case <-done:
+			wait = false
+		case <-time.After(20 * time.Millisecond):
+		}
+	}
+	if got.Name != "echo" {
+		t.Errorf("unexpected echo: %+v", got)
+	}
+
+	conn.Close()
+	if _, _, err := conn.ReadMessage(); err != ErrClosed {
+		t.Errorf("expect ErrClosed, got %v", err)
+	}
+
+	if _, err := Dial(strings.Replace(srv.URL, "http", "ws", 1)); !ghttp.IsStatusErr(err) {
+		t.Errorf("expect status error, got %v", err)
+	}
+}
+
+func TestDialClient(t *testing.T) {
+	srv := serve(t, func(n int, br *bufio.Reader, w *bufio.Writer) {
+		// 等待客户端的ping后发送超过限制的消息
+		if f, err := readFrame(br, 0); err != nil || f.op != PingMessage {
+			t.Errorf("expect ping, got %+v, %v", f, err)
+		}
+		writeFrame(w, TextMessage, []byte("0123456789"), false)
+		w.Flush()
+		readFrame(br, 0)
+	})
+	defer srv.Close()
+
+	var calls int32
+	transport := ghttp.RoundTripperFunc(func(req *http.Request) (*http.Response, error) {
+		atomic.AddInt32(&calls, 1)
+		return http.DefaultTransport.RoundTrip(req)
+	})
+	client := ghttp.NewClient(ghttp.WithTransport(transport), ghttp.WithBearAuth("token"))
+
+	for i := 0; i < 2; i++ {
+		conn, err := DialClient(client, srv.URL, ghttp.WithPingInterval(10*time.Millisecond), ghttp.WithMaxMessageSize(5))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if _, _, err := conn.ReadMessage(); err != ErrMessageTooLarge {
+			t.Errorf("expect ErrMessageTooLarge, got %v", err)
+		}
+		conn.Close()
+	}
+
+	// 复用client的Transport
+	if n := atomic.LoadInt32(&calls); n != 2 {
+		t.Errorf("expect 2 handshakes through client transport, got %v", n)
+	}
+}
@@ -0,0 +1,122 @@
+package ws
+
+import (
+	"bufio"
+	"crypto/rand"
+	"encoding/binary"
+	"io"
+)
+
+const (
+	opContinuation = 0
+	finalBit       = 0x80
+	maskBit        = 0x80
+	maxControlSize = 125
+)
+
+type frame struct {
+	fin     bool
+	op      int
+	payload []byte
+}
+
+// readFrame 读取一帧,payload超过limit时返回ErrMessageTooLarge,服务器发送的帧不应该有掩码,这里也兼容
+func readFrame(br *bufio.Reader, limit int64) (*frame, error) {
+	var head [2]byte
+	if _, err := io.ReadFull(br, head[:]); err != nil {
+		return nil, err
+	}
+
+	// 没有协商扩展时,RSV必须为0
+	if head[0]&0x70 != 0 {
+		return nil, ErrProtocol
+	}
+
+	f := &frame{fin: head[0]&finalBit != 0, op: int(head[0] & 0x0f)}
+	size := int64(head[1] & 0x7f)
+	switch size {
+	case 126:
+		var ext [2]byte
+		if _, err := io.ReadFull(br, ext[:]); err != nil {
+			return nil, err
+		}
+		size = int64(binary.BigEndian.Uint16(ext[:]))
+	case 127:
+		var ext [8]byte
+		if _, err := io.ReadFull(br, ext[:]); err != nil {
+			return nil, err
+		}
+		size = int64(binary.BigEndian.Uint64(ext[:]))
+		if size < 0 {
+			return nil, ErrProtocol
+		}
+	}
+
+	if f.op >= CloseMessage && (size > maxControlSize || !f.fin) {
+		return nil, ErrProtocol
+	}
+	if limit > 0 && size > limit {
+		return nil, ErrMessageTooLarge
+	}
+
+	var key [4]byte
+	masked := head[1]&maskBit != 0
+	if masked {
+		if _, err := io.ReadFull(br, key[:]); err != nil {
+			return nil, err
+		}
+	}
+
+	f.payload = make([]byte, size)
+	if _, err := io.ReadFull(br, f.payload); err != nil {
+		return nil, err
+	}
+	if masked {
+		maskBytes(key, f.payload)
+	}
+
+	return f, nil
+}
+
+// writeFrame 写入一帧,客户端发送的帧必须使用随机掩码
+func writeFrame(w io.Writer, op int, payload []byte, masked bool) error {
+	buf := make([]byte, 0, 14+len(payload))
+	buf = append(buf, byte(op)|finalBit)
+
+	var mask byte
+	if masked {
+		mask = maskBit
+	}
+	switch size := len(payload); {
+	case size <= 125:
+		buf = append(buf, mask|byte(size))
+	case size <= 0xffff:
+		buf = append(buf, mask|126)
+		buf = binary.BigEndian.AppendUint16(buf, uint16(size))
+	default:
+		buf = append(buf, mask|127)
+		buf = binary.BigEndian.AppendUint64(buf, uint64(size))
+	}
+
+	if masked {
+		var key [4]byte
+		if _, err := rand.Read(key[:]); err != nil {
+			return err
+		}
+		buf = append(buf, key[:]...)
+		start := len(buf)
+		buf = append(buf, payload...)
+		maskBytes(key, buf[start:])
+	} else {
+		buf = append(buf, payload...)
+	}
+
+	_, err := w.Write(buf)
+	return err
+}
+
+func maskBytes(key [4]byte, data []byte) {
+	for i := range data {
+		data[i] ^= key[i&3]
+	}
+}